@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/tharaka70/web_analyzer/internal/analyzer"
+)
+
+// Upper bounds for per-request options so a single API call can't tie up the server
+const (
+	maxAPIRequestBytes    = 1 << 20
+	maxAPILinkConcurrency = 50
+	maxAPITimeout         = 2 * time.Minute
+	maxAPIRedirects       = 20
+	maxAPIRetries         = 5
+)
+
+// Error categories for failures detected by the API layer itself, alongside analyzer.ErrorCategory values
+const (
+	apiErrorCategoryBadRequest  = "bad_request"
+	apiErrorCategoryNotFound    = "not_found"
+	apiErrorCategoryUnavailable = "unavailable"
+	apiErrorCategoryInternal    = "internal"
+)
+
+// apiAnalysisRequest is the JSON body accepted by POST /api/v1/analyses
+type apiAnalysisRequest struct {
+	URL     string             `json:"url"`
+	Options apiAnalysisOptions `json:"options"`
+}
+
+// apiAnalysisOptions tunes a single analysis; zero values keep the server defaults
+type apiAnalysisOptions struct {
+	CheckLinks       *bool    `json:"check_links,omitempty"`
+	DetectLoginForms *bool    `json:"detect_login_forms,omitempty"`
+	CheckSitemap     *bool    `json:"check_sitemap,omitempty"`
+	LinkConcurrency  int      `json:"link_concurrency,omitempty"`
+	LinkTimeoutMs    int      `json:"link_timeout_ms,omitempty"`
+	FetchTimeoutMs   int      `json:"fetch_timeout_ms,omitempty"`
+	UserAgent        string   `json:"user_agent,omitempty"`
+	RobotsPolicy     string   `json:"robots_policy,omitempty"` // "obey" (default) or "ignore"
+	CertExpiryDays   int      `json:"cert_expiry_warning_days,omitempty"`
+	CheckPerformance *bool    `json:"check_performance,omitempty"`
+	Budget           string   `json:"performance_budget,omitempty"` // e.g. "script=1MB,total=3MB"
+	MaxRedirects     *int     `json:"max_redirects,omitempty"`
+	LinkScope        string   `json:"link_scope,omitempty"`          // "host" (default) or "domain"
+	MatchScheme      *bool    `json:"match_scheme,omitempty"`        // links on another scheme are external (default true)
+	LinkAliases      []string `json:"link_aliases,omitempty"`        // hosts whose links count as internal
+	IgnoreParams     []string `json:"ignore_query_params,omitempty"` // e.g. ["utm_*", "ref"]
+	HostConcurrency  int      `json:"host_concurrency,omitempty"`    // links on the same host checked in parallel, below the server limit
+	HostRate         float64  `json:"host_rate,omitempty"`           // link check requests per second per host, below the server limit
+	LinkRetries      *int     `json:"link_retries,omitempty"`        // retries of transient link check failures
+}
+
+// apiErrorResponse is the body of every non-2xx API response
+type apiErrorResponse struct {
+	Error apiError `json:"error"`
+}
+
+type apiError struct {
+	Message    string `json:"message"`
+	Category   string `json:"category"`
+	StatusCode int    `json:"status_code,omitempty"` // status returned by the analyzed page, if any
+}
+
+// analyzerOptions converts the request options into analyzer options, clamped to server limits
+func (o apiAnalysisOptions) analyzerOptions() []analyzer.Option {
+	opts := append(politenessOptions(), analyzer.WithHTTPClient(httpClient))
+	if o.CheckLinks != nil {
+		opts = append(opts, analyzer.WithLinkCheck(*o.CheckLinks))
+	}
+	if o.DetectLoginForms != nil {
+		opts = append(opts, analyzer.WithLoginFormDetection(*o.DetectLoginForms))
+	}
+	if o.CheckSitemap != nil {
+		opts = append(opts, analyzer.WithSitemapCheck(*o.CheckSitemap))
+	}
+	if o.LinkConcurrency > 0 {
+		opts = append(opts, analyzer.WithLinkConcurrency(min(o.LinkConcurrency, maxAPILinkConcurrency)))
+	}
+	if o.LinkTimeoutMs > 0 {
+		opts = append(opts, analyzer.WithLinkTimeout(min(time.Duration(o.LinkTimeoutMs)*time.Millisecond, maxAPITimeout)))
+	}
+	if o.FetchTimeoutMs > 0 {
+		opts = append(opts, analyzer.WithFetchTimeout(min(time.Duration(o.FetchTimeoutMs)*time.Millisecond, maxAPITimeout)))
+	}
+	if o.UserAgent != "" {
+		opts = append(opts, analyzer.WithUserAgent(o.UserAgent))
+	}
+	if policy, ok := analyzer.ParseRobotsPolicy(o.RobotsPolicy); ok {
+		opts = append(opts, analyzer.WithRobotsPolicy(policy))
+	}
+	if o.CheckPerformance != nil {
+		opts = append(opts, analyzer.WithPerformanceCheck(*o.CheckPerformance))
+	}
+	if budget, err := analyzer.ParsePerformanceBudget(o.Budget); o.Budget != "" && err == nil {
+		opts = append(opts, analyzer.WithPerformanceBudget(budget))
+	}
+	if o.CertExpiryDays > 0 {
+		opts = append(opts, analyzer.WithCertExpiryWarning(time.Duration(o.CertExpiryDays)*24*time.Hour))
+	}
+	if o.MaxRedirects != nil {
+		opts = append(opts, analyzer.WithMaxRedirects(min(*o.MaxRedirects, maxAPIRedirects)))
+	}
+	if o.LinkScope != "" || o.MatchScheme != nil || len(o.LinkAliases) > 0 {
+		policy := analyzer.DefaultLinkPolicy
+		if scope, ok := analyzer.ParseLinkScope(o.LinkScope); ok {
+			policy.Scope = scope
+		}
+		if o.MatchScheme != nil {
+			policy.MatchScheme = *o.MatchScheme
+		}
+		policy.Aliases = o.LinkAliases
+		opts = append(opts, analyzer.WithLinkPolicy(policy))
+	}
+	if len(o.IgnoreParams) > 0 {
+		opts = append(opts, analyzer.WithIgnoredQueryParams(o.IgnoreParams...))
+	}
+	// The server's per-host limits apply to every analysis, so a request can only lower them
+	concurrency, rate, burst := hostThrottle.Limits()
+	if o.HostConcurrency > 0 && o.HostConcurrency < concurrency {
+		opts = append(opts, analyzer.WithHostConcurrency(o.HostConcurrency))
+	}
+	if o.HostRate > 0 && (rate == 0 || o.HostRate < rate) {
+		opts = append(opts, analyzer.WithHostRateLimit(o.HostRate, min(burst, max(int(o.HostRate), 1))))
+	}
+	if o.LinkRetries != nil {
+		opts = append(opts, analyzer.WithRetries(min(*o.LinkRetries, maxAPIRetries), 0))
+	}
+	return opts
+}
+
+// validate rejects option values that can't be applied
+func (o apiAnalysisOptions) validate() error {
+	if _, ok := analyzer.ParseRobotsPolicy(o.RobotsPolicy); o.RobotsPolicy != "" && !ok {
+		return fmt.Errorf("invalid robots_policy %q (want %q or %q)", o.RobotsPolicy, analyzer.RobotsObey, analyzer.RobotsIgnore)
+	}
+	if _, err := analyzer.ParsePerformanceBudget(o.Budget); err != nil {
+		return fmt.Errorf("invalid performance_budget: %w", err)
+	}
+	if _, ok := analyzer.ParseLinkScope(o.LinkScope); o.LinkScope != "" && !ok {
+		return fmt.Errorf("invalid link_scope %q (want %q or %q)", o.LinkScope, analyzer.LinkScopeHost, analyzer.LinkScopeDomain)
+	}
+	if o.HostRate < 0 || (o.LinkRetries != nil && *o.LinkRetries < 0) {
+		return fmt.Errorf("host_rate and link_retries must not be negative")
+	}
+	return nil
+}
+
+// apiAnalysesHandler runs an analysis synchronously and returns the AnalysisResult as JSON
+func apiAnalysesHandler(w http.ResponseWriter, r *http.Request) {
+	req, parsedURL, ok := decodeAnalysisRequest(w, r)
+	if !ok {
+		return
+	}
+
+	logger.Info("Attempting to analyze URL via API", "URL", parsedURL.String())
+	result, err := analyzer.New(req.Options.analyzerOptions()...).Analyze(r.Context(), parsedURL.String())
+	if err != nil {
+		logger.Error("Error analyzing URL via API", "URL", parsedURL.String(), "error", err)
+		writeAnalysisError(w, err)
+		return
+	}
+
+	logger.Info("Successfully analyzed URL via API", "URL", parsedURL.String())
+	writeJSON(w, http.StatusOK, result)
+}
+
+// decodeAnalysisRequest reads and validates a JSON analysis request. On failure it has
+// already written the error response and returns ok == false.
+func decodeAnalysisRequest(w http.ResponseWriter, r *http.Request) (req apiAnalysisRequest, parsedURL *url.URL, ok bool) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeAPIError(w, http.StatusMethodNotAllowed, apiError{Message: "Method not allowed", Category: apiErrorCategoryBadRequest})
+		return req, nil, false
+	}
+
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAPIRequestBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, apiError{Message: "Invalid JSON body: " + err.Error(), Category: apiErrorCategoryBadRequest})
+		return req, nil, false
+	}
+
+	if err := req.Options.validate(); err != nil {
+		writeAPIError(w, http.StatusBadRequest, apiError{Message: err.Error(), Category: apiErrorCategoryBadRequest})
+		return req, nil, false
+	}
+
+	parsedURL, validationErr := analyzer.ValidateURL(req.URL)
+	if validationErr != nil {
+		writeAnalysisError(w, validationErr)
+		return req, nil, false
+	}
+	return req, parsedURL, true
+}
+
+// writeAnalysisError maps an analyzer error to an HTTP status and a structured error body
+func writeAnalysisError(w http.ResponseWriter, err error) {
+	status, apiErr := analysisErrorResponse(err)
+	writeAPIError(w, status, apiErr)
+}
+
+// analysisErrorResponse converts an analyzer error into an HTTP status and API error body
+func analysisErrorResponse(err error) (int, apiError) {
+	var ae *analyzer.AnalysisError
+	if !errors.As(err, &ae) {
+		return http.StatusInternalServerError, apiError{Message: err.Error(), Category: apiErrorCategoryInternal}
+	}
+
+	status := http.StatusBadGateway
+	switch ae.Category {
+	case analyzer.ErrorCategoryInvalidURL:
+		status = http.StatusBadRequest
+	case analyzer.ErrorCategoryNotHTML, analyzer.ErrorCategoryRobots, analyzer.ErrorCategoryRedirect:
+		status = http.StatusUnprocessableEntity
+	case analyzer.ErrorCategoryTimeout:
+		status = http.StatusGatewayTimeout
+	case analyzer.ErrorCategoryCancelled:
+		status = http.StatusServiceUnavailable
+	}
+	return status, apiError{Message: ae.Message, Category: string(ae.Category), StatusCode: ae.StatusCode}
+}
+
+func writeAPIError(w http.ResponseWriter, status int, apiErr apiError) {
+	writeJSON(w, status, apiErrorResponse{Error: apiErr})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.Error("Error encoding JSON response", "error", err)
+	}
+}
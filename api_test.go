@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/tharaka70/web_analyzer/internal/analyzer"
+	"github.com/tharaka70/web_analyzer/internal/jobs"
+)
+
+func TestMain(m *testing.M) {
+	if err := loadTemplates("templates"); err != nil {
+		panic(err)
+	}
+	jobManager = jobs.NewManager()
+	os.Exit(m.Run())
+}
+
+func TestAPIAnalysesHandler(t *testing.T) {
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<!DOCTYPE html><html><head><title>API Page</title></head><body><h1>Hi</h1><a href="/missing">gone</a></body></html>`)
+	}))
+	defer page.Close()
+
+	testCases := []struct {
+		name         string
+		method       string
+		body         string
+		wantStatus   int
+		wantCategory string
+	}{
+		{"Success", http.MethodPost, fmt.Sprintf(`{"url": %q, "options": {"link_concurrency": 2}}`, page.URL), http.StatusOK, ""},
+		{"MethodNotAllowed", http.MethodGet, "", http.StatusMethodNotAllowed, apiErrorCategoryBadRequest},
+		{"MalformedJSON", http.MethodPost, `{"url":`, http.StatusBadRequest, apiErrorCategoryBadRequest},
+		{"UnknownOption", http.MethodPost, fmt.Sprintf(`{"url": %q, "options": {"bogus": true}}`, page.URL), http.StatusBadRequest, apiErrorCategoryBadRequest},
+		{"InvalidRobotsPolicy", http.MethodPost, fmt.Sprintf(`{"url": %q, "options": {"robots_policy": "sometimes"}}`, page.URL), http.StatusBadRequest, apiErrorCategoryBadRequest},
+		{"InvalidURL", http.MethodPost, `{"url": "ftp://example.com"}`, http.StatusBadRequest, string(analyzer.ErrorCategoryInvalidURL)},
+		{"UpstreamHTTPError", http.MethodPost, fmt.Sprintf(`{"url": %q}`, page.URL+"/missing"), http.StatusBadGateway, string(analyzer.ErrorCategoryHTTPStatus)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, "/api/v1/analyses", strings.NewReader(tc.body))
+			rec := httptest.NewRecorder()
+			apiAnalysesHandler(rec, req)
+
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("Expected status %d, got %d. Body: %s", tc.wantStatus, rec.Code, rec.Body.String())
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Expected Content-Type application/json, got %q", ct)
+			}
+
+			if tc.wantCategory != "" {
+				var errBody apiErrorResponse
+				if err := json.Unmarshal(rec.Body.Bytes(), &errBody); err != nil {
+					t.Fatalf("Could not decode error body: %v", err)
+				}
+				if errBody.Error.Category != tc.wantCategory {
+					t.Errorf("Expected error category %q, got %q", tc.wantCategory, errBody.Error.Category)
+				}
+				return
+			}
+
+			var result map[string]any
+			if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+				t.Fatalf("Could not decode result body: %v", err)
+			}
+			if result["page_title"] != "API Page" {
+				t.Errorf("Expected page_title 'API Page', got %v", result["page_title"])
+			}
+			links, _ := result["inaccessible_links"].([]any)
+			if len(links) != 1 {
+				t.Errorf("Expected 1 inaccessible link, got %v", result["inaccessible_links"])
+			}
+		})
+	}
+}
+
+func TestJobHandlers(t *testing.T) {
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<!DOCTYPE html><html><head><title>Job Page</title></head><body><a href="/other">other</a></body></html>`)
+	}))
+	defer page.Close()
+
+	app := httptest.NewServer(newRouter("static"))
+	defer app.Close()
+
+	resp, err := http.Post(app.URL+"/jobs", "application/json", strings.NewReader(fmt.Sprintf(`{"url": %q}`, page.URL)))
+	if err != nil {
+		t.Fatalf("POST /jobs failed: %v", err)
+	}
+	var submitted apiJob
+	json.NewDecoder(resp.Body).Decode(&submitted)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d", http.StatusAccepted, resp.StatusCode)
+	}
+	if submitted.ID == "" || resp.Header.Get("Location") != submitted.StatusURL {
+		t.Fatalf("Expected job ID and matching Location header, got %+v (Location %q)", submitted, resp.Header.Get("Location"))
+	}
+
+	// The event stream ends with a "done" event carrying the final job
+	resp, err = http.Get(app.URL + submitted.EventsURL)
+	if err != nil {
+		t.Fatalf("GET events failed: %v", err)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Expected Content-Type text/event-stream, got %q", ct)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	events := strings.Split(strings.TrimSpace(string(body)), "\n\n")
+	last := events[len(events)-1]
+	if !strings.HasPrefix(last, "event: done\ndata: ") {
+		t.Fatalf("Expected stream to end with a done event, got %q", last)
+	}
+	var final apiJob
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(last, "event: done\ndata: ")), &final); err != nil {
+		t.Fatalf("Could not decode done event: %v", err)
+	}
+	if final.Status != jobs.StatusSucceeded || final.Result == nil || final.Result.PageTitle != "Job Page" {
+		t.Errorf("Expected succeeded job with title 'Job Page', got %+v", final)
+	}
+
+	resp, err = http.Get(app.URL + submitted.StatusURL)
+	if err != nil {
+		t.Fatalf("GET job failed: %v", err)
+	}
+	var polled apiJob
+	json.NewDecoder(resp.Body).Decode(&polled)
+	resp.Body.Close()
+	if polled.Status != jobs.StatusSucceeded {
+		t.Errorf("Expected polled job to be succeeded, got %s", polled.Status)
+	}
+
+	resp, err = http.Get(app.URL + submitted.ReportURL)
+	if err != nil {
+		t.Fatalf("GET report failed: %v", err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(body), "Job Page") {
+		t.Errorf("Expected report page to contain the analysis results")
+	}
+
+	resp, err = http.Get(app.URL + "/jobs/does-not-exist")
+	if err != nil {
+		t.Fatalf("GET missing job failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status %d for unknown job, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+}
@@ -0,0 +1,805 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tharaka70/web_analyzer/internal/analyzer"
+	"github.com/tharaka70/web_analyzer/internal/crawler"
+)
+
+// Conditions that can make the analyze command fail (see --fail-on)
+const (
+	conditionInaccessibleLinks = "inaccessible-links"
+	conditionMissingTitle      = "missing-title"
+	conditionMissingH1         = "missing-h1"
+	conditionLoginForm         = "login-form"
+	conditionUnknownDoctype    = "unknown-doctype"
+	conditionOverBudget        = "over-budget"
+)
+
+// failureChecks evaluates each --fail-on condition against a result, returning a
+// description of the problem or "" when the page passes
+var failureChecks = map[string]func(*analyzer.AnalysisResult) string{
+	conditionInaccessibleLinks: func(r *analyzer.AnalysisResult) string {
+		if n := len(r.InaccessibleLinks); n > 0 {
+			return fmt.Sprintf("%d inaccessible link(s)", n)
+		}
+		return ""
+	},
+	conditionMissingTitle: func(r *analyzer.AnalysisResult) string {
+		if r.PageTitle == "" {
+			return "page has no title"
+		}
+		return ""
+	},
+	conditionMissingH1: func(r *analyzer.AnalysisResult) string {
+		if r.HeadingsCount["h1"] == 0 {
+			return "page has no h1 heading"
+		}
+		return ""
+	},
+	conditionLoginForm: func(r *analyzer.AnalysisResult) string {
+		if r.ContainsLoginForm {
+			return "page contains a login form"
+		}
+		return ""
+	},
+	conditionUnknownDoctype: func(r *analyzer.AnalysisResult) string {
+		if strings.Contains(r.HTMLVersion, "Unknown") {
+			return "unrecognized or missing doctype: " + r.HTMLVersion
+		}
+		return ""
+	},
+	conditionOverBudget: func(r *analyzer.AnalysisResult) string {
+		var over []string
+		for _, v := range r.Performance.Violations {
+			over = append(over, fmt.Sprintf("%s %d/%d bytes", v.Kind, v.Actual, v.Limit))
+		}
+		if len(over) > 0 {
+			return "performance budget exceeded: " + strings.Join(over, ", ")
+		}
+		return ""
+	},
+}
+
+// cliReport is the outcome of analyzing one URL from the command line
+type cliReport struct {
+	URL      string                   `json:"url"`
+	Result   *analyzer.AnalysisResult `json:"result,omitempty"`
+	Error    *apiError                `json:"error,omitempty"`
+	Failures []cliFailure             `json:"failures,omitempty"`
+}
+
+// cliFailure is a --fail-on condition that a page did not pass
+type cliFailure struct {
+	Condition string `json:"condition"`
+	Message   string `json:"message"`
+}
+
+// commonFlags are the flags shared by the analyze and crawl commands
+type commonFlags struct {
+	format       *string
+	failOn       *string
+	checkLinks   *bool
+	checkSitemap *bool
+	checkPerf    *bool
+	budget       *string
+	concurrency  *int
+	fetchTimeout *time.Duration
+	linkTimeout  *time.Duration
+	userAgent    *string
+	robots       *string
+	certExpiry   *time.Duration
+	maxRedirects *int
+	linkScope    *string
+	matchScheme  *bool
+	linkAliases  *string
+	ignoreParams *string
+	perHost      *int
+	hostRate     *float64
+	hostBurst    *int
+	retries      *int
+	retryBackoff *time.Duration
+	verbose      *bool
+}
+
+func addCommonFlags(flags *flag.FlagSet) *commonFlags {
+	return &commonFlags{
+		format: flags.String("format", "text", "output format: text or json"),
+		failOn: flags.String("fail-on", conditionInaccessibleLinks+","+conditionMissingTitle,
+			"comma-separated conditions that cause a non-zero exit code: "+strings.Join(sortedConditions(), ", ")+` (or "none")`),
+		checkLinks:   flags.Bool("check-links", true, "check whether links are accessible"),
+		checkSitemap: flags.Bool("check-sitemap", false, "discover the site's sitemaps and check the URLs they list"),
+		checkPerf:    flags.Bool("check-performance", false, "probe every resource of the page for its transfer size and cache headers"),
+		budget:       flags.String("budget", "", `performance budget, e.g. "script=1MB,image=2MB,total=3MB" (default: built-in budget)`),
+		concurrency:  flags.Int("concurrency", analyzer.DefaultLinkConcurrency, "number of links checked in parallel"),
+		fetchTimeout: flags.Duration("fetch-timeout", analyzer.DefaultFetchTimeout, "timeout for fetching each page"),
+		linkTimeout:  flags.Duration("link-timeout", analyzer.DefaultLinkTimeout, "timeout for each request made to check a link"),
+		userAgent:    flags.String("user-agent", analyzer.DefaultUserAgent, "User-Agent header sent with every request"),
+		robots:       flags.String("robots", string(analyzer.RobotsObey), `robots.txt policy: "obey" or "ignore"`),
+		certExpiry:   flags.Duration("cert-expiry-warning", analyzer.DefaultCertExpiryWarning, "warn about TLS certificates expiring within this duration"),
+		maxRedirects: flags.Int("max-redirects", analyzer.DefaultMaxRedirects, "redirects followed for each analyzed page"),
+		linkScope:    flags.String("link-scope", string(analyzer.LinkScopeHost), `what counts as an internal link: "host" or "domain" (registrable domain, e.g. any *.example.com)`),
+		matchScheme:  flags.Bool("match-scheme", true, "count links on another scheme (http vs https) as external"),
+		linkAliases:  flags.String("link-aliases", "", "comma-separated hosts whose links count as internal, e.g. a CDN"),
+		ignoreParams: flags.String("ignore-params", "", `comma-separated query parameters ignored when deduplicating links, e.g. "utm_*,ref"`),
+		perHost:      flags.Int("host-concurrency", analyzer.DefaultHostConcurrency, "number of links on the same host checked in parallel"),
+		hostRate:     flags.Float64("host-rate", analyzer.DefaultHostRate, "link check requests per second to each host (0 for no limit)"),
+		hostBurst:    flags.Int("host-burst", analyzer.DefaultHostBurst, "requests a host may receive at once before -host-rate applies"),
+		retries:      flags.Int("retries", analyzer.DefaultMaxRetries, "retries of link checks failing with 429, 5xx gateway errors or connection errors"),
+		retryBackoff: flags.Duration("retry-backoff", analyzer.DefaultRetryBackoff, "wait before the first retry, doubled for each further one unless the host sends Retry-After"),
+		verbose:      flags.Bool("v", false, "log progress to stderr"),
+	}
+}
+
+// validate checks the format and returns the parsed --fail-on conditions
+func (f *commonFlags) validate() ([]string, error) {
+	if *f.format != "text" && *f.format != "json" {
+		return nil, fmt.Errorf("unknown format %q (want text or json)", *f.format)
+	}
+	if _, ok := analyzer.ParseRobotsPolicy(*f.robots); !ok {
+		return nil, fmt.Errorf("unknown robots policy %q (want obey or ignore)", *f.robots)
+	}
+	if _, err := analyzer.ParsePerformanceBudget(*f.budget); err != nil {
+		return nil, fmt.Errorf("invalid budget: %w", err)
+	}
+	if _, ok := analyzer.ParseLinkScope(*f.linkScope); !ok {
+		return nil, fmt.Errorf("unknown link scope %q (want host or domain)", *f.linkScope)
+	}
+	if *f.hostRate < 0 || *f.retries < 0 {
+		return nil, fmt.Errorf("-host-rate and -retries must not be negative")
+	}
+	return parseConditions(*f.failOn)
+}
+
+// setupLogging keeps logs on stderr, quiet unless asked for, since results go to stdout
+func (f *commonFlags) setupLogging(stderr io.Writer) {
+	logLevel := slog.LevelWarn
+	if *f.verbose {
+		logLevel = slog.LevelInfo
+	}
+	logger = slog.New(slog.NewTextHandler(stderr, &slog.HandlerOptions{Level: logLevel}))
+	slog.SetDefault(logger)
+}
+
+func (f *commonFlags) analyzerOptions() []analyzer.Option {
+	opts := []analyzer.Option{
+		analyzer.WithHTTPClient(httpClient),
+		analyzer.WithLinkCheck(*f.checkLinks),
+		analyzer.WithSitemapCheck(*f.checkSitemap),
+		analyzer.WithLinkConcurrency(*f.concurrency),
+		analyzer.WithFetchTimeout(*f.fetchTimeout),
+		analyzer.WithLinkTimeout(*f.linkTimeout),
+		analyzer.WithUserAgent(*f.userAgent),
+		analyzer.WithRobotsPolicy(analyzer.RobotsPolicy(*f.robots)),
+		analyzer.WithCertExpiryWarning(*f.certExpiry),
+		analyzer.WithPerformanceCheck(*f.checkPerf),
+		analyzer.WithMaxRedirects(*f.maxRedirects),
+		analyzer.WithLinkPolicy(f.linkPolicy()),
+		analyzer.WithIgnoredQueryParams(splitFlagList(*f.ignoreParams)...),
+		analyzer.WithHostConcurrency(*f.perHost),
+		analyzer.WithHostRateLimit(*f.hostRate, *f.hostBurst),
+		analyzer.WithRetries(*f.retries, *f.retryBackoff),
+	}
+	if *f.budget != "" {
+		budget, _ := analyzer.ParsePerformanceBudget(*f.budget) // checked by validate
+		opts = append(opts, analyzer.WithPerformanceBudget(budget))
+	}
+	return opts
+}
+
+// linkPolicy builds the link classification policy from the flags
+func (f *commonFlags) linkPolicy() analyzer.LinkPolicy {
+	scope, _ := analyzer.ParseLinkScope(*f.linkScope) // checked by validate
+	return analyzer.LinkPolicy{Scope: scope, MatchScheme: *f.matchScheme, Aliases: splitFlagList(*f.linkAliases)}
+}
+
+// runAnalyze implements "web_analyzer analyze": it analyzes each URL, prints the results
+// and returns a non-zero exit code if any page fails to analyze or trips a --fail-on condition
+func runAnalyze(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("analyze", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	common := addCommonFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: web_analyzer analyze [flags] <url>...")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if flags.NArg() == 0 {
+		fmt.Fprintln(stderr, "analyze: at least one URL is required")
+		flags.Usage()
+		return exitUsage
+	}
+	conditions, err := common.validate()
+	if err != nil {
+		fmt.Fprintf(stderr, "analyze: %v\n", err)
+		return exitUsage
+	}
+	common.setupLogging(stderr)
+
+	a := analyzer.New(common.analyzerOptions()...)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	exitCode := exitOK
+	reports := make([]cliReport, 0, flags.NArg())
+	for _, rawURL := range flags.Args() {
+		report := analyzeForCLI(ctx, a, rawURL, conditions)
+		switch {
+		case report.Error != nil:
+			exitCode = exitFailure
+		case len(report.Failures) > 0 && exitCode == exitOK:
+			exitCode = exitChecksFailed
+		}
+		reports = append(reports, report)
+	}
+
+	if *common.format == "json" {
+		if err := writeJSONOutput(stdout, reports); err != nil {
+			fmt.Fprintf(stderr, "analyze: writing JSON: %v\n", err)
+			return exitFailure
+		}
+	} else {
+		for _, report := range reports {
+			writeTextReport(stdout, report)
+		}
+	}
+	return exitCode
+}
+
+// analyzeForCLI validates and analyzes a single URL and evaluates the fail-on conditions
+func analyzeForCLI(ctx context.Context, a *analyzer.Analyzer, rawURL string, conditions []string) cliReport {
+	report := cliReport{URL: rawURL}
+
+	parsedURL, err := analyzer.ValidateURL(rawURL)
+	if err == nil {
+		report.Result, err = a.Analyze(ctx, parsedURL.String())
+	}
+	if err != nil {
+		_, apiErr := analysisErrorResponse(err)
+		report.Error = &apiErr
+		return report
+	}
+
+	report.Failures = evaluateConditions(report.Result, conditions)
+	return report
+}
+
+// evaluateConditions returns the --fail-on conditions that the result does not pass
+func evaluateConditions(result *analyzer.AnalysisResult, conditions []string) []cliFailure {
+	var failures []cliFailure
+	for _, condition := range conditions {
+		if msg := failureChecks[condition](result); msg != "" {
+			failures = append(failures, cliFailure{Condition: condition, Message: msg})
+		}
+	}
+	return failures
+}
+
+func writeJSONOutput(w io.Writer, v any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// splitFlagList splits a comma-separated flag value, dropping blank items
+func splitFlagList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseConditions splits and validates the --fail-on flag value
+func parseConditions(value string) ([]string, error) {
+	var conditions []string
+	for _, c := range strings.Split(value, ",") {
+		c = strings.TrimSpace(c)
+		if c == "" || c == "none" {
+			continue
+		}
+		if _, ok := failureChecks[c]; !ok {
+			return nil, fmt.Errorf("unknown --fail-on condition %q (valid: %s)", c, strings.Join(sortedConditions(), ", "))
+		}
+		conditions = append(conditions, c)
+	}
+	return conditions, nil
+}
+
+func sortedConditions() []string {
+	names := make([]string, 0, len(failureChecks))
+	for name := range failureChecks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// writeTextReport prints a human-readable summary of one report
+func writeTextReport(w io.Writer, report cliReport) {
+	fmt.Fprintf(w, "URL: %s\n", report.URL)
+	if report.Error != nil {
+		fmt.Fprintf(w, "  ERROR [%s]: %s\n\n", report.Error.Category, report.Error.Message)
+		return
+	}
+
+	r := report.Result
+	if r.FinalURL != "" && r.FinalURL != report.URL {
+		fmt.Fprintf(w, "  Final URL:     %s\n", r.FinalURL)
+	}
+	writeRedirectsText(w, r.Redirects)
+	if r.BaseURL != r.FinalURL {
+		fmt.Fprintf(w, "  Base URL:      %s\n", r.BaseURL)
+	}
+	fmt.Fprintf(w, "  HTML version:  %s\n", r.HTMLVersion)
+	fmt.Fprintf(w, "  Encoding:      %s (from %s)\n", r.Encoding.Name, r.Encoding.Source)
+	for _, warning := range r.Encoding.Warnings {
+		fmt.Fprintf(w, "    - %s\n", warning)
+	}
+	fmt.Fprintf(w, "  Title:         %s\n", r.PageTitle)
+	writeMetadataText(w, r.Metadata)
+
+	levels := make([]string, 0, len(r.HeadingsCount))
+	for level := range r.HeadingsCount {
+		levels = append(levels, level)
+	}
+	sort.Strings(levels)
+	headings := make([]string, 0, len(levels))
+	for _, level := range levels {
+		headings = append(headings, fmt.Sprintf("%s=%d", level, r.HeadingsCount[level]))
+	}
+	fmt.Fprintf(w, "  Headings:      %s\n", strings.Join(headings, " "))
+	if o := r.Outline; o.MultipleH1 || o.SkippedLevels > 0 || o.EmptyHeadings > 0 {
+		fmt.Fprintf(w, "    %d h1, %d skipped level(s), %d empty\n", o.H1Count, o.SkippedLevels, o.EmptyHeadings)
+	}
+
+	fmt.Fprintf(w, "  Links:         %d internal, %d external, %d inaccessible, %d skipped (robots.txt)\n",
+		r.InternalLinksCount, r.ExternalLinksCount, len(r.InaccessibleLinks), len(r.SkippedLinks))
+	writeLinkDomainsText(w, r)
+	for _, link := range r.InaccessibleLinks {
+		status := string(link.ErrorClass)
+		if link.StatusCode != 0 {
+			status = fmt.Sprintf("%d %s", link.StatusCode, link.ErrorClass)
+		}
+		occurrences := ""
+		if link.Occurrences > 1 {
+			occurrences = fmt.Sprintf(", %d occurrences", link.Occurrences)
+		}
+		if link.Attempts > 1 {
+			occurrences += fmt.Sprintf(", %d attempts", link.Attempts)
+		}
+		fmt.Fprintf(w, "    - %s [%s, %s, %s%s]\n", link.URL, status, link.Method, link.Latency.Round(time.Millisecond), occurrences)
+	}
+	writeResourcesText(w, r)
+	fmt.Fprintf(w, "  Social:        %d Open Graph, %d Twitter, %d article properties; %s card, %d issue(s)\n",
+		len(r.Social.OpenGraph), len(r.Social.Twitter), len(r.Social.Article), r.Social.Preview.Card, len(r.Social.Issues))
+	for _, issue := range r.Social.Issues {
+		fmt.Fprintf(w, "    - %s [%s]\n", issue.Property, issue.Message)
+	}
+	writeStructuredDataText(w, r.StructuredData)
+	fmt.Fprintf(w, "  Accessibility: %d error(s), %d warning(s)\n", r.Accessibility.Errors, r.Accessibility.Warnings)
+	for _, f := range r.Accessibility.Findings {
+		if f.Severity == analyzer.SeverityError {
+			fmt.Fprintf(w, "    - %s [%s, WCAG %s] %s\n", f.Path, f.RuleID, f.WCAG, f.Message)
+		}
+	}
+	if r.TLS != nil {
+		fmt.Fprintf(w, "  TLS:           %s, %s; certificate for %s expires %s (%d days)\n", r.TLS.Version, r.TLS.CipherSuite,
+			r.TLS.Certificate.Subject, r.TLS.Certificate.NotAfter.Format(time.DateOnly), r.TLS.Certificate.DaysRemaining)
+		for _, warning := range r.TLS.Warnings {
+			fmt.Fprintf(w, "    - %s\n", warning)
+		}
+	}
+	if len(r.MixedContent) > 0 {
+		fmt.Fprintf(w, "  Mixed content: %d resource(s) over plain HTTP\n", len(r.MixedContent))
+		for _, m := range r.MixedContent {
+			fmt.Fprintf(w, "    - %s [%s, <%s %s>]\n", m.URL, m.Kind, m.Tag, m.Attribute)
+		}
+	}
+	writePerformanceText(w, r.Performance)
+	secErrors, secWarnings := r.Security.Counts()
+	fmt.Fprintf(w, "  Security:      grade %s (%d/100), %d error(s), %d warning(s)\n", r.Security.Grade, r.Security.Score, secErrors, secWarnings)
+	for _, f := range r.Security.Findings {
+		if f.Severity == analyzer.SeverityError {
+			fmt.Fprintf(w, "    - %s: %s\n", f.Header, f.Message)
+		}
+	}
+	loginForm := "no"
+	if r.ContainsLoginForm {
+		loginForm = "yes"
+	}
+	fmt.Fprintf(w, "  Login form:    %s\n", loginForm)
+	fmt.Fprintf(w, "  Forms:         %d, %d password field(s) outside forms\n", len(r.Forms.Forms), len(r.Forms.PasswordFieldsOutsideForms))
+	for _, f := range r.Forms.Forms {
+		fmt.Fprintf(w, "    - %s %s [%s, %d%%]\n", f.Method, f.Action, f.Kind, f.ConfidencePercent())
+		for _, issue := range f.Issues {
+			fmt.Fprintf(w, "      ! %s\n", issue)
+		}
+	}
+	if r.Sitemap != nil {
+		writeSitemapText(w, r.Sitemap)
+	}
+
+	for _, failure := range report.Failures {
+		fmt.Fprintf(w, "  FAIL %s: %s\n", failure.Condition, failure.Message)
+	}
+	fmt.Fprintln(w)
+}
+
+// writeMetadataText prints the metadata fields that are present on the page
+func writeMetadataText(w io.Writer, m analyzer.PageMetadata) {
+	if m.Description != "" {
+		fmt.Fprintf(w, "  Description:   %s\n", m.Description)
+	}
+	if m.Canonical != "" {
+		status := ""
+		switch c := m.CanonicalStatus; {
+		case c == nil:
+		case c.SkippedByRobots:
+			status = " [not checked, robots.txt]"
+		case !c.Accessible():
+			status = fmt.Sprintf(" [inaccessible: %s]", c.ErrorClass)
+			if c.StatusCode != 0 {
+				status = fmt.Sprintf(" [inaccessible: %d %s]", c.StatusCode, c.ErrorClass)
+			}
+		}
+		fmt.Fprintf(w, "  Canonical:     %s%s\n", m.Canonical, status)
+	}
+	if len(m.Robots) > 0 || len(m.Googlebot) > 0 || len(m.XRobotsTag) > 0 {
+		fmt.Fprintf(w, "  Robots:        meta=%s googlebot=%s header=%s\n",
+			strings.Join(m.Robots, ","), strings.Join(m.Googlebot, ","), strings.Join(m.XRobotsTag, "; "))
+	}
+	if m.Viewport != "" {
+		fmt.Fprintf(w, "  Viewport:      %s\n", m.Viewport)
+	}
+	if m.Charset != "" {
+		fmt.Fprintf(w, "  Charset:       %s\n", m.Charset)
+	}
+}
+
+// writePerformanceText prints the page weight by kind and the budget violations
+func writePerformanceText(w io.Writer, p analyzer.PerformanceReport) {
+	doc := p.Document
+	fmt.Fprintf(w, "  Page weight:   %d bytes total; document %d bytes", p.Totals[analyzer.ResourceTotal], doc.TransferSize)
+	if doc.ContentEncoding != "" {
+		fmt.Fprintf(w, " (%s)", doc.ContentEncoding)
+	}
+	fmt.Fprintf(w, ", TTFB %s, download %s\n", doc.TTFB.Round(time.Millisecond), p.DownloadTime.Round(time.Millisecond))
+	if !p.ResourcesProbed {
+		return
+	}
+	kinds := make([]string, 0, len(p.Totals))
+	for kind := range p.Totals {
+		if kind != analyzer.ResourceTotal {
+			kinds = append(kinds, string(kind))
+		}
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		fmt.Fprintf(w, "    - %s: %d bytes\n", kind, p.Totals[analyzer.ResourceKind(kind)])
+	}
+	for _, v := range p.Violations {
+		fmt.Fprintf(w, "    ! %s over budget: %d of %d bytes\n", v.Kind, v.Actual, v.Limit)
+	}
+}
+
+// writeLinkDomainsText prints the policy links were classified by and the most linked hosts
+func writeLinkDomainsText(w io.Writer, r *analyzer.AnalysisResult) {
+	const maxDomains = 5
+	domains := make([]string, 0, maxDomains)
+	for _, d := range r.LinkDomains[:min(len(r.LinkDomains), maxDomains)] {
+		domains = append(domains, fmt.Sprintf("%s %d", d.Domain, d.Count))
+	}
+	if more := len(r.LinkDomains) - maxDomains; more > 0 {
+		domains = append(domains, fmt.Sprintf("%d more", more))
+	}
+	fmt.Fprintf(w, "    internal means %s\n", r.LinkPolicy)
+	if len(domains) > 0 {
+		fmt.Fprintf(w, "    by host: %s\n", strings.Join(domains, ", "))
+	}
+}
+
+// writeRedirectsText prints the redirect chain that led to the page, if there was one
+func writeRedirectsText(w io.Writer, r analyzer.RedirectReport) {
+	if r.Count() == 0 {
+		return
+	}
+	var flags []string
+	if r.HTTPSUpgrade {
+		flags = append(flags, "HTTP to HTTPS")
+	}
+	if r.HostChanged {
+		flags = append(flags, "host changed")
+	}
+	summary := fmt.Sprint(r.Count())
+	if len(flags) > 0 {
+		summary += " (" + strings.Join(flags, ", ") + ")"
+	}
+	fmt.Fprintf(w, "  Redirects:     %s\n", summary)
+	for _, hop := range r.Hops {
+		fmt.Fprintf(w, "    - %d %s (%d ms)\n", hop.StatusCode, hop.URL, hop.LatencyMillis())
+	}
+	for _, warning := range r.Warnings {
+		fmt.Fprintf(w, "    - warning: %s\n", warning)
+	}
+}
+
+// writeResourcesText prints the number of subresources per kind, first-party and third-party
+func writeResourcesText(w io.Writer, r *analyzer.AnalysisResult) {
+	thirdParty := 0
+	for _, res := range r.Resources {
+		if !res.FirstParty {
+			thirdParty++
+		}
+	}
+	kinds := make([]string, 0, len(r.ResourceCounts))
+	for kind := range r.ResourceCounts {
+		kinds = append(kinds, string(kind))
+	}
+	sort.Strings(kinds)
+	for i, kind := range kinds {
+		kinds[i] = fmt.Sprintf("%d %s", r.ResourceCounts[analyzer.ResourceKind(kind)], kind)
+	}
+	fmt.Fprintf(w, "  Resources:     %d (%d third-party)", len(r.Resources), thirdParty)
+	if len(kinds) > 0 {
+		fmt.Fprintf(w, ": %s", strings.Join(kinds, ", "))
+	}
+	fmt.Fprintln(w)
+}
+
+// writeStructuredDataText prints the structured data items by type along with parse errors and validation errors
+func writeStructuredDataText(w io.Writer, d analyzer.StructuredData) {
+	types := make([]string, 0, len(d.Items))
+	for _, item := range d.Items {
+		types = append(types, fmt.Sprintf("%s (%s)", strings.Join(item.Types, ","), item.Format))
+	}
+	warnings := 0
+	for _, issue := range d.Issues {
+		if issue.Severity == analyzer.SeverityWarning {
+			warnings++
+		}
+	}
+	fmt.Fprintf(w, "  Structured:    %d item(s) %s, %d malformed JSON-LD, %d error(s), %d warning(s)\n",
+		len(d.Items), strings.Join(types, " "), len(d.Errors), len(d.Issues)-warnings, warnings)
+	for _, e := range d.Errors {
+		fmt.Fprintf(w, "    - JSON-LD block %d, line %d, column %d [%s]\n", e.Block, e.Line, e.Column, e.Message)
+	}
+	for _, issue := range d.Issues {
+		if issue.Severity == analyzer.SeverityError {
+			fmt.Fprintf(w, "    - %s: %s.%s [%s]\n", issue.Path, issue.Type, issue.Property, issue.Message)
+		}
+	}
+}
+
+// writeSitemapText prints the sitemap section shared by the analyze and crawl text reports
+func writeSitemapText(w io.Writer, s *analyzer.SitemapReport) {
+	if len(s.Files) == 0 {
+		fmt.Fprintln(w, "  Sitemap:       none found")
+		return
+	}
+	truncated := ""
+	if s.Truncated {
+		truncated = " (check limit reached)"
+	}
+	fmt.Fprintf(w, "  Sitemap:       %d file(s), %d URLs, %d checked%s: %d broken, %d redirecting, %d blocked, %d non-canonical\n",
+		len(s.Files), s.URLsTotal, s.URLsChecked, truncated, len(s.Broken), len(s.Redirecting), len(s.Blocked), len(s.NonCanonical))
+	for _, f := range s.Files {
+		if f.Error != "" {
+			fmt.Fprintf(w, "    - %s [%s, %s]\n", f.URL, f.Source, f.Error)
+		}
+	}
+	for _, link := range s.Broken {
+		status := string(link.ErrorClass)
+		if link.StatusCode != 0 {
+			status = fmt.Sprintf("%d %s", link.StatusCode, link.ErrorClass)
+		}
+		fmt.Fprintf(w, "    - %s [%s]\n", link.URL, status)
+	}
+	for _, link := range s.Redirecting {
+		fmt.Fprintf(w, "    - %s [redirects to %s]\n", link.URL, link.RedirectChain[len(link.RedirectChain)-1])
+	}
+	for _, link := range s.Blocked {
+		fmt.Fprintf(w, "    - %s [blocked by robots.txt]\n", link.URL)
+	}
+	for _, issue := range s.NonCanonical {
+		fmt.Fprintf(w, "    - %s [%s]\n", issue.URL, issue.Reason)
+	}
+}
+
+// stringList is a flag.Value collecting every occurrence of a repeatable flag
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// compilePatterns compiles each regular expression, naming the flag in errors
+func compilePatterns(flagName string, patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -%s pattern %q: %v", flagName, p, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// crawlPageFailures is a crawled page that tripped a --fail-on condition. Pages that failed to
+// analyze are listed in the site report instead.
+type crawlPageFailures struct {
+	URL      string       `json:"url"`
+	Failures []cliFailure `json:"failures"`
+}
+
+// crawlOutput is the JSON written by the crawl command
+type crawlOutput struct {
+	Report   *crawler.SiteReport `json:"report"`
+	Failures []crawlPageFailures `json:"failures,omitempty"`
+}
+
+// runCrawl implements "web_analyzer crawl": it crawls a site from a seed URL, prints the
+// site report and returns a non-zero exit code if any page trips a --fail-on condition
+func runCrawl(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("crawl", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	common := addCommonFlags(flags)
+	maxDepth := flags.Int("max-depth", crawler.DefaultMaxDepth, "how many links away from the seed to follow")
+	maxPages := flags.Int("max-pages", crawler.DefaultMaxPages, "maximum number of pages to analyze")
+	pageConcurrency := flags.Int("page-concurrency", crawler.DefaultConcurrency, "number of pages analyzed in parallel")
+	perHost := flags.Int("per-host", crawler.DefaultPerHostLimit, "number of pages of the same host analyzed in parallel")
+	var include, exclude stringList
+	flags.Var(&include, "include", "only follow links whose path matches this regular expression (repeatable)")
+	flags.Var(&exclude, "exclude", "never follow links whose path matches this regular expression (repeatable)")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: web_analyzer crawl [flags] <seed-url>")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(stderr, "crawl: exactly one seed URL is required")
+		flags.Usage()
+		return exitUsage
+	}
+	conditions, err := common.validate()
+	if err != nil {
+		fmt.Fprintf(stderr, "crawl: %v\n", err)
+		return exitUsage
+	}
+	includePatterns, err := compilePatterns("include", include)
+	if err != nil {
+		fmt.Fprintf(stderr, "crawl: %v\n", err)
+		return exitUsage
+	}
+	excludePatterns, err := compilePatterns("exclude", exclude)
+	if err != nil {
+		fmt.Fprintf(stderr, "crawl: %v\n", err)
+		return exitUsage
+	}
+	common.setupLogging(stderr)
+
+	c := crawler.New(
+		crawler.WithAnalyzerOptions(common.analyzerOptions()...),
+		crawler.WithMaxDepth(*maxDepth),
+		crawler.WithMaxPages(*maxPages),
+		crawler.WithConcurrency(*pageConcurrency),
+		crawler.WithPerHostLimit(*perHost),
+		crawler.WithInclude(includePatterns...),
+		crawler.WithExclude(excludePatterns...),
+		crawler.WithSitemapCheck(*common.checkSitemap),
+	)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	report, err := c.Crawl(ctx, flags.Arg(0))
+	if report == nil {
+		_, apiErr := analysisErrorResponse(err)
+		fmt.Fprintf(stderr, "crawl: %s\n", apiErr.Message)
+		return exitFailure
+	}
+
+	exitCode := exitOK
+	var failures []crawlPageFailures
+	for i, page := range report.Pages {
+		var pageFailures []cliFailure
+		if page.Result != nil {
+			pageFailures = evaluateConditions(page.Result, conditions)
+		} else if i == 0 {
+			exitCode = exitFailure // the seed itself could not be analyzed
+		}
+		if len(pageFailures) > 0 {
+			failures = append(failures, crawlPageFailures{URL: page.URL, Failures: pageFailures})
+		}
+	}
+	if len(failures) > 0 && exitCode == exitOK {
+		exitCode = exitChecksFailed
+	}
+	if err != nil && exitCode == exitOK {
+		exitCode = exitFailure // interrupted
+	}
+
+	if *common.format == "json" {
+		if err := writeJSONOutput(stdout, crawlOutput{Report: report, Failures: failures}); err != nil {
+			fmt.Fprintf(stderr, "crawl: writing JSON: %v\n", err)
+			return exitFailure
+		}
+	} else {
+		writeCrawlTextReport(stdout, report, failures)
+	}
+	return exitCode
+}
+
+// writeCrawlTextReport prints a human-readable summary of a site report
+func writeCrawlTextReport(w io.Writer, report *crawler.SiteReport, failures []crawlPageFailures) {
+	fmt.Fprintf(w, "Site: %s\n", report.SeedURL)
+	truncated := ""
+	if report.Truncated {
+		truncated = " (page limit reached)"
+	}
+	fmt.Fprintf(w, "  Pages:         %d analyzed, %d failed, %d skipped (not HTML or robots.txt)%s\n",
+		report.PagesAnalyzed, report.PagesFailed, report.PagesSkipped, truncated)
+	fmt.Fprintf(w, "  Links:         %d internal, %d external, %d unique inaccessible\n",
+		report.InternalLinksCount, report.ExternalLinksCount, len(report.InaccessibleLinks))
+	for _, issue := range report.InaccessibleLinks {
+		status := string(issue.ErrorClass)
+		if issue.StatusCode != 0 {
+			status = fmt.Sprintf("%d %s", issue.StatusCode, issue.ErrorClass)
+		}
+		fmt.Fprintf(w, "    - %s [%s] found on %d page(s)\n", issue.URL, status, len(issue.FoundOn))
+	}
+	if report.Sitemap != nil {
+		writeSitemapText(w, report.Sitemap)
+		fmt.Fprintf(w, "    %d sitemap URL(s) not reached by the crawl\n", len(report.SitemapURLsNotCrawled))
+		for _, u := range report.SitemapURLsNotCrawled {
+			fmt.Fprintf(w, "    - %s [not crawled]\n", u)
+		}
+	}
+
+	fmt.Fprintln(w, "  Pages crawled:")
+	for _, page := range report.Pages {
+		switch {
+		case page.Result != nil:
+			fmt.Fprintf(w, "    [%d] %s  %q\n", page.Depth, page.URL, page.Result.PageTitle)
+		default:
+			fmt.Fprintf(w, "    [%d] %s  ERROR [%s]: %s\n", page.Depth, page.URL, page.ErrorCategory, page.Error)
+		}
+	}
+
+	for _, failure := range failures {
+		for _, f := range failure.Failures {
+			fmt.Fprintf(w, "  FAIL %s %s: %s\n", failure.URL, f.Condition, f.Message)
+		}
+	}
+	fmt.Fprintln(w)
+}
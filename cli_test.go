@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRunAnalyze(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/good":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<!DOCTYPE html><html><head><title>Good</title></head><body><h1>Hi</h1><a href="/good">self</a></body></html>`)
+		case "/untitled":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<!DOCTYPE html><html><body><a href="/missing">broken</a></body></html>`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	testCases := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantOutput []string
+	}{
+		{"Passing", []string{server.URL + "/good"}, exitOK, []string{"Title:         Good", "Headings:      h1=1", "1 internal, 0 external, 0 inaccessible"}},
+		{"FailedChecks", []string{server.URL + "/untitled"}, exitChecksFailed, []string{"FAIL inaccessible-links: 1 inaccessible link(s)", "FAIL missing-title"}},
+		{"ChecksDisabled", []string{"-fail-on", "none", server.URL + "/untitled"}, exitOK, []string{"1 inaccessible"}},
+		{"CustomCondition", []string{"-fail-on", "missing-h1", server.URL + "/good", server.URL + "/untitled"}, exitChecksFailed, []string{"FAIL missing-h1"}},
+		{"AnalysisError", []string{server.URL + "/good", server.URL + "/nowhere"}, exitFailure, []string{"ERROR [http_status]"}},
+		{"InvalidURL", []string{"not-a-url"}, exitFailure, []string{"ERROR [invalid_url]"}},
+		{"NoURL", nil, exitUsage, nil},
+		{"UnknownCondition", []string{"-fail-on", "bogus", server.URL}, exitUsage, nil},
+		{"UnknownFormat", []string{"-format", "xml", server.URL}, exitUsage, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := run(append([]string{"analyze"}, tc.args...), &stdout, &stderr)
+			if code != tc.wantCode {
+				t.Fatalf("Expected exit code %d, got %d. Stdout: %s Stderr: %s", tc.wantCode, code, stdout.String(), stderr.String())
+			}
+			for _, want := range tc.wantOutput {
+				if !strings.Contains(stdout.String(), want) {
+					t.Errorf("Expected output to contain %q, got:\n%s", want, stdout.String())
+				}
+			}
+		})
+	}
+
+	t.Run("JSONFormat", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		code := run([]string{"analyze", "-format", "json", server.URL + "/good", server.URL + "/untitled"}, &stdout, &stderr)
+		if code != exitChecksFailed {
+			t.Fatalf("Expected exit code %d, got %d. Stderr: %s", exitChecksFailed, code, stderr.String())
+		}
+		var reports []cliReport
+		if err := json.Unmarshal(stdout.Bytes(), &reports); err != nil {
+			t.Fatalf("Could not decode JSON output: %v\n%s", err, stdout.String())
+		}
+		if len(reports) != 2 {
+			t.Fatalf("Expected 2 reports, got %d", len(reports))
+		}
+		if reports[0].Result == nil || reports[0].Result.PageTitle != "Good" || len(reports[0].Failures) != 0 {
+			t.Errorf("Unexpected first report: %+v", reports[0])
+		}
+		if len(reports[1].Failures) != 2 {
+			t.Errorf("Expected 2 failures for the second report, got %+v", reports[1].Failures)
+		}
+	})
+}
+
+func TestRunUnknownCommand(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"frobnicate"}, &stdout, &stderr); code != exitUsage {
+		t.Errorf("Expected exit code %d for unknown command, got %d", exitUsage, code)
+	}
+	if !strings.Contains(stderr.String(), "Usage:") {
+		t.Errorf("Expected usage text on stderr, got %q", stderr.String())
+	}
+}
+
+func TestRunCrawl(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<html><head><title>Home</title></head><body><a href="/docs">Docs</a><a href="/blog">Blog</a></body></html>`)
+		case "/docs":
+			fmt.Fprint(w, `<html><head><title>Docs</title></head><body><a href="/">Home</a></body></html>`)
+		case "/blog":
+			fmt.Fprint(w, `<html><body>untitled</body></html>`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"crawl", "-check-links=false", server.URL}, &stdout, &stderr)
+	if code != exitChecksFailed {
+		t.Fatalf("Expected exit code %d, got %d. Stdout: %s Stderr: %s", exitChecksFailed, code, stdout.String(), stderr.String())
+	}
+	for _, want := range []string{"3 analyzed, 0 failed", `"Docs"`, "FAIL " + server.URL + "/blog missing-title"} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, stdout.String())
+		}
+	}
+
+	stdout.Reset()
+	code = run([]string{"crawl", "-check-links=false", "-exclude", "^/blog", "-format", "json", server.URL}, &stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("Expected exit code %d with /blog excluded, got %d. Stderr: %s", exitOK, code, stderr.String())
+	}
+	var output crawlOutput
+	if err := json.Unmarshal(stdout.Bytes(), &output); err != nil {
+		t.Fatalf("Could not decode JSON output: %v\n%s", err, stdout.String())
+	}
+	if output.Report == nil || output.Report.PagesAnalyzed != 2 {
+		t.Errorf("Expected 2 analyzed pages, got %+v", output.Report)
+	}
+
+	if code := run([]string{"crawl", "-exclude", "(", server.URL}, &stdout, &stderr); code != exitUsage {
+		t.Errorf("Expected exit code %d for an invalid pattern, got %d", exitUsage, code)
+	}
+}
@@ -2,4 +2,7 @@ module github.com/tharaka70/web_analyzer
 
 go 1.24.3
 
-require golang.org/x/net v0.40.0
+require (
+	golang.org/x/net v0.40.0
+	golang.org/x/text v0.25.0
+)
@@ -0,0 +1,396 @@
+package analyzer
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Accessibility rule IDs
+const (
+	RuleImageAlt        = "image-alt"         // images without alternative text
+	RuleFormLabel       = "form-label"        // form controls without a label
+	RuleButtonName      = "button-name"       // buttons without an accessible name
+	RuleLinkName        = "link-name"         // links whose content has no accessible name (e.g. an icon)
+	RuleLinkEmpty       = "link-empty"        // links with no content at all
+	RuleHTMLLang        = "html-lang"         // <html> without a lang attribute
+	RuleHeadingOrder    = "heading-order"     // heading levels that skip one or more levels
+	RuleDuplicateID     = "duplicate-id"      // id attributes used more than once
+	RuleARIAAttr        = "aria-valid-attr"   // unknown aria-* attributes
+	RuleARIARole        = "aria-valid-role"   // unknown role values
+	RuleARIARef         = "aria-valid-ref"    // aria-labelledby etc. pointing at missing ids
+	RuleARIAHiddenFocus = "aria-hidden-focus" // focusable elements hidden from assistive technology
+)
+
+// AccessibilityReport lists the accessibility problems found in a document
+type AccessibilityReport struct {
+	Findings []AccessibilityFinding `json:"findings"` // in document order, document-level findings first
+	Errors   int                    `json:"errors"`
+	Warnings int                    `json:"warnings"`
+}
+
+// AccessibilityFinding is one violation of an accessibility rule
+type AccessibilityFinding struct {
+	RuleID   string `json:"rule_id"`
+	Severity string `json:"severity"` // SeverityError or SeverityWarning
+	WCAG     string `json:"wcag"`     // WCAG 2.1 success criterion, e.g. "1.1.1"
+	Message  string `json:"message"`
+	Path     string `json:"path"` // CSS-like selector of the offending element
+}
+
+// a11yRules holds the severity and WCAG reference of each rule
+var a11yRules = map[string]struct{ severity, wcag string }{
+	RuleImageAlt:        {SeverityError, "1.1.1"},
+	RuleFormLabel:       {SeverityError, "1.3.1"},
+	RuleButtonName:      {SeverityError, "4.1.2"},
+	RuleLinkName:        {SeverityError, "2.4.4"},
+	RuleLinkEmpty:       {SeverityError, "2.4.4"},
+	RuleHTMLLang:        {SeverityError, "3.1.1"},
+	RuleHeadingOrder:    {SeverityWarning, "1.3.1"},
+	RuleDuplicateID:     {SeverityWarning, "4.1.1"},
+	RuleARIAAttr:        {SeverityError, "4.1.2"},
+	RuleARIARole:        {SeverityError, "4.1.2"},
+	RuleARIARef:         {SeverityWarning, "1.3.1"},
+	RuleARIAHiddenFocus: {SeverityError, "4.1.2"},
+}
+
+// ariaAttributes are the states and properties defined by WAI-ARIA 1.2
+var ariaAttributes = setOf("aria-activedescendant", "aria-atomic", "aria-autocomplete", "aria-braillelabel",
+	"aria-brailleroledescription", "aria-busy", "aria-checked", "aria-colcount", "aria-colindex", "aria-colindextext",
+	"aria-colspan", "aria-controls", "aria-current", "aria-describedby", "aria-description", "aria-details",
+	"aria-disabled", "aria-dropeffect", "aria-errormessage", "aria-expanded", "aria-flowto", "aria-grabbed",
+	"aria-haspopup", "aria-hidden", "aria-invalid", "aria-keyshortcuts", "aria-label", "aria-labelledby", "aria-level",
+	"aria-live", "aria-modal", "aria-multiline", "aria-multiselectable", "aria-orientation", "aria-owns",
+	"aria-placeholder", "aria-posinset", "aria-pressed", "aria-readonly", "aria-relevant", "aria-required",
+	"aria-roledescription", "aria-rowcount", "aria-rowindex", "aria-rowindextext", "aria-rowspan", "aria-selected",
+	"aria-setsize", "aria-sort", "aria-valuemax", "aria-valuemin", "aria-valuenow", "aria-valuetext")
+
+// ariaRoles are the non-abstract roles defined by WAI-ARIA 1.2, plus the DPUB and graphics roles in common use
+var ariaRoles = setOf("alert", "alertdialog", "application", "article", "banner", "blockquote", "button", "caption",
+	"cell", "checkbox", "code", "columnheader", "combobox", "complementary", "contentinfo", "definition", "deletion",
+	"dialog", "directory", "document", "emphasis", "feed", "figure", "form", "generic", "grid", "gridcell", "group",
+	"heading", "img", "insertion", "link", "list", "listbox", "listitem", "log", "main", "mark", "marquee", "math",
+	"menu", "menubar", "menuitem", "menuitemcheckbox", "menuitemradio", "meter", "navigation", "none", "note",
+	"option", "paragraph", "presentation", "progressbar", "radio", "radiogroup", "region", "row", "rowgroup",
+	"rowheader", "scrollbar", "search", "searchbox", "separator", "slider", "spinbutton", "status", "strong",
+	"subscript", "superscript", "switch", "tab", "table", "tablist", "tabpanel", "term", "textbox", "time", "timer",
+	"toolbar", "tooltip", "tree", "treegrid", "treeitem",
+	"doc-abstract", "doc-acknowledgments", "doc-afterword", "doc-appendix", "doc-backlink", "doc-biblioentry",
+	"doc-bibliography", "doc-biblioref", "doc-chapter", "doc-colophon", "doc-conclusion", "doc-cover", "doc-credit",
+	"doc-credits", "doc-dedication", "doc-endnote", "doc-endnotes", "doc-epigraph", "doc-epilogue", "doc-errata",
+	"doc-example", "doc-footnote", "doc-foreword", "doc-glossary", "doc-glossref", "doc-index", "doc-introduction",
+	"doc-noteref", "doc-notice", "doc-pagebreak", "doc-pagelist", "doc-part", "doc-preface", "doc-prologue",
+	"doc-pullquote", "doc-qna", "doc-subtitle", "doc-tip", "doc-toc", "graphics-document", "graphics-object",
+	"graphics-symbol")
+
+// ARIA attributes whose value is a list of element ids
+var ariaIDRefAttributes = []string{"aria-labelledby", "aria-describedby", "aria-controls", "aria-owns", "aria-flowto", "aria-details", "aria-errormessage", "aria-activedescendant"}
+
+func setOf(items ...string) map[string]bool {
+	set := make(map[string]bool, len(items))
+	for _, item := range items {
+		set[item] = true
+	}
+	return set
+}
+
+// a11yAudit holds the state of one accessibility audit
+type a11yAudit struct {
+	ids       map[string][]*html.Node // elements by id, in document order
+	labelFor  map[string]bool         // ids referenced by <label for>
+	report    AccessibilityReport
+	lastLevel int // level of the previous heading, 0 before the first
+}
+
+// auditAccessibility runs the accessibility rules over doc
+func auditAccessibility(doc *html.Node) AccessibilityReport {
+	audit := &a11yAudit{ids: map[string][]*html.Node{}, labelFor: map[string]bool{}}
+	audit.report.Findings = []AccessibilityFinding{}
+
+	// First pass: ids and label targets, which rules refer to from anywhere in the document
+	var index func(*html.Node)
+	index = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			if id, ok := attrLookup(n, "id"); ok && id != "" {
+				audit.ids[id] = append(audit.ids[id], n)
+			}
+			if n.DataAtom == atom.Label {
+				if target := attrValue(n, "for"); target != "" {
+					audit.labelFor[target] = true
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			index(c)
+		}
+	}
+	index(doc)
+
+	// Document-level rules
+	if root := findElement(doc, atom.Html); root != nil {
+		if strings.TrimSpace(attrValue(root, "lang")) == "" {
+			audit.add(RuleHTMLLang, root, "<html> element has no lang attribute")
+		}
+	}
+	ids := make([]string, 0, len(audit.ids))
+	for id := range audit.ids {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if nodes := audit.ids[id]; len(nodes) > 1 {
+			for _, n := range nodes[1:] {
+				audit.add(RuleDuplicateID, n, fmt.Sprintf("id %q is used %d times", id, len(nodes)))
+			}
+		}
+	}
+
+	var walk func(n *html.Node, inLabel bool)
+	walk = func(n *html.Node, inLabel bool) {
+		if n.Type == html.ElementNode {
+			audit.checkElement(n, inLabel)
+			inLabel = inLabel || n.DataAtom == atom.Label
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c, inLabel)
+		}
+	}
+	walk(doc, false)
+
+	for _, f := range audit.report.Findings {
+		if f.Severity == SeverityError {
+			audit.report.Errors++
+		} else {
+			audit.report.Warnings++
+		}
+	}
+	return audit.report
+}
+
+// add records a finding for rule on n
+func (audit *a11yAudit) add(rule string, n *html.Node, msg string) {
+	r := a11yRules[rule]
+	audit.report.Findings = append(audit.report.Findings, AccessibilityFinding{
+		RuleID: rule, Severity: r.severity, WCAG: r.wcag, Message: msg, Path: cssPath(n),
+	})
+}
+
+// checkElement applies the element-level rules to n. inLabel is set inside a <label>.
+func (audit *a11yAudit) checkElement(n *html.Node, inLabel bool) {
+	switch n.DataAtom {
+	case atom.Img, atom.Area:
+		if _, ok := attrLookup(n, "alt"); !ok && !audit.hasARIAName(n) && !isPresentational(n) {
+			audit.add(RuleImageAlt, n, fmt.Sprintf("<%s> has no alt attribute", n.Data))
+		}
+	case atom.Input:
+		switch strings.ToLower(attrValue(n, "type")) {
+		case "hidden", "submit", "reset":
+			// hidden inputs are not rendered; submit and reset buttons have a default label
+		case "image":
+			if strings.TrimSpace(attrValue(n, "alt")) == "" && !audit.hasARIAName(n) {
+				audit.add(RuleImageAlt, n, `<input type="image"> has no alt attribute`)
+			}
+		case "button":
+			if strings.TrimSpace(attrValue(n, "value")) == "" && !audit.hasARIAName(n) {
+				audit.add(RuleButtonName, n, `<input type="button"> has no value or ARIA label`)
+			}
+		default:
+			audit.checkLabel(n, inLabel)
+		}
+	case atom.Select, atom.Textarea:
+		audit.checkLabel(n, inLabel)
+	case atom.Button:
+		if !audit.hasAccessibleName(n) {
+			audit.add(RuleButtonName, n, "button has no accessible name")
+		}
+	case atom.A:
+		if _, ok := attrLookup(n, "href"); ok && !audit.hasAccessibleName(n) {
+			if hasContent(n) {
+				audit.add(RuleLinkName, n, "link content has no accessible name (e.g. an image without alt text)")
+			} else {
+				audit.add(RuleLinkEmpty, n, "link has no content")
+			}
+		}
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		level := int(n.Data[1] - '0')
+		if audit.lastLevel > 0 && level > audit.lastLevel+1 {
+			audit.add(RuleHeadingOrder, n, fmt.Sprintf("heading level jumps from h%d to h%d", audit.lastLevel, level))
+		}
+		audit.lastLevel = level
+	}
+	audit.checkARIA(n)
+}
+
+// checkLabel reports a form control that has no label of any kind
+func (audit *a11yAudit) checkLabel(n *html.Node, inLabel bool) {
+	if inLabel || audit.labelFor[attrValue(n, "id")] || audit.hasARIAName(n) || strings.TrimSpace(attrValue(n, "title")) != "" {
+		return
+	}
+	audit.add(RuleFormLabel, n, fmt.Sprintf("<%s> has no associated label", n.Data))
+}
+
+// checkARIA applies the ARIA rules to the attributes of n
+func (audit *a11yAudit) checkARIA(n *html.Node) {
+	for _, attr := range n.Attr {
+		if strings.HasPrefix(attr.Key, "aria-") && !ariaAttributes[attr.Key] {
+			audit.add(RuleARIAAttr, n, fmt.Sprintf("unknown ARIA attribute %s", attr.Key))
+		}
+	}
+	if role, ok := attrLookup(n, "role"); ok {
+		// A role attribute may list fallbacks; the first one the browser knows is used
+		roles := strings.Fields(strings.ToLower(role))
+		valid := false
+		for _, r := range roles {
+			valid = valid || ariaRoles[r]
+		}
+		if !valid {
+			audit.add(RuleARIARole, n, fmt.Sprintf("role %q is not a valid ARIA role", role))
+		}
+	}
+	for _, key := range ariaIDRefAttributes {
+		for _, id := range strings.Fields(attrValue(n, key)) {
+			if len(audit.ids[id]) == 0 {
+				audit.add(RuleARIARef, n, fmt.Sprintf("%s refers to missing id %q", key, id))
+			}
+		}
+	}
+	if strings.EqualFold(strings.TrimSpace(attrValue(n, "aria-hidden")), "true") && isFocusable(n) {
+		audit.add(RuleARIAHiddenFocus, n, `focusable element has aria-hidden="true"`)
+	}
+}
+
+// hasARIAName reports whether n is named through aria-label or a resolvable aria-labelledby
+func (audit *a11yAudit) hasARIAName(n *html.Node) bool {
+	if strings.TrimSpace(attrValue(n, "aria-label")) != "" {
+		return true
+	}
+	for _, id := range strings.Fields(attrValue(n, "aria-labelledby")) {
+		if nodes := audit.ids[id]; len(nodes) > 0 && textWithAlt(nodes[0]) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// hasAccessibleName approximates the accessible name computation for buttons and links:
+// ARIA labels, then the content including image alt text, then the title attribute
+func (audit *a11yAudit) hasAccessibleName(n *html.Node) bool {
+	return audit.hasARIAName(n) || textWithAlt(n) != "" || strings.TrimSpace(attrValue(n, "title")) != ""
+}
+
+// textWithAlt returns the text content of n, counting the alt text of images and the
+// aria-label of descendants, with whitespace normalized
+func textWithAlt(n *html.Node) string {
+	var parts []string
+	var f func(*html.Node)
+	f = func(c *html.Node) {
+		switch c.Type {
+		case html.TextNode:
+			parts = append(parts, c.Data)
+		case html.ElementNode:
+			if c != n {
+				if strings.EqualFold(attrValue(c, "aria-hidden"), "true") {
+					return
+				}
+				if label := attrValue(c, "aria-label"); label != "" {
+					parts = append(parts, label)
+					return
+				}
+			}
+			if c.DataAtom == atom.Img {
+				parts = append(parts, attrValue(c, "alt"))
+			}
+		}
+		for child := c.FirstChild; child != nil; child = child.NextSibling {
+			f(child)
+		}
+	}
+	f(n)
+	return strings.Join(strings.Fields(strings.Join(parts, " ")), " ")
+}
+
+// hasContent reports whether n contains any element or non-blank text
+func hasContent(n *html.Node) bool {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode || (c.Type == html.TextNode && strings.TrimSpace(c.Data) != "") {
+			return true
+		}
+	}
+	return false
+}
+
+// isPresentational reports whether n is explicitly removed from the accessibility tree
+func isPresentational(n *html.Node) bool {
+	role := strings.ToLower(strings.TrimSpace(attrValue(n, "role")))
+	return role == "presentation" || role == "none" || strings.EqualFold(attrValue(n, "aria-hidden"), "true")
+}
+
+// isFocusable reports whether n can receive keyboard focus
+func isFocusable(n *html.Node) bool {
+	if tabindex, ok := attrLookup(n, "tabindex"); ok {
+		if i, err := strconv.Atoi(strings.TrimSpace(tabindex)); err == nil {
+			return i >= 0
+		}
+	}
+	if _, disabled := attrLookup(n, "disabled"); disabled {
+		return false
+	}
+	switch n.DataAtom {
+	case atom.A, atom.Area:
+		_, ok := attrLookup(n, "href")
+		return ok
+	case atom.Button, atom.Select, atom.Textarea, atom.Iframe:
+		return true
+	case atom.Input:
+		return !strings.EqualFold(attrValue(n, "type"), "hidden")
+	}
+	return false
+}
+
+// findElement returns the first element of type a in document order
+func findElement(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findElement(c, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// cssPath returns a CSS-like selector for n, e.g. "html > body > div#main > p:nth-of-type(2) > img".
+// Ids are included for readability; :nth-of-type is only added where a tag repeats among siblings.
+func cssPath(n *html.Node) string {
+	var segments []string
+	for e := n; e != nil && e.Type == html.ElementNode; e = e.Parent {
+		segment := e.Data
+		if id := attrValue(e, "id"); id != "" {
+			segment += "#" + id
+		}
+		index, count := 0, 0
+		if e.Parent != nil {
+			for s := e.Parent.FirstChild; s != nil; s = s.NextSibling {
+				if s.Type == html.ElementNode && s.Data == e.Data {
+					count++
+					if s == e {
+						index = count
+					}
+				}
+			}
+		}
+		if count > 1 {
+			segment += fmt.Sprintf(":nth-of-type(%d)", index)
+		}
+		segments = append(segments, segment)
+	}
+	for i, j := 0, len(segments)-1; i < j; i, j = i+1, j-1 {
+		segments[i], segments[j] = segments[j], segments[i]
+	}
+	return strings.Join(segments, " > ")
+}
@@ -0,0 +1,84 @@
+package analyzer
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestAuditAccessibility(t *testing.T) {
+	testCases := []struct {
+		name string
+		page string
+		want []string // "rule_id path"
+	}{
+		{
+			name: "Clean",
+			page: `<html lang="en"><body>
+				<h1>Title</h1><h2>Section</h2>
+				<img src="a.png" alt=""><img src="b.png" role="presentation">
+				<label>Name <input name="name"></label>
+				<label for="email">Email</label><input id="email" type="email">
+				<input type="search" aria-label="Search"><input type="hidden" name="token"><input type="submit">
+				<button><img src="x.png" alt="Close"></button>
+				<a href="/a">About</a><a href="/b" title="Home"><svg></svg></a>
+				<div role="navigation" aria-labelledby="email"></div>
+			</body></html>`,
+		},
+		{
+			name: "Violations",
+			page: `<html><body>
+				<h1>Title</h1><h3 id="x">Skipped</h3>
+				<p><img src="a.png"></p>
+				<form><input name="q" placeholder="Search"><select></select><input type="image" src="go.png"></form>
+				<button></button><input type="button">
+				<a href="/a"><img src="icon.png"></a><a href="/b"></a><a name="anchor"></a>
+				<div id="x" role="buton" aria-labeledby="y"></div>
+				<span aria-describedby="missing"></span>
+				<a href="/c" aria-hidden="true">Hidden</a>
+			</body></html>`,
+			want: []string{
+				"html-lang html",
+				"duplicate-id html > body > div#x",
+				"heading-order html > body > h3#x",
+				"image-alt html > body > p > img",
+				"form-label html > body > form > input:nth-of-type(1)",
+				"form-label html > body > form > select",
+				"image-alt html > body > form > input:nth-of-type(2)",
+				"button-name html > body > button",
+				"button-name html > body > input",
+				"link-name html > body > a:nth-of-type(1)",
+				"image-alt html > body > a:nth-of-type(1) > img",
+				"link-empty html > body > a:nth-of-type(2)",
+				"aria-valid-attr html > body > div#x",
+				"aria-valid-role html > body > div#x",
+				"aria-valid-ref html > body > span",
+				"aria-hidden-focus html > body > a:nth-of-type(4)",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := html.Parse(strings.NewReader(tc.page))
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			report := auditAccessibility(doc)
+			var got []string
+			for _, f := range report.Findings {
+				got = append(got, f.RuleID+" "+f.Path)
+				if f.WCAG == "" || f.Severity == "" || f.Message == "" {
+					t.Errorf("Finding %+v lacks a WCAG reference, severity or message", f)
+				}
+			}
+			if strings.Join(got, "\n") != strings.Join(tc.want, "\n") {
+				t.Errorf("Expected findings:\n%s\ngot:\n%s", strings.Join(tc.want, "\n"), strings.Join(got, "\n"))
+			}
+			if report.Errors+report.Warnings != len(report.Findings) {
+				t.Errorf("Expected %d errors and warnings in total, got %d + %d", len(report.Findings), report.Errors, report.Warnings)
+			}
+		})
+	}
+}
@@ -1,12 +1,17 @@
 package analyzer
 
 import (
+	"compress/gzip"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"net"
 	"net/http"
 	"net/url"
+	"slices"
 	"strings"
-	"sync" // For WaitGroup concurrent link checks
 	"time"
 
 	"golang.org/x/net/html"
@@ -14,37 +19,223 @@ import (
 )
 
 // AnalysisResult holds all the extracted information
+// JSON field names are part of the public API (/api/v1) and must stay stable.
 type AnalysisResult struct {
-	HTMLVersion        string
-	PageTitle          string
-	HeadingsCount      map[string]int // Map with header value and count {"h1": 2, "h2": 5}
-	InternalLinksCount int
-	ExternalLinksCount int
-	InaccessibleLinks  []string // store URLs of inaccessible links
-	ContainsLoginForm  bool
+	FinalURL           string               `json:"final_url"` // URL of the page after following redirects
+	BaseURL            string               `json:"base_url"`  // URL relative links were resolved against
+	Redirects          RedirectReport       `json:"redirects"` // how the requested URL led to FinalURL
+	HTMLVersion        string               `json:"html_version"`
+	Encoding           EncodingReport       `json:"encoding"` // character encoding the page was decoded from
+	PageTitle          string               `json:"page_title"`
+	HeadingsCount      map[string]int       `json:"headings_count"` // Map with header value and count {"h1": 2, "h2": 5}, derived from Outline
+	Outline            DocumentOutline      `json:"outline"`
+	InternalLinksCount int                  `json:"internal_links_count"`
+	ExternalLinksCount int                  `json:"external_links_count"`
+	LinkPolicy         LinkPolicy           `json:"link_policy"`        // how links were classified as internal or external
+	LinkDomains        []DomainLinks        `json:"link_domains"`       // Links grouped by destination host, most linked first
+	InaccessibleLinks  []LinkCheckResult    `json:"inaccessible_links"` // details of every link that failed the accessibility check
+	SkippedLinks       []LinkCheckResult    `json:"skipped_links"`      // links not checked because robots.txt disallows them
+	RobotsPolicy       RobotsPolicy         `json:"robots_policy"`
+	ContainsLoginForm  bool                 `json:"contains_login_form"` // true if any form in Forms is classified as a login form
+	Forms              FormsReport          `json:"forms"`
+	Links              []PageLink           `json:"links"`           // every counted link, in document order
+	Resources          []Resource           `json:"resources"`       // scripts, stylesheets, images and other subresources, in document order
+	ResourceCounts     map[ResourceKind]int `json:"resource_counts"` // number of Resources per kind
+	Metadata           PageMetadata         `json:"metadata"`
+	Social             SocialMetadata       `json:"social"`
+	StructuredData     StructuredData       `json:"structured_data"`
+	Accessibility      AccessibilityReport  `json:"accessibility"`
+	Security           SecurityReport       `json:"security"`
+	TLS                *TLSReport           `json:"tls,omitempty"` // set for pages fetched over HTTPS
+	MixedContent       []MixedContent       `json:"mixed_content"` // plain HTTP resources of an HTTPS page
+	Performance        PerformanceReport    `json:"performance"`
+	Sitemap            *SitemapReport       `json:"sitemap,omitempty"` // set when the sitemap check is enabled
 }
 
+// PageLink is a link found in the analyzed document
+type PageLink struct {
+	URL      string `json:"url"` // absolute URL
+	Tag      string `json:"tag"` // element the link came from: "a" or "link", or e.g. "img" for a subresource
+	Text     string `json:"text,omitempty"`
+	Path     string `json:"path,omitempty"` // CSS-like selector of the element
+	Internal bool   `json:"internal"`
+}
+
+// IsInternalLink reports whether link points to the same site as base under DefaultLinkPolicy:
+// both host and scheme must match, and the port unless it is the scheme's default.
+func IsInternalLink(base, link *url.URL) bool {
+	return DefaultLinkPolicy.Internal(base, link)
+}
+
+// ErrorCategory classifies an AnalysisError so callers can react without parsing messages
+type ErrorCategory string
+
+const (
+	ErrorCategoryInvalidURL  ErrorCategory = "invalid_url"       // the submitted URL was rejected before fetching
+	ErrorCategoryFetchFailed ErrorCategory = "fetch_failed"      // network-level failure fetching the page
+	ErrorCategoryTimeout     ErrorCategory = "timeout"           // the fetch or analysis ran out of time
+	ErrorCategoryCancelled   ErrorCategory = "cancelled"         // the caller cancelled the analysis
+	ErrorCategoryHTTPStatus  ErrorCategory = "http_status"       // the page answered with a 4xx/5xx status
+	ErrorCategoryNotHTML     ErrorCategory = "not_html"          // the page is not text/html
+	ErrorCategoryParse       ErrorCategory = "parse_error"       // the page could not be parsed
+	ErrorCategoryRobots      ErrorCategory = "robots_disallowed" // robots.txt forbids fetching the page
+	ErrorCategoryRedirect    ErrorCategory = "redirect_failed"   // the page redirects in a loop, too often or to an invalid URL
+)
+
 // Custom error type to include status code
 type AnalysisError struct {
 	Message    string
 	StatusCode int
+	Category   ErrorCategory
+	Err        error // underlying cause, if any (e.g. context.DeadlineExceeded)
 }
 
 func (e *AnalysisError) Error() string {
 	return e.Message
 }
 
-// FetchAndAnalyze performs the core analysis
+// Unwrap exposes the underlying cause so callers can use errors.Is / errors.As
+func (e *AnalysisError) Unwrap() error {
+	return e.Err
+}
+
+// ValidateURL checks that a user-submitted URL is a non-empty absolute HTTP/HTTPS URL with a host.
+// Failures are returned as *AnalysisError with ErrorCategoryInvalidURL and a user-friendly message.
+func ValidateURL(rawURL string) (*url.URL, error) {
+	if rawURL == "" {
+		return nil, &AnalysisError{Message: "URL field cannot be empty.", Category: ErrorCategoryInvalidURL}
+	}
+	parsedURL, err := url.ParseRequestURI(rawURL)
+	if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") {
+		return nil, &AnalysisError{Message: fmt.Sprintf("Invalid URL: %q. Must be a valid HTTP/HTTPS URL.", rawURL), Category: ErrorCategoryInvalidURL, Err: err}
+	}
+	if parsedURL.Host == "" {
+		return nil, &AnalysisError{Message: fmt.Sprintf("Invalid URL: %q. URL must include a host (e.g., example.com).", rawURL), Category: ErrorCategoryInvalidURL}
+	}
+	return parsedURL, nil
+}
+
+// fetchErrorCategory distinguishes timeouts and cancellation from other fetch failures
+func fetchErrorCategory(err error) ErrorCategory {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorCategoryTimeout
+	case errors.Is(err, context.Canceled):
+		return ErrorCategoryCancelled
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrorCategoryTimeout
+	}
+	return ErrorCategoryFetchFailed
+}
+
+// Analyzer fetches and analyzes web pages. Create one with New; the zero value is not usable.
+// An Analyzer is safe for concurrent use by multiple goroutines.
+type Analyzer struct {
+	client             *http.Client
+	fetchTimeout       time.Duration // deadline for fetching and parsing the page itself
+	linkTimeout        time.Duration // deadline for each individual link check
+	userAgent          string
+	linkConcurrency    int
+	checkLinks         bool
+	checkSitemaps      bool
+	checkPerformance   bool // probe every resource for its transfer size
+	performanceBudget  PerformanceBudget
+	detectLoginForms   bool
+	robotsPolicy       RobotsPolicy
+	certExpiryWarning  time.Duration // TLS certificates expiring sooner than this get a warning
+	maxRedirects       int           // redirects followed for the page itself
+	linkPolicy         LinkPolicy
+	ignoredQueryParams []string // query parameters that don't make links different, e.g. "utm_*"
+	hostConcurrency    int      // link checks in flight per host
+	hostRate           float64  // link check requests per second per host; 0 for no limit
+	hostBurst          int
+	maxRetries         int           // retries of a link check failing with a transient error
+	retryBackoff       time.Duration // wait before the first retry, doubled for each further one
+	sharedThrottle     *HostThrottle // limits shared with other Analyzers, on top of the analyzer's own
+	throttle           *HostThrottle
+	robots             *RobotsCache
+	progress           ProgressFunc
+}
+
+// New creates an Analyzer with sensible defaults, overridden by the given options
+func New(opts ...Option) *Analyzer {
+	a := &Analyzer{
+		client:            &http.Client{},
+		fetchTimeout:      DefaultFetchTimeout,
+		linkTimeout:       DefaultLinkTimeout,
+		userAgent:         DefaultUserAgent,
+		linkConcurrency:   DefaultLinkConcurrency,
+		checkLinks:        true,
+		detectLoginForms:  true,
+		robotsPolicy:      RobotsObey,
+		performanceBudget: DefaultPerformanceBudget,
+		certExpiryWarning: DefaultCertExpiryWarning,
+		maxRedirects:      DefaultMaxRedirects,
+		linkPolicy:        DefaultLinkPolicy,
+		hostConcurrency:   DefaultHostConcurrency,
+		hostRate:          DefaultHostRate,
+		hostBurst:         DefaultHostBurst,
+		maxRetries:        DefaultMaxRetries,
+		retryBackoff:      DefaultRetryBackoff,
+		robots:            NewRobotsCache(),
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	a.throttle = NewHostThrottle(a.hostConcurrency, a.hostRate, a.hostBurst)
+	a.throttle.parent = a.sharedThrottle
+	return a
+}
+
+// FetchAndAnalyze performs the core analysis using a default Analyzer and no cancellation.
+// It is kept for existing callers; new code should use New(...).Analyze(ctx, url).
 func FetchAndAnalyze(pageURL string) (*AnalysisResult, error) {
+	return New().Analyze(context.Background(), pageURL)
+}
+
+// withTimeout derives a context with the given timeout, or just a cancelable one if d <= 0
+func withTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+// Analyze fetches pageURL and performs the core analysis. Cancelling ctx aborts the page
+// fetch as well as any link checks still in flight.
+func (a *Analyzer) Analyze(ctx context.Context, pageURL string) (*AnalysisResult, error) {
 	slog.Info("Attempting to fetch URL", "url", pageURL)
-	resp, err := http.Get(pageURL)
+	a.reportProgress(Progress{Phase: PhaseFetch})
+
+	// The fetch timeout covers the request and reading/parsing the body, but not link checks
+	fetchCtx, cancelFetch := withTimeout(ctx, a.fetchTimeout)
+	defer cancelFetch()
+
+	if allowed, err := a.robotsAllowed(fetchCtx, pageURL); err != nil {
+		slog.Error("Gave up waiting for robots.txt", "url", pageURL, "error", err)
+		return nil, &AnalysisError{Message: fmt.Sprintf("Failed to fetch URL: %v", err), StatusCode: 0, Category: fetchErrorCategory(err), Err: err}
+	} else if !allowed {
+		slog.Warn("robots.txt disallows fetching URL", "url", pageURL, "user_agent", a.userAgent)
+		return nil, &AnalysisError{Message: "robots.txt disallows fetching this URL for " + userAgentToken(a.userAgent), StatusCode: 0, Category: ErrorCategoryRobots}
+	}
+
+	trace := &transferTrace{}
+	start := time.Now()
+	resp, redirects, err := a.fetchPage(trace.withTrace(fetchCtx), pageURL)
 	if err != nil {
+		var analysisErr *AnalysisError
+		if errors.As(err, &analysisErr) {
+			slog.Error("Failed to fetch URL", "url", pageURL, "error", err, "hops", len(redirects.Hops))
+			return nil, analysisErr
+		}
 		if urlErr, ok := err.(*url.Error); ok {
 			slog.Error("Network error fetching URL", "url", pageURL, "error", urlErr)
-			return nil, &AnalysisError{Message: fmt.Sprintf("Failed to fetch URL: %v", urlErr), StatusCode: 0}
+			return nil, &AnalysisError{Message: fmt.Sprintf("Failed to fetch URL: %v", urlErr), StatusCode: 0, Category: fetchErrorCategory(urlErr.Err), Err: urlErr.Err}
 		}
 		slog.Error("Unknown error fetching URL", "url", pageURL, "error", err)
-		return nil, &AnalysisError{Message: fmt.Sprintf("Failed to fetch URL: %v", err), StatusCode: 0}
+		return nil, &AnalysisError{Message: fmt.Sprintf("Failed to fetch URL: %v", err), StatusCode: 0, Category: fetchErrorCategory(err), Err: err}
 	}
 	defer resp.Body.Close()
 
@@ -55,6 +246,7 @@ func FetchAndAnalyze(pageURL string) (*AnalysisResult, error) {
 		return nil, &AnalysisError{
 			Message:    fmt.Sprintf("URL returned HTTP error: %s", resp.Status),
 			StatusCode: resp.StatusCode,
+			Category:   ErrorCategoryHTTPStatus,
 		}
 	}
 
@@ -64,27 +256,72 @@ func FetchAndAnalyze(pageURL string) (*AnalysisResult, error) {
 		return nil, &AnalysisError{
 			Message:    fmt.Sprintf("URL is not an HTML page. Content-Type: %s", contentType),
 			StatusCode: resp.StatusCode,
+			Category:   ErrorCategoryNotHTML,
 		}
 	}
 
-	doc, err := html.Parse(resp.Body)
+	a.reportProgress(Progress{Phase: PhaseParse})
+	ttfb := trace.since(start)
+	counter := &countingReader{r: resp.Body}
+	var body io.Reader = counter
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		gz, gzErr := gzip.NewReader(counter)
+		if gzErr != nil {
+			slog.Error("Failed to decompress page", "url", pageURL, "error", gzErr)
+			return nil, &AnalysisError{Message: fmt.Sprintf("Failed to decompress page: %v", gzErr), StatusCode: resp.StatusCode, Category: ErrorCategoryParse, Err: gzErr}
+		}
+		defer gz.Close()
+		body = gz
+	}
+	// html.Parse assumes UTF-8, so other encodings are transcoded first
+	body, encodingReport, err := decodeDocument(body, contentType)
+	if err != nil {
+		slog.Error("Failed to read page", "url", pageURL, "error", err)
+		return nil, &AnalysisError{Message: fmt.Sprintf("Failed to read page: %v", err), StatusCode: resp.StatusCode, Category: ErrorCategoryParse, Err: err}
+	}
+	slog.Debug("Determined character encoding", "encoding", encodingReport.Name, "source", encodingReport.Source, "mismatch", encodingReport.Mismatch)
+	doc, err := html.Parse(body)
+	if err == nil {
+		_, err = io.Copy(io.Discard, body) // the parser may stop before EOF; the download time covers the whole body
+	}
 	if err != nil {
 		slog.Error("Failed to parse HTML", "url", pageURL, "error", err)
-		return nil, &AnalysisError{Message: fmt.Sprintf("Failed to parse HTML: %v", err), StatusCode: resp.StatusCode}
+		return nil, &AnalysisError{Message: fmt.Sprintf("Failed to parse HTML: %v", err), StatusCode: resp.StatusCode, Category: ErrorCategoryParse, Err: err}
 	}
+	downloadTime := time.Since(start)
 
 	result := &AnalysisResult{
-		HeadingsCount: make(map[string]int),
+		InaccessibleLinks: []LinkCheckResult{},
+		SkippedLinks:      []LinkCheckResult{},
+		RobotsPolicy:      a.robotsPolicy,
+		Links:             []PageLink{},
+		LinkPolicy:        a.linkPolicy,
+		Redirects:         redirects,
+		Metadata:          PageMetadata{XRobotsTag: xRobotsTag(resp.Header)},
+		Encoding:          encodingReport,
+		Performance:       PerformanceReport{DownloadTime: downloadTime, Resources: []TransferStats{}},
 	}
-
-	var baseDomain *url.URL
-	baseDomain, err = url.Parse(pageURL)
-	if err != nil {
-		slog.Error("Failed to parse baseDomain from pageURL", "pageURL", pageURL, "error", err)
-		return nil, &AnalysisError{Message: fmt.Sprintf("Failed to parse base URL for link analysis: %v", err), StatusCode: resp.StatusCode}
+	result.Performance.Document.fromResponse(resp)
+	result.Performance.Document.TransferSize, result.Performance.Document.SizeMeasured = counter.n, true
+	result.Performance.Document.TTFB = ttfb
+
+	// Links are classified against the page's final URL after redirects, and relative
+	// links are resolved against its <base href> if it declares one
+	baseDomain := resp.Request.URL
+	linkBase := documentBase(doc, baseDomain)
+	result.FinalURL = baseDomain.String()
+	result.BaseURL = linkBase.String()
+	if result.FinalURL != pageURL {
+		slog.Info("Page was redirected", "url", pageURL, "final_url", result.FinalURL, "redirects", redirects.Count())
 	}
+	result.Security = auditSecurity(resp.Header, resp.Cookies(), baseDomain)
+	if resp.TLS != nil {
+		result.TLS = inspectTLS(resp.TLS, baseDomain.Hostname(), a.rootCAs(), a.certExpiryWarning, time.Now())
+		slog.Debug("TLS inspected", "version", result.TLS.Version, "chain_valid", result.TLS.ChainValid, "warnings", len(result.TLS.Warnings))
+	}
+	slog.Debug("Security audit complete", "grade", result.Security.Grade, "findings", len(result.Security.Findings))
 
-	var linksToTest []string
+	outline := newOutlineBuilder()
 
 	// Traverse the HTML tree
 	var f func(*html.Node)
@@ -95,14 +332,23 @@ func FetchAndAnalyze(pageURL string) (*AnalysisResult, error) {
 				result.PageTitle = strings.TrimSpace(n.FirstChild.Data)
 			}
 
-			// --- 2. Headings Count ---
+			// --- 2. Headings Outline ---
 			switch n.DataAtom {
 			case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
-				result.HeadingsCount[n.Data]++
-				slog.Debug("Found heading", "tag", n.Data, "current_count", result.HeadingsCount[n.Data])
+				outline.add(n)
+				slog.Debug("Found heading", "tag", n.Data, "count", len(outline.outline.Headings))
+			}
+
+			// --- 3. Metadata (meta tags, canonical, base) ---
+			switch n.DataAtom {
+			case atom.Meta, atom.Link, atom.Base:
+				collectMetadata(&result.Metadata, n, linkBase)
+			}
+			if n.DataAtom == atom.Meta {
+				collectSocial(&result.Social, n)
 			}
 
-			// --- 3. Links Count (Anchor tags and Link tags) ---
+			// --- 4. Links Count (Anchor tags and Link tags) ---
 			if n.DataAtom == atom.A || n.DataAtom == atom.Link {
 				var hrefAttr string
 
@@ -121,34 +367,28 @@ func FetchAndAnalyze(pageURL string) (*AnalysisResult, error) {
 						strings.HasPrefix(strings.ToLower(hrefAttr), "tel:") {
 						// Skip empty, fragment, javascript, mailto, or tel links
 					} else {
-						absoluteLink, parseErr := baseDomain.Parse(hrefAttr)
+						absoluteLink, parseErr := linkBase.Parse(hrefAttr)
 						if parseErr != nil {
-							slog.Warn("Could not parse link", "original_href", hrefAttr, "base_url", baseDomain.String(), "error", parseErr)
+							slog.Warn("Could not parse link", "original_href", hrefAttr, "base_url", linkBase.String(), "error", parseErr)
 						} else {
 							linkStr := absoluteLink.String()
-							if absoluteLink.Host == baseDomain.Host && absoluteLink.Scheme == baseDomain.Scheme { // ensure scheme also matches for stricter internal
+							link := PageLink{URL: linkStr, Tag: n.Data, Path: cssPath(n), Internal: a.linkPolicy.Internal(baseDomain, absoluteLink)}
+							if link.Internal {
 								result.InternalLinksCount++
 								slog.Debug("Found internal link", "tag", n.Data, "href", linkStr)
 							} else {
 								result.ExternalLinksCount++
 								slog.Debug("Found external link", "tag", n.Data, "href", linkStr)
 							}
-							linksToTest = append(linksToTest, linkStr)
+							if n.DataAtom == atom.A {
+								link.Text = nodeText(n)
+							}
+							result.Links = append(result.Links, link)
 						}
 					}
 				}
 			}
 
-			// --- 4. Login Form Detection (Basic Heuristics) ---
-			if n.DataAtom == atom.Form {
-				// Check if ContainsLoginForm is already true to avoid redundant checks if multiple forms exist
-				if !result.ContainsLoginForm {
-					result.ContainsLoginForm = detectLoginForm(n)
-					if result.ContainsLoginForm {
-						slog.Info("Login form detected on page")
-					}
-				}
-			}
 		} else if n.Type == html.DoctypeNode {
 			// --- 5. HTML Version (Check based on Doctype) ---
 			slog.Debug("Doctype node found", "data", n.Data)
@@ -198,6 +438,9 @@ func FetchAndAnalyze(pageURL string) (*AnalysisResult, error) {
 		}
 	}
 	f(doc)
+	result.Outline = outline.finish()
+	result.HeadingsCount = result.Outline.Counts()
+	result.LinkDomains = groupLinksByDomain(result.Links)
 
 	// Fallback for HTML Version if not set during traversal
 	if result.HTMLVersion == "" {
@@ -205,16 +448,83 @@ func FetchAndAnalyze(pageURL string) (*AnalysisResult, error) {
 		result.HTMLVersion = "Unknown or No Doctype"
 	}
 	slog.Info("Final HTML version determined", "version", result.HTMLVersion)
+	finishSocial(&result.Social, result.PageTitle, result.Metadata.Description, baseDomain, linkBase)
+	result.StructuredData = extractStructuredData(doc, linkBase)
+	slog.Debug("Structured data extracted", "items", len(result.StructuredData.Items), "errors", len(result.StructuredData.Errors), "issues", len(result.StructuredData.Issues))
+	result.Accessibility = auditAccessibility(doc)
+	slog.Info("Accessibility audit complete", "errors", result.Accessibility.Errors, "warnings", result.Accessibility.Warnings)
+
+	refs := collectResourceRefs(doc, linkBase)
+	result.Resources = inventoryResources(refs, baseDomain, a.linkPolicy)
+	result.ResourceCounts = countResources(result.Resources)
+	slog.Debug("Resources inventoried", "count", len(result.Resources))
+	result.MixedContent = detectMixedContent(refs, baseDomain)
+	if len(result.MixedContent) > 0 {
+		slog.Warn("Mixed content found on HTTPS page", "url", result.FinalURL, "count", len(result.MixedContent))
+	}
 
-	// --- 6. Inaccessible Links Check (Concurrent) ---
-	if len(linksToTest) > 0 {
-		slog.Debug("Checking accessibility for links", "count", len(linksToTest))
-		result.InaccessibleLinks = checkLinkAccessibility(linksToTest)
-		slog.Info("Link accessibility check complete", "inaccessible_count", len(result.InaccessibleLinks))
+	// --- 6. Forms and Login Form Detection ---
+	// Empty form actions submit to the page itself, so the final URL is passed along with the base
+	result.Forms = inventoryForms(doc, baseDomain, linkBase)
+	if a.detectLoginForms {
+		result.ContainsLoginForm = result.Forms.Has(FormLogin)
+		if result.ContainsLoginForm {
+			slog.Info("Login form detected on page")
+		}
+	}
+
+	// --- 7. Inaccessible Links and Resources Check (Concurrent) ---
+	linksChecked := 0 // unique URLs, as reported during the check
+	if !a.checkLinks {
+		slog.Debug("Link accessibility check disabled, skipping.")
+	} else if toCheck := append(slices.Clip(result.Links), resourceLinks(result.Resources)...); len(toCheck) > 0 {
+		// Subresources are checked along with the links so that broken images and scripts are reported too
+		slog.Debug("Checking accessibility for links and resources", "count", len(toCheck))
+		result.InaccessibleLinks, result.SkippedLinks, linksChecked = a.checkLinkAccessibility(ctx, toCheck)
+		slog.Info("Link accessibility check complete", "inaccessible_count", len(result.InaccessibleLinks), "skipped_count", len(result.SkippedLinks))
 	} else {
 		slog.Debug("No links found to check for accessibility.")
 	}
 
+	// --- 8. Canonical URL Check ---
+	if a.checkLinks && result.Metadata.Canonical != "" {
+		if checked := a.checkLinkResults(ctx, []PageLink{{URL: result.Metadata.Canonical, Tag: "link"}}, nil); len(checked) == 1 {
+			result.Metadata.CanonicalStatus = &checked[0]
+		}
+	}
+
+	// --- 9. Social Preview Image Check ---
+	if a.checkLinks && result.Social.Preview.Image != "" {
+		a.checkSocialImage(ctx, &result.Social)
+	}
+
+	// --- 10. Sitemap Check ---
+	if a.checkSitemaps {
+		a.reportProgress(Progress{Phase: PhaseSitemap})
+		// The site is the one the page was finally served from, after any redirects
+		result.Sitemap, _ = a.CheckSitemaps(ctx, result.FinalURL) // only fails for invalid URLs, and FinalURL was just fetched
+	}
+
+	// --- 11. Page Weight and Performance Budget ---
+	result.Performance.Document.URL, result.Performance.Document.Kind = result.FinalURL, ResourceDocument
+	if a.checkPerformance {
+		a.reportProgress(Progress{Phase: PhasePerformance})
+		result.Performance.Resources = a.probeResources(ctx, result.Resources)
+		result.Performance.ResourcesProbed = true
+	}
+	finishPerformance(&result.Performance, a.performanceBudget)
+
+	// A cancelled context leaves the link results incomplete, so don't report them as a success
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		slog.Warn("Analysis cancelled", "url", pageURL, "error", ctxErr)
+		return nil, &AnalysisError{Message: fmt.Sprintf("Analysis cancelled: %v", ctxErr), StatusCode: resp.StatusCode, Category: fetchErrorCategory(ctxErr), Err: ctxErr}
+	}
+
+	done := Progress{Phase: PhaseDone}
+	if a.checkLinks {
+		done.LinksChecked, done.LinksTotal = linksChecked, linksChecked // every link finished, as ctx is not done
+	}
+	a.reportProgress(done)
 	return result, nil
 }
 
@@ -308,112 +618,3 @@ func detectLoginForm(formNode *html.Node) bool {
 
 	return isUserPassForm || isPinForm
 }
-
-// checkLinkAccessibility checks a list of URLs concurrently
-func checkLinkAccessibility(links []string) []string {
-	var inaccessible []string
-	if len(links) == 0 {
-		return inaccessible
-	}
-
-	var wg sync.WaitGroup
-	var mu sync.Mutex
-
-	concurrencyLimit := 10
-	semaphore := make(chan struct{}, concurrencyLimit)
-
-	httpClient := &http.Client{
-		Timeout: 10 * time.Second,
-	}
-
-	for _, link := range links {
-		wg.Add(1)
-		semaphore <- struct{}{}
-
-		go func(l string) {
-			defer wg.Done()
-			defer func() { <-semaphore }()
-
-			slog.Debug("Checking link accessibility", "url", l)
-			req, err := http.NewRequest(http.MethodHead, l, nil)
-			if err != nil {
-				mu.Lock()
-				inaccessible = append(inaccessible, l)
-				mu.Unlock()
-				return
-			}
-			req.Header.Set("User-Agent", "WebAnalyzerBot/1.0 (+http://example.com/bot)")
-
-			resp, err := httpClient.Do(req)
-			statusCode := 0
-			if err != nil {
-				if urlErr, ok := err.(*url.Error); ok {
-					if strings.Contains(strings.ToLower(urlErr.Error()), "timeout") || strings.Contains(strings.ToLower(urlErr.Error()), "refused") {
-						mu.Lock()
-						inaccessible = append(inaccessible, l)
-						mu.Unlock()
-						return
-					}
-				}
-				// Try GET if HEAD fails (could be 405 or other method not allowed)
-				reqGet, errGet := http.NewRequest(http.MethodGet, l, nil)
-				if errGet != nil {
-					mu.Lock()
-					inaccessible = append(inaccessible, l)
-					mu.Unlock()
-					return
-				}
-				reqGet.Header.Set("User-Agent", "WebAnalyzerBot/1.0 (+http://example.com/bot)")
-				respGet, errGet := httpClient.Do(reqGet)
-				if errGet != nil {
-					mu.Lock() // Lock to prevent concurrent access to inaccessible slice from go routines
-					inaccessible = append(inaccessible, l)
-					mu.Unlock()
-					return
-				}
-				defer respGet.Body.Close()
-				if respGet.StatusCode >= 400 {
-					mu.Lock()
-					inaccessible = append(inaccessible, l)
-					mu.Unlock()
-				}
-				return
-			}
-			defer resp.Body.Close()
-			statusCode = resp.StatusCode
-			if statusCode == 405 {
-				// Retry with GET if HEAD is not allowed
-				reqGet, errGet := http.NewRequest(http.MethodGet, l, nil)
-				if errGet != nil {
-					mu.Lock()
-					inaccessible = append(inaccessible, l)
-					mu.Unlock()
-					return
-				}
-				reqGet.Header.Set("User-Agent", "WebAnalyzerBot/1.0 (+http://example.com/bot)")
-				respGet, errGet := httpClient.Do(reqGet)
-				if errGet != nil {
-					mu.Lock()
-					inaccessible = append(inaccessible, l)
-					mu.Unlock()
-					return
-				}
-				defer respGet.Body.Close()
-				if respGet.StatusCode >= 400 {
-					mu.Lock()
-					inaccessible = append(inaccessible, l)
-					mu.Unlock()
-				}
-				return
-			}
-			if statusCode >= 400 {
-				mu.Lock()
-				inaccessible = append(inaccessible, l)
-				mu.Unlock()
-			}
-		}(link)
-	}
-
-	wg.Wait()
-	return inaccessible
-}
@@ -2,12 +2,16 @@
 package analyzer
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"strings"
+	"sync"
 
 	"testing"
 	"time"
@@ -118,9 +122,15 @@ func TestFetchAndAnalyze_Full(t *testing.T) {
 		t.Errorf("Expected 1 inaccessible link, got %d. Links: %+v", len(result.InaccessibleLinks), result.InaccessibleLinks)
 	} else {
 		foundBroken := false
-		for _, url := range result.InaccessibleLinks {
-			if strings.Contains(url, "definitely-broken-link") {
+		for _, link := range result.InaccessibleLinks {
+			if strings.Contains(link.URL, "definitely-broken-link") {
 				foundBroken = true
+				if link.Tag != "a" || link.Text != "Broken Link" {
+					t.Errorf("Expected broken link to come from <a> with text 'Broken Link', got tag %q text %q", link.Tag, link.Text)
+				}
+				if link.ErrorClass != LinkErrorConnectionRefused {
+					t.Errorf("Expected error class %q for broken link, got %q", LinkErrorConnectionRefused, link.ErrorClass)
+				}
 				break
 			}
 		}
@@ -148,6 +158,9 @@ func TestFetchAndAnalyze_ErrorHandling(t *testing.T) {
 		if ae.StatusCode != 0 {
 			t.Errorf("Expected StatusCode 0 for network error, got %d", ae.StatusCode)
 		}
+		if ae.Category != ErrorCategoryFetchFailed {
+			t.Errorf("Expected category %q for network error, got %q", ErrorCategoryFetchFailed, ae.Category)
+		}
 	})
 
 	t.Run("HTTPErrorStatus", func(t *testing.T) {
@@ -167,6 +180,9 @@ func TestFetchAndAnalyze_ErrorHandling(t *testing.T) {
 		if ae.StatusCode != http.StatusNotFound {
 			t.Errorf("Expected StatusCode %d, got %d", http.StatusNotFound, ae.StatusCode)
 		}
+		if ae.Category != ErrorCategoryHTTPStatus {
+			t.Errorf("Expected category %q, got %q", ErrorCategoryHTTPStatus, ae.Category)
+		}
 	})
 
 	t.Run("NonHTMLContent", func(t *testing.T) {
@@ -269,35 +285,90 @@ func TestCheckLinkAccessibility(t *testing.T) {
 	})
 	defer headFailGetOkServer.Close()
 
-	links := []string{
-		okServer.URL + "/good",                // Accessible
-		notFoundServer.URL + "/bad",           // Inaccessible (404)
-		"http://localhost:12347/unreachable",  // Inaccessible (connection refused)
-		timeoutServer.URL + "/timeout",        // Inaccessible (timeout)
-		headFailGetOkServer.URL + "/headfail", // Should be accessible via GET retry
+	// Server that disallows HEAD and returns 404 for GET
+	headFailGetNotFoundServer := newMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		http.NotFound(w, r)
+	})
+	defer headFailGetNotFoundServer.Close()
+
+	// Server that redirects twice before ending on a 404
+	redirectServer := newMockServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/start":
+			http.Redirect(w, r, "/middle", http.StatusMovedPermanently)
+		case "/middle":
+			http.Redirect(w, r, "/gone", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	defer redirectServer.Close()
+
+	links := []PageLink{
+		{URL: okServer.URL + "/good", Tag: "a"},                     // Accessible
+		{URL: notFoundServer.URL + "/bad", Tag: "a"},                // Inaccessible (404)
+		{URL: "http://localhost:12347/unreachable", Tag: "a"},       // Inaccessible (connection refused)
+		{URL: timeoutServer.URL + "/timeout", Tag: "link"},          // Inaccessible (timeout)
+		{URL: headFailGetOkServer.URL + "/headfail", Tag: "a"},      // Should be accessible via GET retry
+		{URL: headFailGetNotFoundServer.URL + "/getfail", Tag: "a"}, // Inaccessible via GET retry (404)
+		{URL: redirectServer.URL + "/start", Tag: "a"},              // Inaccessible after redirects (404)
 	}
 
-	inaccessibleLinks := checkLinkAccessibility(links)
+	inaccessibleLinks, _, _ := New().checkLinkAccessibility(context.Background(), links)
 
-	// Expect /bad, /unreachable, /timeout to be inaccessible. /headfail should be accessible.
-	if len(inaccessibleLinks) != 3 {
-		t.Fatalf("Expected 3 inaccessible links, got %d. Details: %+v", len(inaccessibleLinks), inaccessibleLinks)
+	// Expect /bad, /unreachable, /timeout, /getfail, /start to be inaccessible. /headfail should be accessible.
+	if len(inaccessibleLinks) != 5 {
+		t.Fatalf("Expected 5 inaccessible links, got %d. Details: %+v", len(inaccessibleLinks), inaccessibleLinks)
 	}
 
-	expectedInaccessible := map[string]bool{
-		notFoundServer.URL + "/bad":          true,
-		"http://localhost:12347/unreachable": true,
-		timeoutServer.URL + "/timeout":       true,
+	expectedInaccessible := map[string]struct {
+		class      LinkErrorClass
+		statusCode int
+		method     string
+	}{
+		notFoundServer.URL + "/bad":                {LinkErrorHTTPStatus, http.StatusNotFound, http.MethodHead},
+		"http://localhost:12347/unreachable":       {LinkErrorConnectionRefused, 0, http.MethodHead},
+		timeoutServer.URL + "/timeout":             {LinkErrorTimeout, 0, http.MethodHead},
+		headFailGetNotFoundServer.URL + "/getfail": {LinkErrorHTTPStatus, http.StatusNotFound, http.MethodGet},
+		redirectServer.URL + "/start":              {LinkErrorHTTPStatus, http.StatusNotFound, http.MethodHead},
 	}
 	foundInaccessibleCount := 0
 
-	for _, url := range inaccessibleLinks {
-		if _, ok := expectedInaccessible[url]; ok {
-			foundInaccessibleCount++
-		} else {
-			t.Errorf("Unexpected link in inaccessible list: %s", url)
+	for _, link := range inaccessibleLinks {
+		expected, ok := expectedInaccessible[link.URL]
+		if !ok {
+			t.Errorf("Unexpected link in inaccessible list: %s", link.URL)
+			continue
+		}
+		foundInaccessibleCount++
+		if link.ErrorClass != expected.class {
+			t.Errorf("Link %s: expected error class %q, got %q (error: %s)", link.URL, expected.class, link.ErrorClass, link.Error)
+		}
+		if link.StatusCode != expected.statusCode {
+			t.Errorf("Link %s: expected status code %d, got %d", link.URL, expected.statusCode, link.StatusCode)
+		}
+		if link.Method != expected.method {
+			t.Errorf("Link %s: expected method %s, got %s", link.URL, expected.method, link.Method)
+		}
+		if link.Latency <= 0 {
+			t.Errorf("Link %s: expected a positive latency, got %v", link.URL, link.Latency)
 		}
 	}
+
+	redirected := inaccessibleLinks[0]
+	for _, link := range inaccessibleLinks {
+		if link.URL == redirectServer.URL+"/start" {
+			redirected = link
+		}
+	}
+	expectedChain := []string{redirectServer.URL + "/middle", redirectServer.URL + "/gone"}
+	if strings.Join(redirected.RedirectChain, " ") != strings.Join(expectedChain, " ") {
+		t.Errorf("Expected redirect chain %v, got %v", expectedChain, redirected.RedirectChain)
+	}
 	if foundInaccessibleCount != len(expectedInaccessible) {
 		t.Errorf("Mismatch in count of specific expected inaccessible links. Expected %d, found in list %d", len(expectedInaccessible), foundInaccessibleCount)
 	}
@@ -339,3 +410,337 @@ func TestFetchAndAnalyze_HTMLVersions(t *testing.T) {
 		})
 	}
 }
+
+func TestAnalyze_Options(t *testing.T) {
+	var gotUserAgent string
+	var linkRequests int
+	var mu sync.Mutex
+	server := newMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/link" {
+			mu.Lock()
+			linkRequests++
+			mu.Unlock()
+			return
+		}
+		gotUserAgent = r.Header.Get("User-Agent")
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><body><a href="/link">L</a><form><input type="text" name="user"><input type="password" name="pw"><button>Go</button></form></body></html>`)
+	})
+	defer server.Close()
+
+	a := New(WithUserAgent("TestAgent/2.0"), WithLinkCheck(false), WithLoginFormDetection(false))
+	result, err := a.Analyze(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("Analyze failed unexpectedly: %v", err)
+	}
+	if gotUserAgent != "TestAgent/2.0" {
+		t.Errorf("Expected User-Agent 'TestAgent/2.0', got '%s'", gotUserAgent)
+	}
+	if linkRequests != 0 {
+		t.Errorf("Expected no link checks with WithLinkCheck(false), got %d", linkRequests)
+	}
+	if result.InternalLinksCount != 1 {
+		t.Errorf("Expected links to still be counted, got %d internal links", result.InternalLinksCount)
+	}
+	if result.ContainsLoginForm {
+		t.Errorf("Expected ContainsLoginForm to be false with WithLoginFormDetection(false)")
+	}
+}
+
+func TestAnalyze_Cancellation(t *testing.T) {
+	t.Run("FetchTimeout", func(t *testing.T) {
+		server := newMockServer(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+		})
+		defer server.Close()
+
+		start := time.Now()
+		_, err := New(WithFetchTimeout(100*time.Millisecond)).Analyze(context.Background(), server.URL)
+		if err == nil {
+			t.Fatal("Expected an error for a slow page, got nil")
+		}
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected error to wrap context.DeadlineExceeded, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("Expected fetch to be aborted quickly, took %v", elapsed)
+		}
+	})
+
+	t.Run("CancelDuringLinkCheck", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		server := newMockServer(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasPrefix(r.URL.Path, "/slow") {
+				cancel() // cancel the analysis as soon as link checking starts
+				select {
+				case <-r.Context().Done():
+				case <-time.After(5 * time.Second):
+				}
+				return
+			}
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<html><body><a href="/slow1">1</a><a href="/slow2">2</a><a href="/slow3">3</a></body></html>`)
+		})
+		defer server.Close()
+
+		start := time.Now()
+		_, err := New(WithLinkConcurrency(1)).Analyze(ctx, server.URL)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected error to wrap context.Canceled, got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("Expected link checks to be aborted quickly, took %v", elapsed)
+		}
+	})
+}
+
+func TestCheckLink_TLSError(t *testing.T) {
+	// The default client does not trust httptest's self-signed certificate
+	tlsServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer tlsServer.Close()
+
+	res, _ := New().checkLink(context.Background(), PageLink{URL: tlsServer.URL + "/secure", Tag: "a"})
+	if res.Accessible() {
+		t.Fatalf("Expected link with untrusted certificate to be inaccessible")
+	}
+	if res.ErrorClass != LinkErrorTLS {
+		t.Errorf("Expected error class %q, got %q (error: %s)", LinkErrorTLS, res.ErrorClass, res.Error)
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	testCases := []struct {
+		name    string
+		rawURL  string
+		wantErr string
+	}{
+		{"Valid", "https://example.com/path?q=1", ""},
+		{"Empty", "", "URL field cannot be empty."},
+		{"NotAURL", "not a url", "Must be a valid HTTP/HTTPS URL"},
+		{"WrongScheme", "ftp://example.com/file", "Must be a valid HTTP/HTTPS URL"},
+		{"NoHost", "http:///path", "URL must include a host"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			parsed, err := ValidateURL(tc.rawURL)
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Expected %q to be valid, got error: %v", tc.rawURL, err)
+				}
+				if parsed.String() != tc.rawURL {
+					t.Errorf("Expected parsed URL %q, got %q", tc.rawURL, parsed.String())
+				}
+				return
+			}
+			var ae *AnalysisError
+			if !errors.As(err, &ae) {
+				t.Fatalf("Expected *AnalysisError for %q, got %T (%v)", tc.rawURL, err, err)
+			}
+			if ae.Category != ErrorCategoryInvalidURL {
+				t.Errorf("Expected category %q, got %q", ErrorCategoryInvalidURL, ae.Category)
+			}
+			if !strings.Contains(ae.Message, tc.wantErr) {
+				t.Errorf("Expected message to contain %q, got %q", tc.wantErr, ae.Message)
+			}
+		})
+	}
+}
+
+func TestAnalyze_Progress(t *testing.T) {
+	server := newMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><body><a href="/a">A</a><a href="/b">B</a><a href="/c">C</a></body></html>`)
+	})
+	defer server.Close()
+
+	var updates []Progress
+	a := New(WithProgress(func(p Progress) { updates = append(updates, p) }))
+	if _, err := a.Analyze(context.Background(), server.URL); err != nil {
+		t.Fatalf("Analyze failed unexpectedly: %v", err)
+	}
+
+	var phases []Phase
+	for _, p := range updates {
+		if len(phases) == 0 || phases[len(phases)-1] != p.Phase {
+			phases = append(phases, p.Phase)
+		}
+	}
+	expectedPhases := []Phase{PhaseFetch, PhaseParse, PhaseCheckLinks, PhaseDone}
+	if fmt.Sprint(phases) != fmt.Sprint(expectedPhases) {
+		t.Errorf("Expected phases %v, got %v", expectedPhases, phases)
+	}
+
+	// One update when link checking starts, then one per checked link
+	var linkUpdates []Progress
+	for _, p := range updates {
+		if p.Phase == PhaseCheckLinks {
+			linkUpdates = append(linkUpdates, p)
+		}
+	}
+	if len(linkUpdates) != 4 {
+		t.Fatalf("Expected 4 link check updates, got %d: %+v", len(linkUpdates), linkUpdates)
+	}
+	for i, p := range linkUpdates {
+		if p.LinksChecked != i || p.LinksTotal != 3 {
+			t.Errorf("Update %d: expected %d/3 links checked, got %d/%d", i, i, p.LinksChecked, p.LinksTotal)
+		}
+	}
+}
+
+func TestNormalizeLinkURL(t *testing.T) {
+	ignored := []string{"utm_*", "ref"}
+	testCases := []struct {
+		raw  string
+		want string
+	}{
+		{"HTTP://Example.COM:80/Path", "http://example.com/Path"},
+		{"https://example.com:443", "https://example.com/"},
+		{"https://example.com:8443/", "https://example.com:8443/"},
+		{"https://example.com/page#section", "https://example.com/page"},
+		{"https://example.com/page?", "https://example.com/page"},
+		{"https://example.com/?utm_source=x&id=1&ref=nav&utm_medium=y", "https://example.com/?id=1"},
+		{"https://example.com/?referrer=a&b=%20c", "https://example.com/?referrer=a&b=%20c"},
+		{"http://[::1]:80/", "http://[::1]/"},
+	}
+
+	for _, tc := range testCases {
+		u, err := url.Parse(tc.raw)
+		if err != nil {
+			t.Fatalf("Failed to parse %q: %v", tc.raw, err)
+		}
+		if got := normalizeLinkURL(u, ignored); got != tc.want {
+			t.Errorf("normalizeLinkURL(%q) = %q, want %q", tc.raw, got, tc.want)
+		}
+	}
+}
+
+func TestCheckLinkAccessibility_Deduplication(t *testing.T) {
+	var mu sync.Mutex
+	requests := map[string]int{}
+	server := newMockServer(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.RequestURI()]++
+		mu.Unlock()
+		http.NotFound(w, r)
+	})
+	defer server.Close()
+
+	links := []PageLink{
+		{URL: server.URL + "/gone#top", Tag: "a", Text: "Top", Path: "html > body > nav > a"},
+		{URL: server.URL + "/gone?utm_source=footer", Tag: "a", Text: "Footer", Path: "html > body > footer > a"},
+		{URL: server.URL + "/missing.png", Tag: "img", Path: "html > body > img"},
+		{URL: strings.Replace(server.URL, "http://", "HTTP://", 1) + "/gone", Tag: "a", Text: "Again", Path: "html > body > a"},
+	}
+	inaccessible, _, _ := New(WithIgnoredQueryParams("utm_*")).checkLinkAccessibility(context.Background(), links)
+
+	if len(inaccessible) != 2 {
+		t.Fatalf("Expected 2 unique inaccessible links, got %+v", inaccessible)
+	}
+	gone := inaccessible[0]
+	if gone.URL != server.URL+"/gone" || gone.Occurrences != 3 || len(gone.Sources) != 3 {
+		t.Fatalf("Expected %s/gone with 3 occurrences, got %+v", server.URL, gone)
+	}
+	wantPaths := []string{"html > body > nav > a", "html > body > footer > a", "html > body > a"}
+	for i, source := range gone.Sources {
+		if source.Path != wantPaths[i] || source.URL != links[[]int{0, 1, 3}[i]].URL {
+			t.Errorf("Expected source %d at %s, got %+v", i, wantPaths[i], source)
+		}
+	}
+	if inaccessible[1].Occurrences != 1 || inaccessible[1].Tag != "img" {
+		t.Errorf("Expected the image once, got %+v", inaccessible[1])
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if n := requests["/gone"] + requests["/gone?utm_source=footer"]; n != 1 {
+		t.Errorf("Expected the duplicated link to be requested once, got %d request(s): %v", n, requests)
+	}
+}
+
+func TestAnalyze_LinkResolution(t *testing.T) {
+	other := newMockServer(func(w http.ResponseWriter, r *http.Request) {})
+	defer other.Close()
+
+	server := newMockServer(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/start":
+			http.Redirect(w, r, "/docs/page", http.StatusFound)
+		case "/docs/page":
+			fmt.Fprint(w, `<html><head><link rel="stylesheet" href="style.css"></head><body><a href="next">Next</a></body></html>`)
+		case "/based":
+			fmt.Fprintf(w, `<html><head><link rel="canonical" href="based"><base href="%s/assets/"></head><body><a href="img/logo.png">Logo</a><a href="/">Home</a></body></html>`, other.URL)
+		case "/bad-base":
+			fmt.Fprint(w, `<html><head><base href="javascript:alert(1)"></head><body><a href="x">X</a></body></html>`)
+		}
+	})
+	defer server.Close()
+
+	testCases := []struct {
+		name         string
+		path         string
+		wantFinalURL string
+		wantBaseURL  string
+		wantLinks    []PageLink
+		wantCanon    string
+	}{
+		{
+			name:         "Redirect",
+			path:         "/start",
+			wantFinalURL: server.URL + "/docs/page",
+			wantBaseURL:  server.URL + "/docs/page",
+			wantLinks: []PageLink{
+				{URL: server.URL + "/docs/style.css", Tag: "link", Path: "html > head > link", Internal: true},
+				{URL: server.URL + "/docs/next", Tag: "a", Text: "Next", Path: "html > body > a", Internal: true},
+			},
+		},
+		{
+			name:         "BaseHref",
+			path:         "/based",
+			wantFinalURL: server.URL + "/based",
+			wantBaseURL:  other.URL + "/assets/",
+			wantLinks: []PageLink{
+				{URL: other.URL + "/assets/based", Tag: "link", Path: "html > head > link", Internal: false},
+				{URL: other.URL + "/assets/img/logo.png", Tag: "a", Text: "Logo", Path: "html > body > a:nth-of-type(1)", Internal: false},
+				{URL: other.URL + "/", Tag: "a", Text: "Home", Path: "html > body > a:nth-of-type(2)", Internal: false},
+			},
+			wantCanon: other.URL + "/assets/based",
+		},
+		{
+			name:         "UnusableBaseHref",
+			path:         "/bad-base",
+			wantFinalURL: server.URL + "/bad-base",
+			wantBaseURL:  server.URL + "/bad-base",
+			wantLinks:    []PageLink{{URL: server.URL + "/x", Tag: "a", Text: "X", Path: "html > body > a", Internal: true}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := New(WithLinkCheck(false)).Analyze(context.Background(), server.URL+tc.path)
+			if err != nil {
+				t.Fatalf("Analyze failed unexpectedly: %v", err)
+			}
+			if result.FinalURL != tc.wantFinalURL || result.BaseURL != tc.wantBaseURL {
+				t.Errorf("Expected final URL %q and base URL %q, got %q and %q", tc.wantFinalURL, tc.wantBaseURL, result.FinalURL, result.BaseURL)
+			}
+			if fmt.Sprint(result.Links) != fmt.Sprint(tc.wantLinks) {
+				t.Errorf("Expected links %+v, got %+v", tc.wantLinks, result.Links)
+			}
+			if result.Metadata.Canonical != tc.wantCanon {
+				t.Errorf("Expected canonical %q, got %q", tc.wantCanon, result.Metadata.Canonical)
+			}
+		})
+	}
+}
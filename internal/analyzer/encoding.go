@@ -0,0 +1,158 @@
+package analyzer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/transform"
+)
+
+// Sources of the encoding in an EncodingReport, in order of precedence
+const (
+	EncodingSourceBOM     = "bom"
+	EncodingSourceHeader  = "header"  // charset parameter of the Content-Type header
+	EncodingSourceMeta    = "meta"    // <meta charset> or <meta http-equiv="Content-Type">
+	EncodingSourceSniffed = "sniffed" // nothing was declared: UTF-8 if the content is valid UTF-8, otherwise windows-1252
+)
+
+// encodingPrescanBytes is how much of the document is searched for a BOM or <meta> declaration
+const encodingPrescanBytes = 1024
+
+// EncodingReport describes the character encoding the page was decoded with
+type EncodingReport struct {
+	Name          string `json:"name"`   // WHATWG name of the encoding, e.g. "utf-8" or "shift_jis"
+	Source        string `json:"source"` // where Name was determined from
+	HeaderCharset string `json:"header_charset,omitempty"`
+	// DocumentCharset is the encoding the document declares, by BOM or by a <meta> element
+	// within its first 1024 bytes
+	DocumentCharset string   `json:"document_charset,omitempty"`
+	Mismatch        bool     `json:"mismatch"` // the header and the document declare different encodings
+	Warnings        []string `json:"warnings"`
+}
+
+// boms maps byte order marks to the encoding they select
+var boms = []struct {
+	bom  []byte
+	name string
+}{
+	{[]byte{0xef, 0xbb, 0xbf}, "utf-8"},
+	{[]byte{0xfe, 0xff}, "utf-16be"},
+	{[]byte{0xff, 0xfe}, "utf-16le"},
+}
+
+// decodeDocument returns body transcoded to UTF-8, with any BOM removed, and reports which
+// encoding it was decoded from. contentType is the value of the Content-Type header.
+func decodeDocument(body io.Reader, contentType string) (io.Reader, EncodingReport, error) {
+	prefix := make([]byte, encodingPrescanBytes)
+	n, err := io.ReadFull(body, prefix)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, EncodingReport{}, err
+	}
+	prefix = prefix[:n]
+
+	e, report := detectEncoding(prefix, contentType)
+	for _, b := range boms {
+		if bytes.HasPrefix(prefix, b.bom) {
+			prefix = prefix[len(b.bom):]
+			break
+		}
+	}
+	decoded := io.MultiReader(bytes.NewReader(prefix), body)
+	if e != encoding.Nop {
+		decoded = transform.NewReader(decoded, e.NewDecoder())
+	}
+	return decoded, report, nil
+}
+
+// detectEncoding determines the encoding of a document starting with prefix, following the
+// precedence of the HTML standard: BOM, then Content-Type header, then <meta> prescan
+func detectEncoding(prefix []byte, contentType string) (encoding.Encoding, EncodingReport) {
+	report := EncodingReport{Warnings: []string{}}
+
+	var headerEnc encoding.Encoding
+	if _, params, err := mime.ParseMediaType(contentType); err == nil && params["charset"] != "" {
+		label := params["charset"]
+		if headerEnc, report.HeaderCharset = charset.Lookup(label); headerEnc == nil {
+			report.HeaderCharset = label
+			report.Warnings = append(report.Warnings, fmt.Sprintf("Content-Type header declares unsupported charset %q", label))
+		}
+	}
+
+	var docEnc encoding.Encoding
+	docSource := ""
+	for _, b := range boms {
+		if bytes.HasPrefix(prefix, b.bom) {
+			docEnc, report.DocumentCharset = charset.Lookup(b.name)
+			docSource = EncodingSourceBOM
+			break
+		}
+	}
+	if docEnc == nil {
+		if label := prescanCharset(prefix); label != "" {
+			docSource = EncodingSourceMeta
+			if docEnc, report.DocumentCharset = charset.Lookup(label); docEnc == nil {
+				report.DocumentCharset = label
+				report.Warnings = append(report.Warnings, fmt.Sprintf("<meta> declares unsupported charset %q", label))
+			} else if strings.HasPrefix(report.DocumentCharset, "utf-16") {
+				// A <meta> that could be read as ASCII can't be right about UTF-16
+				docEnc, report.DocumentCharset = charset.Lookup("utf-8")
+			}
+		}
+	}
+
+	if headerEnc != nil && docEnc != nil && report.HeaderCharset != report.DocumentCharset {
+		report.Mismatch = true
+	}
+
+	var e encoding.Encoding
+	switch {
+	case docSource == EncodingSourceBOM:
+		e, report.Name, report.Source = docEnc, report.DocumentCharset, EncodingSourceBOM
+	case headerEnc != nil:
+		e, report.Name, report.Source = headerEnc, report.HeaderCharset, EncodingSourceHeader
+	case docEnc != nil:
+		e, report.Name, report.Source = docEnc, report.DocumentCharset, EncodingSourceMeta
+	default:
+		// With no usable declaration, DetermineEncoding only sniffs for UTF-8
+		e, report.Name, _ = charset.DetermineEncoding(prefix, "")
+		report.Source = EncodingSourceSniffed
+		report.Warnings = append(report.Warnings, fmt.Sprintf("No character encoding declared; assumed %s", report.Name))
+	}
+	if report.Mismatch {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("Content-Type header declares %s but the document declares %s; decoded as %s", report.HeaderCharset, report.DocumentCharset, report.Name))
+	}
+	return e, report
+}
+
+// prescanCharset returns the charset label declared by the first <meta charset> or
+// <meta http-equiv="Content-Type"> element in prefix, or "" if there is none
+func prescanCharset(prefix []byte) string {
+	z := html.NewTokenizer(bytes.NewReader(prefix))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.StartTagToken, html.SelfClosingTagToken:
+			t := z.Token()
+			if t.Data != "meta" {
+				continue
+			}
+			n := &html.Node{Type: html.ElementNode, Data: t.Data, Attr: t.Attr}
+			if label := strings.TrimSpace(attrValue(n, "charset")); label != "" {
+				return label
+			}
+			if strings.EqualFold(attrValue(n, "http-equiv"), "content-type") {
+				if _, params, err := mime.ParseMediaType(attrValue(n, "content")); err == nil && params["charset"] != "" {
+					return params["charset"]
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package analyzer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+)
+
+func TestDetectEncoding(t *testing.T) {
+	testCases := []struct {
+		name         string
+		prefix       string
+		contentType  string
+		wantName     string
+		wantSource   string
+		wantMismatch bool
+		wantWarnings int
+	}{
+		{"Header", "<html>", "text/html; charset=ISO-8859-1", "windows-1252", EncodingSourceHeader, false, 0},
+		{"Meta charset", `<meta charset="Shift_JIS">`, "text/html", "shift_jis", EncodingSourceMeta, false, 0},
+		{"Meta http-equiv", `<meta http-equiv="Content-Type" content="text/html; charset=euc-jp">`, "text/html", "euc-jp", EncodingSourceMeta, false, 0},
+		{"Header wins over meta", `<meta charset="utf-8">`, "text/html; charset=windows-1252", "windows-1252", EncodingSourceHeader, true, 1},
+		{"BOM wins over header", "\xef\xbb\xbf<html>", "text/html; charset=shift_jis", "utf-8", EncodingSourceBOM, true, 1},
+		{"Same declaration", `<meta charset="latin1">`, "text/html; charset=windows-1252", "windows-1252", EncodingSourceHeader, false, 0},
+		{"Meta UTF-16 is UTF-8", `<meta charset="utf-16">`, "text/html", "utf-8", EncodingSourceMeta, false, 0},
+		{"Unsupported header", `<meta charset="utf-8">`, "text/html; charset=klingon", "utf-8", EncodingSourceMeta, false, 1},
+		{"Sniffed UTF-8", "<p>caf\xc3\xa9</p>", "text/html", "utf-8", EncodingSourceSniffed, false, 1},
+		{"Sniffed default", "<p>caf\xe9</p>", "text/html", "windows-1252", EncodingSourceSniffed, false, 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, report := detectEncoding([]byte(tc.prefix), tc.contentType)
+			if report.Name != tc.wantName || report.Source != tc.wantSource {
+				t.Errorf("Expected %s from %s, got %s from %s", tc.wantName, tc.wantSource, report.Name, report.Source)
+			}
+			if report.Mismatch != tc.wantMismatch {
+				t.Errorf("Expected mismatch %v, got %v", tc.wantMismatch, report.Mismatch)
+			}
+			if len(report.Warnings) != tc.wantWarnings {
+				t.Errorf("Expected %d warning(s), got %v", tc.wantWarnings, report.Warnings)
+			}
+		})
+	}
+}
+
+func TestAnalyze_Encoding(t *testing.T) {
+	encode := func(e encoding.Encoding, s string) []byte {
+		b, err := e.NewEncoder().Bytes([]byte(s))
+		if err != nil {
+			t.Fatalf("Failed to encode test page: %v", err)
+		}
+		return b
+	}
+	testCases := []struct {
+		name        string
+		contentType string
+		body        []byte
+		wantTitle   string
+		wantHeading string
+		wantName    string
+	}{
+		{
+			name:        "Shift_JIS from meta",
+			contentType: "text/html",
+			body:        encode(japanese.ShiftJIS, `<html><head><meta charset="Shift_JIS"><title>日本語のページ</title></head><body><h1>見出し</h1></body></html>`),
+			wantTitle:   "日本語のページ",
+			wantHeading: "見出し",
+			wantName:    "shift_jis",
+		},
+		{
+			name:        "windows-1252 from header",
+			contentType: "text/html; charset=windows-1252",
+			body:        encode(charmap.Windows1252, `<html><head><title>Café – Menü</title></head><body><h1>Crème brûlée</h1></body></html>`),
+			wantTitle:   "Café – Menü",
+			wantHeading: "Crème brûlée",
+			wantName:    "windows-1252",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tc.contentType)
+				w.Write(tc.body)
+			}))
+			defer server.Close()
+
+			result, err := New(WithLinkCheck(false)).Analyze(t.Context(), server.URL)
+			if err != nil {
+				t.Fatalf("Analyze failed unexpectedly: %v", err)
+			}
+			if result.PageTitle != tc.wantTitle {
+				t.Errorf("Expected title %q, got %q", tc.wantTitle, result.PageTitle)
+			}
+			if len(result.Outline.Headings) != 1 || !strings.Contains(result.Outline.Headings[0].Text, tc.wantHeading) {
+				t.Errorf("Expected heading %q, got %+v", tc.wantHeading, result.Outline.Headings)
+			}
+			if result.Encoding.Name != tc.wantName {
+				t.Errorf("Expected encoding %s, got %+v", tc.wantName, result.Encoding)
+			}
+		})
+	}
+}
@@ -0,0 +1,378 @@
+package analyzer
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// FormKind classifies what a form is for
+type FormKind string
+
+const (
+	FormLogin         FormKind = "login"
+	FormSignup        FormKind = "signup"
+	FormPasswordReset FormKind = "password_reset"
+	FormSearch        FormKind = "search"
+	FormNewsletter    FormKind = "newsletter"
+	FormPayment       FormKind = "payment"
+	FormContact       FormKind = "contact"
+	FormOther         FormKind = "other" // no kind scored high enough
+)
+
+// formKinds lists the kinds in the order ties are broken
+var formKinds = []FormKind{FormLogin, FormSignup, FormPasswordReset, FormPayment, FormSearch, FormNewsletter, FormContact}
+
+// minFormConfidence is the score a kind needs before a form is classified as it
+const minFormConfidence = 0.4
+
+// FormsReport lists the forms of a document
+type FormsReport struct {
+	Forms []FormInfo `json:"forms"` // in document order
+	// Paths of password inputs that belong to no form, which scripts usually submit
+	PasswordFieldsOutsideForms []string `json:"password_fields_outside_forms"`
+}
+
+// FormInfo describes one <form> element
+type FormInfo struct {
+	Path           string          `json:"path"`   // CSS-like selector of the form
+	Action         string          `json:"action"` // absolute URL the form submits to
+	Method         string          `json:"method"` // GET or POST
+	Inputs         []FormInput     `json:"inputs"`
+	SubmitControls []SubmitControl `json:"submit_controls"`
+	Kind           FormKind        `json:"kind"`
+	Confidence     float64         `json:"confidence"` // 0 to 1
+	Signals        []string        `json:"signals"`    // evidence for Kind
+	Issues         []string        `json:"issues"`     // security problems with how credentials are submitted
+}
+
+// FormInput is a field of a form
+type FormInput struct {
+	Tag          string `json:"tag"`  // input, select or textarea
+	Type         string `json:"type"` // type attribute of inputs, lowercased, "text" if missing; the tag otherwise
+	Name         string `json:"name,omitempty"`
+	ID           string `json:"id,omitempty"`
+	Autocomplete string `json:"autocomplete,omitempty"`
+	Required     bool   `json:"required"`
+}
+
+// SubmitControl is a control that submits a form
+type SubmitControl struct {
+	Tag  string `json:"tag"`  // button or input
+	Type string `json:"type"` // submit or image
+	Name string `json:"name,omitempty"`
+	Text string `json:"text,omitempty"` // button text, value or alt text
+}
+
+// HasPassword reports whether the form has a password field
+func (f FormInfo) HasPassword() bool {
+	return f.count("password") > 0
+}
+
+// ConfidencePercent returns the confidence as a whole percentage, for display
+func (f FormInfo) ConfidencePercent() int {
+	return int(f.Confidence*100 + 0.5)
+}
+
+// count returns the number of inputs of the given type
+func (f FormInfo) count(inputType string) int {
+	n := 0
+	for _, in := range f.Inputs {
+		if in.Type == inputType {
+			n++
+		}
+	}
+	return n
+}
+
+// Has reports whether any form was classified as kind
+func (r FormsReport) Has(kind FormKind) bool {
+	for _, f := range r.Forms {
+		if f.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// inventoryForms describes every form in doc. pageURL is the document's URL, which empty
+// actions submit to and which decides what is cross-origin; base resolves other actions.
+func inventoryForms(doc *html.Node, pageURL, base *url.URL) FormsReport {
+	report := FormsReport{Forms: []FormInfo{}, PasswordFieldsOutsideForms: []string{}}
+	formNodes := []*html.Node{}
+	formsByID := map[string]int{}
+	controls := map[int][]*html.Node{} // form index to its controls
+	var orphans []*html.Node
+
+	var walk func(n *html.Node, form int)
+	walk = func(n *html.Node, form int) {
+		if n.Type == html.ElementNode {
+			switch n.DataAtom {
+			case atom.Form:
+				// Nested forms are dropped by the HTML parser, so a form never encloses another
+				form = len(formNodes)
+				formNodes = append(formNodes, n)
+				if id := attrValue(n, "id"); id != "" {
+					if _, dup := formsByID[id]; !dup {
+						formsByID[id] = form
+					}
+				}
+			case atom.Input, atom.Select, atom.Textarea, atom.Button:
+				// The form attribute overrides the enclosing form; it is resolved after the walk
+				// because it may name a form that comes later in the document
+				if _, ok := attrLookup(n, "form"); ok || form < 0 {
+					orphans = append(orphans, n)
+				} else {
+					controls[form] = append(controls[form], n)
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c, form)
+		}
+	}
+	walk(doc, -1)
+
+	// Controls outside forms, or associated with one through the form attribute
+	for _, n := range orphans {
+		if ref, ok := attrLookup(n, "form"); ok {
+			if i, found := formsByID[ref]; found {
+				controls[i] = append(controls[i], n)
+				continue
+			}
+		}
+		if n.DataAtom == atom.Input && strings.EqualFold(attrValue(n, "type"), "password") {
+			report.PasswordFieldsOutsideForms = append(report.PasswordFieldsOutsideForms, cssPath(n))
+		}
+	}
+
+	for i, n := range formNodes {
+		report.Forms = append(report.Forms, describeForm(n, controls[i], pageURL, base))
+	}
+	return report
+}
+
+// describeForm builds the FormInfo of a form element and its controls
+func describeForm(n *html.Node, controls []*html.Node, pageURL, base *url.URL) FormInfo {
+	info := FormInfo{Path: cssPath(n), Method: "GET", Inputs: []FormInput{}, SubmitControls: []SubmitControl{}, Signals: []string{}, Issues: []string{}}
+	if strings.EqualFold(strings.TrimSpace(attrValue(n, "method")), "post") {
+		info.Method = "POST"
+	}
+
+	// An empty or missing action submits to the document's own URL, not to <base href>
+	action := pageURL
+	if raw := strings.TrimSpace(attrValue(n, "action")); raw != "" {
+		if u, err := base.Parse(raw); err == nil {
+			action = u
+		}
+	}
+	info.Action = action.String()
+
+	for _, c := range controls {
+		switch c.DataAtom {
+		case atom.Button:
+			if t := strings.ToLower(strings.TrimSpace(attrValue(c, "type"))); t == "" || t == "submit" {
+				info.SubmitControls = append(info.SubmitControls, SubmitControl{Tag: "button", Type: "submit", Name: attrValue(c, "name"), Text: textWithAlt(c)})
+			}
+		case atom.Input:
+			t := strings.ToLower(strings.TrimSpace(attrValue(c, "type")))
+			switch t {
+			case "submit":
+				info.SubmitControls = append(info.SubmitControls, SubmitControl{Tag: "input", Type: t, Name: attrValue(c, "name"), Text: attrValue(c, "value")})
+				continue
+			case "image":
+				info.SubmitControls = append(info.SubmitControls, SubmitControl{Tag: "input", Type: t, Name: attrValue(c, "name"), Text: attrValue(c, "alt")})
+				continue
+			case "button", "reset":
+				continue
+			case "":
+				t = "text"
+			}
+			info.Inputs = append(info.Inputs, formInput(c, t))
+		default:
+			info.Inputs = append(info.Inputs, formInput(c, c.Data))
+		}
+	}
+
+	classifyForm(&info, n)
+
+	if info.HasPassword() {
+		if action.Scheme == "http" {
+			info.Issues = append(info.Issues, "credentials are submitted over plain HTTP")
+		}
+		if action.Scheme != pageURL.Scheme || action.Host != pageURL.Host {
+			info.Issues = append(info.Issues, fmt.Sprintf("credentials are submitted to another origin (%s://%s)", action.Scheme, action.Host))
+		}
+		if info.Method == "GET" {
+			info.Issues = append(info.Issues, "credentials are submitted with GET and end up in the URL")
+		}
+	}
+	return info
+}
+
+// formInput describes a form field of the given type
+func formInput(n *html.Node, inputType string) FormInput {
+	_, required := attrLookup(n, "required")
+	return FormInput{
+		Tag:          n.Data,
+		Type:         inputType,
+		Name:         attrValue(n, "name"),
+		ID:           attrValue(n, "id"),
+		Autocomplete: strings.ToLower(strings.TrimSpace(attrValue(n, "autocomplete"))),
+		Required:     required,
+	}
+}
+
+// formKeywords are words in a form's attributes, action, field names and button texts that hint at its kind
+var formKeywords = map[FormKind][]string{
+	FormLogin:         {"login", "log in", "log-in", "signin", "sign in", "sign-in", "logon"},
+	FormSignup:        {"signup", "sign up", "sign-up", "register", "registration", "create account", "join"},
+	FormPasswordReset: {"reset", "forgot", "recover", "lost password"},
+	FormSearch:        {"search", "find"},
+	FormNewsletter:    {"newsletter", "subscribe", "mailing list"},
+	FormPayment:       {"checkout", "payment", "pay now", "billing", "card number", "cvv", "cvc"},
+	FormContact:       {"contact", "message", "enquiry", "inquiry", "feedback"},
+}
+
+// classifyForm scores the form for each kind from its fields and wording, and sets the
+// best-scoring kind with the signals behind it
+func classifyForm(info *FormInfo, n *html.Node) {
+	scores := map[FormKind]float64{}
+	signals := map[FormKind][]string{}
+	add := func(kind FormKind, weight float64, signal string) {
+		scores[kind] += weight
+		signals[kind] = append(signals[kind], signal)
+	}
+
+	passwords := info.count("password")
+	emails := info.count("email")
+	visible := 0
+	autocomplete := map[string]bool{}
+	var names []string
+	for _, in := range info.Inputs {
+		if in.Type != "hidden" {
+			visible++
+		}
+		for _, token := range strings.Fields(in.Autocomplete) {
+			autocomplete[token] = true
+		}
+		names = append(names, strings.ToLower(in.Name+" "+in.ID))
+	}
+	hasName := func(words ...string) bool {
+		for _, name := range names {
+			for _, w := range words {
+				if strings.Contains(name, w) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	// Wording of the form itself, its action and its buttons
+	wording := []string{attrValue(n, "id"), attrValue(n, "class"), attrValue(n, "name"), attrValue(n, "aria-label"), info.Action}
+	for _, s := range info.SubmitControls {
+		wording = append(wording, s.Text, s.Name)
+	}
+	text := strings.ToLower(strings.Join(wording, " "))
+	for _, kind := range formKinds {
+		for _, kw := range formKeywords[kind] {
+			if strings.Contains(text, kw) {
+				add(kind, 0.4, fmt.Sprintf("keyword %q in the form, action or buttons", kw))
+				break
+			}
+		}
+	}
+
+	switch {
+	case passwords == 1:
+		add(FormLogin, 0.4, "one password field")
+	case passwords > 1:
+		add(FormSignup, 0.4, fmt.Sprintf("%d password fields (password confirmation)", passwords))
+	}
+	if autocomplete["current-password"] {
+		add(FormLogin, 0.4, `autocomplete="current-password"`)
+	}
+	if autocomplete["new-password"] {
+		add(FormSignup, 0.3, `autocomplete="new-password"`)
+		if passwords > 0 && !autocomplete["current-password"] && !hasName("email", "user", "name") {
+			add(FormPasswordReset, 0.3, "only new password fields")
+		}
+	}
+	if autocomplete["username"] || hasName("user", "login") || (passwords > 0 && emails > 0) {
+		add(FormLogin, 0.2, "username or email field")
+	}
+	if detectLoginForm(n) {
+		add(FormLogin, 0.2, "matches the username/password or PIN form heuristic")
+	}
+	if autocomplete["given-name"] || autocomplete["family-name"] || hasName("first_name", "firstname", "last_name", "lastname") {
+		add(FormSignup, 0.2, "personal name fields")
+	}
+	if passwords == 0 && visible == 1 && (emails == 1 || hasName("email", "user")) && scores[FormPasswordReset] > 0 {
+		add(FormPasswordReset, 0.2, "single email or username field")
+	}
+
+	if info.count("search") > 0 {
+		add(FormSearch, 0.5, `input type="search"`)
+	}
+	if hasRole(n, "search") {
+		add(FormSearch, 0.4, `role="search"`)
+	}
+	for _, in := range info.Inputs {
+		switch strings.ToLower(in.Name) {
+		case "q", "s", "query", "search", "keyword", "keywords":
+			add(FormSearch, 0.3, fmt.Sprintf("search parameter name %q", in.Name))
+		}
+	}
+	if scores[FormSearch] > 0 && info.Method == "GET" {
+		add(FormSearch, 0.1, "submitted with GET")
+	}
+
+	if passwords == 0 && emails == 1 && visible <= 2 {
+		add(FormNewsletter, 0.3, "a single email field")
+	}
+
+	for token := range autocomplete {
+		if strings.HasPrefix(token, "cc-") {
+			add(FormPayment, 0.6, "credit card autocomplete fields")
+			break
+		}
+	}
+	if hasName("card", "cvv", "cvc", "expiry", "iban") {
+		add(FormPayment, 0.4, "payment card field names")
+	}
+
+	if info.count("textarea") > 0 {
+		add(FormContact, 0.3, "free-text message field")
+		if emails > 0 || autocomplete["email"] {
+			add(FormContact, 0.2, "email field for a reply")
+		}
+	}
+
+	best := FormOther
+	for _, kind := range formKinds {
+		if scores[kind] >= minFormConfidence && scores[kind] > scores[best] {
+			best = kind
+		}
+	}
+	info.Kind = best
+	if best != FormOther {
+		info.Confidence = min(scores[best], 1)
+		info.Signals = append(info.Signals, signals[best]...)
+		sort.Strings(info.Signals)
+	}
+}
+
+// hasRole reports whether n or one of its ancestors has the given ARIA role
+func hasRole(n *html.Node, role string) bool {
+	for e := n; e != nil; e = e.Parent {
+		if e.Type == html.ElementNode && strings.EqualFold(strings.TrimSpace(attrValue(e, "role")), role) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,209 @@
+package analyzer
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestInventoryForms_Classification(t *testing.T) {
+	testCases := []struct {
+		name string
+		form string
+		want FormKind
+	}{
+		{
+			name: "Login",
+			form: `<form action="/session" method="post"><input name="username" autocomplete="username">
+				<input type="password" name="password" autocomplete="current-password"><button>Log in</button></form>`,
+			want: FormLogin,
+		},
+		{
+			name: "Login without hints",
+			form: `<form method="post"><input type="email" name="e"><input type="password" name="p"><input type="submit" value="Go"></form>`,
+			want: FormLogin,
+		},
+		{
+			name: "PIN",
+			form: `<form method="post"><input type="password" name="pin"><input type="submit" value="Enter"></form>`,
+			want: FormLogin,
+		},
+		{
+			name: "Signup",
+			form: `<form action="/register" method="post"><input name="first_name"><input type="email" name="email">
+				<input type="password" name="password" autocomplete="new-password"><input type="password" name="confirm" autocomplete="new-password">
+				<button>Create account</button></form>`,
+			want: FormSignup,
+		},
+		{
+			name: "Password reset",
+			form: `<form action="/password/forgot" method="post"><input type="email" name="email"><button>Send reset link</button></form>`,
+			want: FormPasswordReset,
+		},
+		{
+			name: "Search",
+			form: `<form action="/search" role="search"><input type="search" name="q"><button>Go</button></form>`,
+			want: FormSearch,
+		},
+		{
+			name: "Newsletter",
+			form: `<form action="/lists/add" method="post"><input type="email" name="email"><button>Subscribe</button></form>`,
+			want: FormNewsletter,
+		},
+		{
+			name: "Payment",
+			form: `<form action="/checkout" method="post"><input name="cardnumber" autocomplete="cc-number">
+				<input name="exp" autocomplete="cc-exp"><input name="cvc" autocomplete="cc-csc"><button>Pay</button></form>`,
+			want: FormPayment,
+		},
+		{
+			name: "Contact",
+			form: `<form action="/contact" method="post"><input name="name"><input type="email" name="email">
+				<textarea name="body"></textarea><button>Send</button></form>`,
+			want: FormContact,
+		},
+		{
+			name: "Other",
+			form: `<form method="post"><select name="lang"><option>en</option></select><button>Save</button></form>`,
+			want: FormOther,
+		},
+	}
+
+	pageURL, _ := url.Parse("https://example.com/page")
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := html.Parse(strings.NewReader("<html><body>" + tc.form + "</body></html>"))
+			if err != nil {
+				t.Fatalf("Failed to parse HTML: %v", err)
+			}
+			report := inventoryForms(doc, pageURL, pageURL)
+			if len(report.Forms) != 1 {
+				t.Fatalf("Expected 1 form, got %d", len(report.Forms))
+			}
+			f := report.Forms[0]
+			if f.Kind != tc.want {
+				t.Errorf("Expected kind %q, got %q (confidence %.2f, signals %v)", tc.want, f.Kind, f.Confidence, f.Signals)
+			}
+			if tc.want != FormOther && (f.Confidence < minFormConfidence || f.Confidence > 1 || len(f.Signals) == 0) {
+				t.Errorf("Expected a confidence in [%.1f, 1] with signals, got %.2f and %v", minFormConfidence, f.Confidence, f.Signals)
+			}
+		})
+	}
+}
+
+func TestInventoryForms_Details(t *testing.T) {
+	page := `<html><head><base href="https://cdn.example.com/"></head><body>
+		<form id="login" action="login" method="post">
+			<input name="user" required autocomplete="Username">
+			<input type="password" name="pass">
+			<input type="hidden" name="csrf">
+			<input type="button" value="Show">
+			<button type="submit" name="go">Sign <b>in</b></button>
+		</form>
+		<form></form>
+		<input type="password" name="outside">
+		<input type="password" name="owned" form="login">
+		<input type="password" name="dangling" form="missing">
+	</body></html>`
+	doc, err := html.Parse(strings.NewReader(page))
+	if err != nil {
+		t.Fatalf("Failed to parse HTML: %v", err)
+	}
+	pageURL, _ := url.Parse("https://example.com/account/")
+	base, _ := url.Parse("https://cdn.example.com/")
+	report := inventoryForms(doc, pageURL, base)
+
+	if len(report.Forms) != 2 {
+		t.Fatalf("Expected 2 forms, got %d", len(report.Forms))
+	}
+	f := report.Forms[0]
+	if f.Path != "html > body > form#login:nth-of-type(1)" || f.Action != "https://cdn.example.com/login" || f.Method != "POST" {
+		t.Errorf("Expected form#login posting to the base-resolved action, got path %q action %q method %q", f.Path, f.Action, f.Method)
+	}
+	wantInputs := []FormInput{
+		{Tag: "input", Type: "text", Name: "user", Autocomplete: "username", Required: true},
+		{Tag: "input", Type: "password", Name: "pass"},
+		{Tag: "input", Type: "hidden", Name: "csrf"},
+		{Tag: "input", Type: "password", Name: "owned"},
+	}
+	if !reflect.DeepEqual(f.Inputs, wantInputs) {
+		t.Errorf("Expected inputs %+v, got %+v", wantInputs, f.Inputs)
+	}
+	wantSubmit := []SubmitControl{{Tag: "button", Type: "submit", Name: "go", Text: "Sign in"}}
+	if !reflect.DeepEqual(f.SubmitControls, wantSubmit) {
+		t.Errorf("Expected submit controls %+v, got %+v", wantSubmit, f.SubmitControls)
+	}
+	if len(f.Issues) != 1 || !strings.Contains(f.Issues[0], "another origin (https://cdn.example.com)") {
+		t.Errorf("Expected a cross-origin credentials issue, got %v", f.Issues)
+	}
+
+	empty := report.Forms[1]
+	if empty.Action != pageURL.String() || empty.Method != "GET" || empty.Kind != FormOther || len(empty.Issues) != 0 {
+		t.Errorf("Expected an empty form to GET the page URL with no kind or issues, got %+v", empty)
+	}
+
+	wantOutside := []string{"html > body > input:nth-of-type(1)", "html > body > input:nth-of-type(3)"}
+	if !reflect.DeepEqual(report.PasswordFieldsOutsideForms, wantOutside) {
+		t.Errorf("Expected password fields outside forms %v, got %v", wantOutside, report.PasswordFieldsOutsideForms)
+	}
+}
+
+func TestInventoryForms_CredentialIssues(t *testing.T) {
+	testCases := []struct {
+		name    string
+		pageURL string
+		form    string
+		want    []string // substrings, one per issue
+	}{
+		{
+			name:    "HTTPS same origin",
+			pageURL: "https://example.com/",
+			form:    `<form action="/login" method="post"><input type="password"></form>`,
+		},
+		{
+			name:    "Plain HTTP page",
+			pageURL: "http://example.com/",
+			form:    `<form method="post"><input type="password"></form>`,
+			want:    []string{"plain HTTP"},
+		},
+		{
+			name:    "Downgrade to HTTP",
+			pageURL: "https://example.com/",
+			form:    `<form action="http://example.com/login" method="post"><input type="password"></form>`,
+			want:    []string{"plain HTTP", "another origin (http://example.com)"},
+		},
+		{
+			name:    "GET",
+			pageURL: "https://example.com/",
+			form:    `<form><input type="password"></form>`,
+			want:    []string{"GET"},
+		},
+		{
+			name:    "No password",
+			pageURL: "http://example.com/",
+			form:    `<form action="https://other.example/search"><input name="q"></form>`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := html.Parse(strings.NewReader("<html><body>" + tc.form + "</body></html>"))
+			if err != nil {
+				t.Fatalf("Failed to parse HTML: %v", err)
+			}
+			pageURL, _ := url.Parse(tc.pageURL)
+			issues := inventoryForms(doc, pageURL, pageURL).Forms[0].Issues
+			if len(issues) != len(tc.want) {
+				t.Fatalf("Expected %d issue(s), got %v", len(tc.want), issues)
+			}
+			for i, want := range tc.want {
+				if !strings.Contains(issues[i], want) {
+					t.Errorf("Expected issue %d to mention %q, got %q", i, want, issues[i])
+				}
+			}
+		})
+	}
+}
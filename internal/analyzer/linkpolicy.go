@@ -0,0 +1,175 @@
+package analyzer
+
+import (
+	"net"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// LinkScope decides how much of a site counts as internal
+type LinkScope string
+
+const (
+	LinkScopeHost   LinkScope = "host"   // the same host, and port unless it is the scheme's default
+	LinkScopeDomain LinkScope = "domain" // the same registrable domain, e.g. www.example.com and blog.example.com
+)
+
+// ParseLinkScope converts a flag or API value into a LinkScope
+func ParseLinkScope(s string) (LinkScope, bool) {
+	switch scope := LinkScope(strings.ToLower(strings.TrimSpace(s))); scope {
+	case LinkScopeHost, LinkScopeDomain:
+		return scope, true
+	}
+	return "", false
+}
+
+// LinkPolicy decides which links are internal to the analyzed page
+type LinkPolicy struct {
+	Scope LinkScope `json:"scope"`
+	// MatchScheme makes links on another scheme external, e.g. http:// links on an https:// page
+	MatchScheme bool `json:"match_scheme"`
+	// Aliases are other hosts of the same site, e.g. a CDN or a former domain. With
+	// LinkScopeDomain, every host in an alias's registrable domain is internal too.
+	Aliases []string `json:"aliases"`
+}
+
+// DefaultLinkPolicy is the policy used by New: the page's own host over the same scheme
+var DefaultLinkPolicy = LinkPolicy{Scope: LinkScopeHost, MatchScheme: true, Aliases: []string{}}
+
+// LinkPolicy returns the policy the analyzer classifies links with, so callers following
+// links, such as a crawler, agree with its reports
+func (a *Analyzer) LinkPolicy() LinkPolicy {
+	return a.linkPolicy.withDefaults()
+}
+
+// DomainLinks counts the links to one destination host
+type DomainLinks struct {
+	Domain   string `json:"domain"` // lowercase host name, without port
+	Count    int    `json:"count"`
+	Internal bool   `json:"internal"` // every link to this host is internal
+}
+
+// String describes the policy, e.g. "same host and scheme, aliases: cdn.example.com"
+func (p LinkPolicy) String() string {
+	s := "same registrable domain"
+	if p.Scope != LinkScopeDomain {
+		s = "same host"
+	}
+	if p.MatchScheme {
+		s += " and scheme"
+	} else {
+		s += ", any scheme"
+	}
+	if len(p.Aliases) > 0 {
+		s += ", aliases: " + strings.Join(p.Aliases, ", ")
+	}
+	return s
+}
+
+// Internal reports whether link is internal to a page at base under the policy
+func (p LinkPolicy) Internal(base, link *url.URL) bool {
+	if p.MatchScheme && !strings.EqualFold(link.Scheme, base.Scheme) {
+		return false
+	}
+	if p.sameSite(base, link) {
+		return true
+	}
+	for _, alias := range p.Aliases {
+		if aliasURL := parseAlias(alias); aliasURL != nil && p.sameSite(&url.URL{Scheme: link.Scheme, Host: aliasURL.Host}, link) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseAlias accepts an alias as a host, host:port or URL, returning nil if it has no host
+func parseAlias(alias string) *url.URL {
+	alias = strings.TrimSpace(alias)
+	if !strings.Contains(alias, "://") {
+		alias = "//" + alias
+	}
+	u, err := url.Parse(alias)
+	if err != nil || u.Host == "" {
+		return nil
+	}
+	return u
+}
+
+// sameSite compares the hosts of a and b according to the policy's scope
+func (p LinkPolicy) sameSite(a, b *url.URL) bool {
+	if p.Scope == LinkScopeDomain {
+		return registrableDomain(a.Hostname()) == registrableDomain(b.Hostname())
+	}
+	return strings.EqualFold(a.Hostname(), b.Hostname()) && explicitPort(a) == explicitPort(b)
+}
+
+// explicitPort returns the port of u, or "" if it is omitted or the default for the scheme
+func explicitPort(u *url.URL) string {
+	switch port := u.Port(); {
+	case port == "80" && strings.EqualFold(u.Scheme, "http"), port == "443" && strings.EqualFold(u.Scheme, "https"):
+		return ""
+	default:
+		return port
+	}
+}
+
+// registrableDomain returns the eTLD+1 of host according to the public suffix list, e.g.
+// "example.co.uk" for "www.example.co.uk". IP addresses, single-label hosts and public
+// suffixes themselves are returned as they are.
+func registrableDomain(host string) string {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	if domain, err := publicsuffix.EffectiveTLDPlusOne(host); err == nil {
+		return domain
+	}
+	return host
+}
+
+// groupLinksByDomain counts links per destination host, most linked first
+func groupLinksByDomain(links []PageLink) []DomainLinks {
+	byDomain := map[string]*DomainLinks{}
+	for _, l := range links {
+		u, err := url.Parse(l.URL)
+		if err != nil || u.Hostname() == "" {
+			continue
+		}
+		host := strings.ToLower(u.Hostname())
+		d, ok := byDomain[host]
+		if !ok {
+			d = &DomainLinks{Domain: host, Internal: l.Internal}
+			byDomain[host] = d
+		}
+		d.Count++
+		d.Internal = d.Internal && l.Internal
+	}
+
+	domains := make([]DomainLinks, 0, len(byDomain))
+	for _, d := range byDomain {
+		domains = append(domains, *d)
+	}
+	sort.Slice(domains, func(i, j int) bool {
+		if domains[i].Count != domains[j].Count {
+			return domains[i].Count > domains[j].Count
+		}
+		return domains[i].Domain < domains[j].Domain
+	})
+	return domains
+}
+
+// withDefaults returns a copy of p with a valid scope and a non-nil alias list
+func (p LinkPolicy) withDefaults() LinkPolicy {
+	if _, ok := ParseLinkScope(string(p.Scope)); !ok {
+		p.Scope = LinkScopeHost
+	}
+	p.Aliases = slices.Clone(p.Aliases)
+	if p.Aliases == nil {
+		p.Aliases = []string{}
+	}
+	return p
+}
@@ -0,0 +1,97 @@
+package analyzer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestLinkPolicy_Internal(t *testing.T) {
+	host := DefaultLinkPolicy
+	anyScheme := LinkPolicy{Scope: LinkScopeHost}
+	domain := LinkPolicy{Scope: LinkScopeDomain}
+	aliased := LinkPolicy{Scope: LinkScopeHost, Aliases: []string{"cdn.example.net", "https://old.example.org"}}
+	aliasedDomain := LinkPolicy{Scope: LinkScopeDomain, Aliases: []string{"example.net"}}
+
+	testCases := []struct {
+		name   string
+		policy LinkPolicy
+		base   string
+		link   string
+		want   bool
+	}{
+		{"Same host", host, "https://example.com/", "https://example.com/about", true},
+		{"Default port", host, "https://example.com/", "https://example.com:443/about", true},
+		{"Other port", host, "https://example.com/", "https://example.com:8443/about", false},
+		{"Host case", host, "https://Example.com/", "https://example.COM/", true},
+		{"Subdomain", host, "https://example.com/", "https://www.example.com/", false},
+		{"Scheme must match", host, "https://example.com/", "http://example.com/", false},
+		{"Scheme-insensitive", anyScheme, "https://example.com/", "http://example.com:80/", true},
+		{"Registrable domain", domain, "https://www.example.com/", "https://blog.example.com/", true},
+		{"Public suffix", domain, "https://a.example.co.uk/", "https://b.example.co.uk/", true},
+		{"Different registrable domain", domain, "https://a.example.co.uk/", "https://other.co.uk/", false},
+		{"Private suffix", domain, "https://alice.github.io/", "https://bob.github.io/", false},
+		{"IP address", domain, "http://127.0.0.1/", "http://10.0.0.1/", false},
+		{"Alias", aliased, "https://example.com/", "https://cdn.example.net/app.js", true},
+		{"Alias given as URL", aliased, "https://example.com/", "https://old.example.org/page", true},
+		{"Alias host only", aliased, "https://example.com/", "https://img.example.net/", false},
+		{"Alias domain", aliasedDomain, "https://example.com/", "https://img.example.net/", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			base, _ := url.Parse(tc.base)
+			link, _ := url.Parse(tc.link)
+			if got := tc.policy.Internal(base, link); got != tc.want {
+				t.Errorf("Expected Internal(%s, %s) to be %v under %+v", tc.base, tc.link, tc.want, tc.policy)
+			}
+		})
+	}
+}
+
+func TestGroupLinksByDomain(t *testing.T) {
+	links := []PageLink{
+		{URL: "https://example.com/a", Internal: true},
+		{URL: "https://other.org/", Internal: false},
+		{URL: "https://EXAMPLE.com:443/b", Internal: true},
+		{URL: "http://example.com/c", Internal: false},
+		{URL: "https://blog.other.org/", Internal: false},
+	}
+	want := []DomainLinks{
+		{Domain: "example.com", Count: 3, Internal: false},
+		{Domain: "blog.other.org", Count: 1, Internal: false},
+		{Domain: "other.org", Count: 1, Internal: false},
+	}
+	if got := groupLinksByDomain(links); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+}
+
+func TestAnalyze_LinkPolicy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body>
+			<a href="/local">Local</a>
+			<a href="http://cdn.example.net/file">CDN</a>
+			<a href="https://example.org/">Elsewhere</a>
+		</body></html>`))
+	}))
+	defer server.Close()
+
+	policy := LinkPolicy{Scope: LinkScopeHost, Aliases: []string{"cdn.example.net"}}
+	result, err := New(WithLinkCheck(false), WithLinkPolicy(policy)).Analyze(t.Context(), server.URL)
+	if err != nil {
+		t.Fatalf("Analyze failed unexpectedly: %v", err)
+	}
+	if result.InternalLinksCount != 2 || result.ExternalLinksCount != 1 {
+		t.Errorf("Expected 2 internal and 1 external link, got %d and %d", result.InternalLinksCount, result.ExternalLinksCount)
+	}
+	if !reflect.DeepEqual(result.LinkPolicy, policy) {
+		t.Errorf("Expected the policy %+v to be echoed, got %+v", policy, result.LinkPolicy)
+	}
+	if len(result.LinkDomains) != 3 {
+		t.Errorf("Expected links to 3 domains, got %+v", result.LinkDomains)
+	}
+}
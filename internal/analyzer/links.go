@@ -0,0 +1,435 @@
+package analyzer
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync" // For WaitGroup concurrent link checks
+	"syscall"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// LinkErrorClass categorizes why a link check failed
+type LinkErrorClass string
+
+const (
+	LinkErrorNone              LinkErrorClass = ""
+	LinkErrorHTTPStatus        LinkErrorClass = "http_status"        // server answered with a 4xx/5xx status
+	LinkErrorDNS               LinkErrorClass = "dns"                // host name could not be resolved
+	LinkErrorTimeout           LinkErrorClass = "timeout"            // no answer within the link timeout
+	LinkErrorConnectionRefused LinkErrorClass = "connection_refused" // nothing listening on the target port
+	LinkErrorTLS               LinkErrorClass = "tls"                // handshake or certificate verification failed
+	LinkErrorTooManyRedirects  LinkErrorClass = "too_many_redirects" // redirect limit of the HTTP client was hit
+	LinkErrorInvalidURL        LinkErrorClass = "invalid_url"        // the link could not be turned into a request
+	LinkErrorNetwork           LinkErrorClass = "network"            // any other transport-level failure
+)
+
+// LinkCheckResult holds the outcome of checking a single link
+type LinkCheckResult struct {
+	URL           string         `json:"url"`
+	Tag           string         `json:"tag"`            // element the link came from: "a" or "link", or e.g. "img" for a subresource
+	Text          string         `json:"text,omitempty"` // anchor text for <a> links
+	Method        string         `json:"method"`         // HTTP method that produced the final outcome (HEAD, or GET after fallback)
+	StatusCode    int            `json:"status_code"`    // final status code, 0 if no response was received
+	ContentType   string         `json:"content_type,omitempty"`
+	ErrorClass    LinkErrorClass `json:"error_class,omitempty"`
+	Error         string         `json:"error,omitempty"`          // underlying error message, if any
+	RedirectChain []string       `json:"redirect_chain,omitempty"` // URLs followed after the original one, in order; the last one is the final URL
+	Latency       time.Duration  `json:"latency_ns"`
+	// SkippedByRobots is set when robots.txt disallows the link, in which case it was not requested
+	SkippedByRobots bool `json:"skipped_by_robots,omitempty"`
+	// Occurrences is how many links and resources of the page normalize to URL; Sources lists them
+	Occurrences int          `json:"occurrences,omitempty"`
+	Sources     []LinkSource `json:"sources,omitempty"`
+	Attempts    int          `json:"attempts,omitempty"` // checks made, more than 1 when transient failures were retried
+
+	retryAfter time.Duration // wait asked for by a 429 or 503 response
+}
+
+// LinkSource is one place in the page that references a checked URL
+type LinkSource struct {
+	URL  string `json:"url"` // absolute URL as referenced, before normalization
+	Tag  string `json:"tag"`
+	Text string `json:"text,omitempty"`
+	Path string `json:"path,omitempty"` // CSS-like selector of the element
+}
+
+// Accessible reports whether the link responded with a non-error status
+func (r LinkCheckResult) Accessible() bool {
+	return r.ErrorClass == LinkErrorNone
+}
+
+// IsImage reports whether the link answered with an image content type
+func (r LinkCheckResult) IsImage() bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(r.ContentType)), "image/")
+}
+
+// LatencyMillis returns the latency in whole milliseconds, for display
+func (r LinkCheckResult) LatencyMillis() int64 {
+	return r.Latency.Milliseconds()
+}
+
+// checkLinkAccessibility checks a list of links concurrently and returns the ones that are
+// inaccessible and the ones skipped because of robots.txt, each sorted by URL, and how many
+// unique URLs were checked. Links are normalized first and each unique URL is checked once,
+// with every occurrence as a source. Links that are still pending when ctx is cancelled are
+// not checked and are left out of the result.
+func (a *Analyzer) checkLinkAccessibility(ctx context.Context, links []PageLink) (inaccessible, skipped []LinkCheckResult, checked int) {
+	inaccessible, skipped = []LinkCheckResult{}, []LinkCheckResult{}
+	if len(links) == 0 {
+		return inaccessible, skipped, 0
+	}
+
+	targets, sources := dedupeLinks(links, a.ignoredQueryParams)
+	slog.Debug("Deduplicated links", "links", len(links), "unique", len(targets))
+	a.reportProgress(Progress{Phase: PhaseCheckLinks, LinksTotal: len(targets)})
+	results := a.checkLinkResults(ctx, targets, func(checked int) {
+		a.reportProgress(Progress{Phase: PhaseCheckLinks, LinksChecked: checked, LinksTotal: len(targets)})
+	})
+
+	for _, res := range results {
+		res.Sources = sources[res.URL]
+		res.Occurrences = len(res.Sources)
+		switch {
+		case res.SkippedByRobots:
+			skipped = append(skipped, res)
+		case !res.Accessible():
+			slog.Debug("Link is inaccessible", "url", res.URL, "status_code", res.StatusCode, "error_class", res.ErrorClass)
+			inaccessible = append(inaccessible, res)
+		}
+	}
+	sort.SliceStable(inaccessible, func(i, j int) bool { return inaccessible[i].URL < inaccessible[j].URL })
+	sort.SliceStable(skipped, func(i, j int) bool { return skipped[i].URL < skipped[j].URL })
+	return inaccessible, skipped, len(results)
+}
+
+// checkLinkResults checks links concurrently, honouring robots.txt, the link concurrency limit
+// and the per-host throttle, and returns the result of every link that finished before ctx was
+// cancelled, in input order. onChecked, if not nil, is called with the number of links checked
+// so far after each one.
+func (a *Analyzer) checkLinkResults(ctx context.Context, links []PageLink, onChecked func(checked int)) []LinkCheckResult {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+
+	concurrencyLimit := a.linkConcurrency
+	if concurrencyLimit < 1 {
+		concurrencyLimit = 1
+	}
+	semaphore := make(chan struct{}, concurrencyLimit)
+
+	results := make([]LinkCheckResult, len(links))
+	finished := make([]bool, len(links))
+	checked := 0
+
+	for i, link := range links {
+		wg.Add(1)
+
+		// Each link first waits for a slot on its host, then for a global one, so links to a
+		// busy host queue up without holding global slots that other hosts could use
+		go func(i int, l PageLink) {
+			defer wg.Done()
+			release, ok := a.throttle.acquire(ctx, l.URL)
+			if !ok {
+				slog.Debug("Link check cancelled before starting", "url", l.URL, "error", ctx.Err())
+				return
+			}
+			defer release()
+			select {
+			case semaphore <- struct{}{}:
+			case <-ctx.Done():
+				slog.Debug("Link check cancelled before starting", "url", l.URL, "error", ctx.Err())
+				return
+			}
+			defer func() { <-semaphore }()
+
+			// Crawl-delay is waited for on ctx, so the link timeout only covers the requests
+			var res LinkCheckResult
+			allowed, err := a.robotsAllowed(ctx, l.URL)
+			switch {
+			case err != nil:
+				slog.Debug("Link check cancelled before starting", "url", l.URL, "error", err)
+				return
+			case allowed:
+				slog.Debug("Checking link accessibility", "url", l.URL)
+				var checked bool
+				if res, checked = a.checkLink(ctx, l); !checked {
+					slog.Debug("Link check cancelled while waiting for the host", "url", l.URL, "error", ctx.Err())
+					return
+				}
+			default:
+				slog.Debug("Skipping link disallowed by robots.txt", "url", l.URL)
+				res = LinkCheckResult{URL: l.URL, Tag: l.Tag, Text: l.Text, SkippedByRobots: true}
+			}
+			if ctx.Err() != nil {
+				return // the whole analysis was cancelled; this link was never really checked
+			}
+
+			mu.Lock() // Lock to prevent concurrent access to the counter from go routines
+			defer mu.Unlock()
+			results[i], finished[i] = res, true
+			checked++
+			if onChecked != nil {
+				onChecked(checked)
+			}
+		}(i, link)
+	}
+
+	wg.Wait()
+	done := results[:0]
+	for i, res := range results {
+		if finished[i] {
+			done = append(done, res)
+		}
+	}
+	return done
+}
+
+// checkLink checks a single link with HEAD, falling back to GET when HEAD is rejected. Transient
+// failures are retried after the server's Retry-After or a jittered exponential backoff, as
+// long as the wait fits in ctx. Waits for the host are made on ctx, and each request gets the
+// link timeout of its own. It returns false if ctx ended before the link could be checked.
+func (a *Analyzer) checkLink(ctx context.Context, l PageLink) (LinkCheckResult, bool) {
+	start := time.Now()
+	var res LinkCheckResult
+	for attempt := 1; ; attempt++ {
+		var checked bool
+		if res, checked = a.checkLinkOnce(ctx, l); !checked {
+			return res, false
+		}
+		res.Attempts = attempt
+		if !retryable(res) || attempt > a.maxRetries {
+			break
+		}
+
+		wait := backoff(a.retryBackoff, attempt)
+		if res.retryAfter > 0 {
+			wait = res.retryAfter
+		}
+		if wait > maxRetryWait {
+			slog.Debug("Not retrying link, Retry-After is too long", "url", l.URL, "retry_after", wait)
+			break
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			break // the analysis would end before the retry
+		}
+		if res.retryAfter > 0 {
+			a.throttle.block(l.URL, wait) // the whole host asked us to wait, not just this link
+		}
+		slog.Debug("Retrying link", "url", l.URL, "status_code", res.StatusCode, "error_class", res.ErrorClass, "attempt", attempt, "wait", wait)
+		if !sleep(ctx, wait) {
+			return res, false
+		}
+	}
+	res.Latency = time.Since(start)
+	return res, true
+}
+
+// checkLinkOnce makes one attempt at checking a link: HEAD, then GET if HEAD is rejected.
+// It returns false if ctx ended while waiting for the host's rate limit.
+func (a *Analyzer) checkLinkOnce(ctx context.Context, l PageLink) (LinkCheckResult, bool) {
+	res, checked := a.throttledProbe(ctx, http.MethodHead, l)
+	switch {
+	case !checked:
+	case res.StatusCode == http.StatusMethodNotAllowed:
+		// Retry with GET if HEAD is not allowed
+		res, checked = a.throttledProbe(ctx, http.MethodGet, l)
+	case res.StatusCode == 0 && res.ErrorClass == LinkErrorNetwork:
+		// Try GET if HEAD fails with a generic transport error; some servers mishandle HEAD.
+		// Timeouts, refused connections, DNS and TLS failures would fail the same way again.
+		res, checked = a.throttledProbe(ctx, http.MethodGet, l)
+	}
+	return res, checked
+}
+
+// throttledProbe waits on ctx until the host may receive another request, then probes the link
+// within the link timeout. It returns false if ctx ended while waiting.
+func (a *Analyzer) throttledProbe(ctx context.Context, method string, l PageLink) (LinkCheckResult, bool) {
+	if !a.throttle.wait(ctx, l.URL) {
+		return LinkCheckResult{URL: l.URL, Tag: l.Tag, Text: l.Text, Method: method}, false
+	}
+	probeCtx, cancel := withTimeout(ctx, a.linkTimeout)
+	defer cancel()
+	return a.probeLink(probeCtx, method, l), true
+}
+
+// probeLink issues a single request for the link and records its outcome
+func (a *Analyzer) probeLink(ctx context.Context, method string, l PageLink) LinkCheckResult {
+	res := LinkCheckResult{URL: l.URL, Tag: l.Tag, Text: l.Text, Method: method}
+
+	req, err := http.NewRequestWithContext(ctx, method, l.URL, nil)
+	if err != nil {
+		res.ErrorClass = LinkErrorInvalidURL
+		res.Error = err.Error()
+		return res
+	}
+	req.Header.Set("User-Agent", a.userAgent)
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		res.ErrorClass = classifyLinkError(err)
+		res.Error = err.Error()
+		return res
+	}
+	defer resp.Body.Close()
+
+	res.StatusCode = resp.StatusCode
+	res.ContentType = resp.Header.Get("Content-Type")
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		res.retryAfter, _ = retryAfter(resp.Header.Get("Retry-After"), time.Now())
+	}
+	res.RedirectChain = redirectChain(resp)
+	if resp.StatusCode >= 400 {
+		res.ErrorClass = LinkErrorHTTPStatus
+	}
+	return res
+}
+
+// NormalizeLinkURL returns the form of u used to recognize links to the same page: scheme and
+// host lowercased, default port and fragment removed, and an empty path replaced by "/"
+func NormalizeLinkURL(u *url.URL) string {
+	return normalizeLinkURL(u, nil)
+}
+
+// normalizeLinkURL returns the form of u used to recognize links to the same target: scheme
+// and host lowercased, default port and fragment removed, an empty path replaced by "/", and
+// query parameters matching ignoredParams dropped. A trailing "*" in ignoredParams matches any
+// parameter name with that prefix, e.g. "utm_*".
+func normalizeLinkURL(u *url.URL, ignoredParams []string) string {
+	n := *u
+	n.Scheme = strings.ToLower(n.Scheme)
+	n.Host = strings.ToLower(n.Host)
+	if port := explicitPort(&n); port == "" {
+		n.Host = strings.TrimSuffix(n.Host, ":"+n.Port())
+	}
+	n.Fragment, n.RawFragment = "", ""
+	if n.Path == "" && n.Opaque == "" {
+		n.Path = "/"
+	}
+	if len(ignoredParams) > 0 && n.RawQuery != "" {
+		var kept []string
+		for _, param := range strings.Split(n.RawQuery, "&") {
+			name, _, _ := strings.Cut(param, "=")
+			if unescaped, err := url.QueryUnescape(name); err == nil {
+				name = unescaped
+			}
+			if !queryParamIgnored(name, ignoredParams) {
+				kept = append(kept, param)
+			}
+		}
+		n.RawQuery = strings.Join(kept, "&")
+	}
+	n.ForceQuery = false
+	return n.String()
+}
+
+// queryParamIgnored reports whether name matches one of the ignored parameter patterns
+func queryParamIgnored(name string, ignoredParams []string) bool {
+	for _, pattern := range ignoredParams {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); (ok && strings.HasPrefix(name, prefix)) || name == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// dedupeLinks returns one link per normalized URL, in order of first occurrence, and the
+// occurrences of each normalized URL. The returned links carry the normalized URL and the
+// tag and text of their first occurrence.
+func dedupeLinks(links []PageLink, ignoredParams []string) ([]PageLink, map[string][]LinkSource) {
+	var targets []PageLink
+	sources := make(map[string][]LinkSource)
+	for _, l := range links {
+		key := l.URL
+		if u, err := url.Parse(l.URL); err == nil {
+			key = normalizeLinkURL(u, ignoredParams)
+		}
+		if _, seen := sources[key]; !seen {
+			target := l
+			target.URL = key
+			targets = append(targets, target)
+		}
+		sources[key] = append(sources[key], LinkSource{URL: l.URL, Tag: l.Tag, Text: l.Text, Path: l.Path})
+	}
+	return targets, sources
+}
+
+// redirectChain reconstructs the URLs followed after the original request.
+// Each request created by a redirect keeps a reference to the response that caused it.
+func redirectChain(resp *http.Response) []string {
+	var chain []string
+	for req := resp.Request; req != nil && req.Response != nil; req = req.Response.Request {
+		chain = append(chain, req.URL.String())
+	}
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+	return chain
+}
+
+// classifyLinkError maps a transport error from http.Client.Do to a LinkErrorClass
+func classifyLinkError(err error) LinkErrorClass {
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	var recordErr tls.RecordHeaderError
+	var certErr *tls.CertificateVerificationError
+	var unknownAuthErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidCertErr x509.CertificateInvalidError
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return LinkErrorTimeout
+	case errors.As(err, &dnsErr):
+		if dnsErr.IsTimeout {
+			return LinkErrorTimeout
+		}
+		return LinkErrorDNS
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return LinkErrorConnectionRefused
+	case errors.As(err, &certErr), errors.As(err, &unknownAuthErr), errors.As(err, &hostnameErr),
+		errors.As(err, &invalidCertErr), errors.As(err, &recordErr):
+		return LinkErrorTLS
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return LinkErrorTimeout
+	}
+
+	// The client's default redirect policy returns a plain error with no sentinel to match
+	msg := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(msg, "stopped after") && strings.Contains(msg, "redirects"):
+		return LinkErrorTooManyRedirects
+	case strings.Contains(msg, "timeout"):
+		return LinkErrorTimeout
+	case strings.Contains(msg, "refused"):
+		return LinkErrorConnectionRefused
+	case strings.Contains(msg, "tls:") || strings.Contains(msg, "x509:"):
+		return LinkErrorTLS
+	}
+	return LinkErrorNetwork
+}
+
+// nodeText returns the whitespace-normalized text content of a node and its descendants
+func nodeText(n *html.Node) string {
+	var sb strings.Builder
+	var f func(*html.Node)
+	f = func(c *html.Node) {
+		if c.Type == html.TextNode {
+			sb.WriteString(c.Data)
+			sb.WriteByte(' ')
+		}
+		for child := c.FirstChild; child != nil; child = child.NextSibling {
+			f(child)
+		}
+	}
+	f(n)
+	return strings.Join(strings.Fields(sb.String()), " ")
+}
@@ -0,0 +1,168 @@
+package analyzer
+
+import (
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// PageMetadata holds the <meta> and <link rel> information search engines read from a page.
+// Only the first occurrence of each element counts, as it does for most crawlers.
+type PageMetadata struct {
+	Description string   `json:"description,omitempty"`
+	Keywords    []string `json:"keywords,omitempty"`
+	// Canonical is the <link rel="canonical"> target resolved to an absolute URL
+	Canonical       string           `json:"canonical,omitempty"`
+	CanonicalStatus *LinkCheckResult `json:"canonical_status,omitempty"` // set when links are checked
+	Robots          []string         `json:"robots,omitempty"`           // directives of <meta name="robots">
+	Googlebot       []string         `json:"googlebot,omitempty"`        // directives of <meta name="googlebot">
+	XRobotsTag      []string         `json:"x_robots_tag,omitempty"`     // X-Robots-Tag response header values
+	Viewport        string           `json:"viewport,omitempty"`
+	Charset         string           `json:"charset,omitempty"` // from <meta charset> or <meta http-equiv="Content-Type">
+	BaseHref        string           `json:"base_href,omitempty"`
+}
+
+// attrValue returns the value of the named attribute of n, or "" if it is missing
+func attrValue(n *html.Node, key string) string {
+	v, _ := attrLookup(n, key)
+	return v
+}
+
+// attrLookup returns the value of the named attribute of n and whether it is present
+func attrLookup(n *html.Node, key string) (string, bool) {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return attr.Val, true
+		}
+	}
+	return "", false
+}
+
+// documentBase returns the URL relative references in doc resolve against: the first
+// <base href> in the document, resolved against pageURL, or pageURL itself if there is
+// none or its href is not a usable HTTP/HTTPS URL. As in browsers, the base applies to the
+// whole document, including elements that come before it.
+func documentBase(doc *html.Node, pageURL *url.URL) *url.URL {
+	var href string
+	var found bool
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.DataAtom == atom.Base {
+			for _, attr := range n.Attr {
+				if attr.Key == "href" {
+					href, found = strings.TrimSpace(attr.Val), true
+					return
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil && !found; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(doc)
+	if !found {
+		return pageURL
+	}
+
+	base, err := pageURL.Parse(href)
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") {
+		slog.Warn("Ignoring unusable <base href>", "href", href, "page_url", pageURL.String(), "error", err)
+		return pageURL
+	}
+	return base
+}
+
+// collectMetadata records what a <meta>, <link> or <base> element contributes to m.
+// base is used to resolve the canonical URL.
+func collectMetadata(m *PageMetadata, n *html.Node, base *url.URL) {
+	switch n.DataAtom {
+	case atom.Meta:
+		content := strings.TrimSpace(attrValue(n, "content"))
+		if charset := strings.TrimSpace(attrValue(n, "charset")); charset != "" && m.Charset == "" {
+			m.Charset = charset
+		}
+		if strings.EqualFold(attrValue(n, "http-equiv"), "content-type") && m.Charset == "" {
+			if _, params, err := mime.ParseMediaType(content); err == nil {
+				m.Charset = params["charset"]
+			}
+		}
+		switch strings.ToLower(strings.TrimSpace(attrValue(n, "name"))) {
+		case "description":
+			if m.Description == "" {
+				m.Description = content
+			}
+		case "keywords":
+			if m.Keywords == nil {
+				m.Keywords = splitList(content)
+			}
+		case "robots":
+			if m.Robots == nil {
+				m.Robots = splitList(strings.ToLower(content))
+			}
+		case "googlebot":
+			if m.Googlebot == nil {
+				m.Googlebot = splitList(strings.ToLower(content))
+			}
+		case "viewport":
+			if m.Viewport == "" {
+				m.Viewport = content
+			}
+		}
+	case atom.Link:
+		if m.Canonical != "" || !hasRelToken(attrValue(n, "rel"), "canonical") {
+			return
+		}
+		href := strings.TrimSpace(attrValue(n, "href"))
+		if href == "" {
+			return
+		}
+		canonical, err := base.Parse(href)
+		if err != nil {
+			slog.Warn("Could not parse canonical URL", "href", href, "base_url", base.String(), "error", err)
+			return
+		}
+		m.Canonical = canonical.String()
+	case atom.Base:
+		if m.BaseHref == "" {
+			m.BaseHref = strings.TrimSpace(attrValue(n, "href"))
+		}
+	}
+}
+
+// hasRelToken reports whether the space-separated rel attribute value contains token
+func hasRelToken(rel, token string) bool {
+	for _, t := range strings.Fields(rel) {
+		if strings.EqualFold(t, token) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitList splits a comma-separated attribute value, dropping empty entries
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// xRobotsTag returns the non-empty X-Robots-Tag header values. They are kept whole because
+// a value may be scoped to one crawler ("googlebot: noindex, nofollow").
+func xRobotsTag(h http.Header) []string {
+	var values []string
+	for _, v := range h.Values("X-Robots-Tag") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
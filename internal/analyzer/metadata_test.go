@@ -0,0 +1,69 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestAnalyze_Metadata(t *testing.T) {
+	server := newMockServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			w.Header().Add("X-Robots-Tag", "noarchive")
+			w.Header().Add("X-Robots-Tag", "googlebot: noindex, nofollow")
+			fmt.Fprint(w, `<!DOCTYPE html><html><head>
+				<meta http-equiv="Content-Type" content="text/html; charset=ISO-8859-1">
+				<meta name="Description" content=" A test page ">
+				<meta name="description" content="ignored, only the first one counts">
+				<meta name="keywords" content="go, analyzer,, seo">
+				<meta name="robots" content="NOINDEX, follow">
+				<meta name="googlebot" content="nosnippet">
+				<meta name="viewport" content="width=device-width, initial-scale=1">
+				<link rel="alternate canonical" href="/canonical">
+				<base href="/">
+				<title>Metadata</title>
+			</head><body></body></html>`)
+		case "/canonical":
+			http.NotFound(w, r)
+		}
+	})
+	defer server.Close()
+
+	result, err := New().Analyze(context.Background(), server.URL+"/")
+	if err != nil {
+		t.Fatalf("Analyze failed unexpectedly: %v", err)
+	}
+
+	m := result.Metadata
+	expected := PageMetadata{
+		Description: "A test page",
+		Keywords:    []string{"go", "analyzer", "seo"},
+		Canonical:   server.URL + "/canonical",
+		Robots:      []string{"noindex", "follow"},
+		Googlebot:   []string{"nosnippet"},
+		XRobotsTag:  []string{"noarchive", "googlebot: noindex, nofollow"},
+		Viewport:    "width=device-width, initial-scale=1",
+		Charset:     "ISO-8859-1",
+		BaseHref:    "/",
+	}
+	status := m.CanonicalStatus
+	m.CanonicalStatus = nil
+	if !reflect.DeepEqual(m, expected) {
+		t.Errorf("Expected metadata %+v, got %+v", expected, m)
+	}
+	if status == nil || status.StatusCode != http.StatusNotFound || status.Accessible() {
+		t.Errorf("Expected the canonical URL to be reported as 404, got %+v", status)
+	}
+
+	result, err = New(WithLinkCheck(false)).Analyze(context.Background(), server.URL+"/")
+	if err != nil {
+		t.Fatalf("Analyze failed unexpectedly: %v", err)
+	}
+	if result.Metadata.CanonicalStatus != nil {
+		t.Errorf("Expected no canonical check with link checks disabled, got %+v", result.Metadata.CanonicalStatus)
+	}
+}
@@ -0,0 +1,41 @@
+package analyzer
+
+import (
+	"net/url"
+)
+
+// Kinds of mixed content
+const (
+	MixedContentActive  = "active"  // scripts, styles, frames and form targets; blocked by browsers
+	MixedContentPassive = "passive" // images and media; upgraded to HTTPS or blocked, and the page loses its padlock
+)
+
+// MixedContent is a resource of an HTTPS page that is referenced over plain HTTP
+type MixedContent struct {
+	URL       string `json:"url"`
+	Tag       string `json:"tag"`       // element the reference came from
+	Attribute string `json:"attribute"` // e.g. "src", "srcset", or "style" for CSS url() and @import
+	Path      string `json:"path"`      // CSS-like selector of the element
+	Kind      string `json:"kind"`      // MixedContentActive or MixedContentPassive
+	Severity  string `json:"severity"`  // SeverityError for active content, SeverityWarning for passive
+}
+
+// detectMixedContent returns the references of an HTTPS page at pageURL that use plain HTTP.
+// Pages fetched over HTTP have no mixed content.
+func detectMixedContent(refs []resourceRef, pageURL *url.URL) []MixedContent {
+	mixed := []MixedContent{}
+	if pageURL.Scheme != "https" {
+		return mixed
+	}
+	for _, ref := range refs {
+		if ref.URL.Scheme != "http" {
+			continue
+		}
+		m := MixedContent{URL: ref.URL.String(), Tag: ref.Tag, Attribute: ref.Attribute, Path: ref.Path, Kind: MixedContentPassive, Severity: SeverityWarning}
+		if ref.Active {
+			m.Kind, m.Severity = MixedContentActive, SeverityError
+		}
+		mixed = append(mixed, m)
+	}
+	return mixed
+}
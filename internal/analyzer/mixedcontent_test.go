@@ -0,0 +1,77 @@
+package analyzer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestDetectMixedContent(t *testing.T) {
+	page := `<html><head>
+		<script src="http://cdn.example/app.js"></script>
+		<script src="https://cdn.example/safe.js"></script>
+		<link rel="stylesheet" href="http://cdn.example/site.css">
+		<link rel="canonical" href="http://example.com/">
+		<style>@import "http://cdn.example/more.css"; body { background: url('http://cdn.example/bg.png') }</style>
+	</head><body>
+		<a href="http://example.org/">navigation is not mixed content</a>
+		<img src="//cdn.example/a.png" srcset="http://cdn.example/b.png 2x, https://cdn.example/c.png 3x">
+		<picture><source srcset="http://cdn.example/d.webp"></picture>
+		<video src="http://cdn.example/v.mp4" poster="data:image/png;base64,AAAA"></video>
+		<iframe src="http://widgets.example/"></iframe>
+		<form action="http://example.com/subscribe"><button formaction="/ok">Go</button></form>
+		<div style="background-image: url(http://cdn.example/e.png)"></div>
+	</body></html>`
+	doc, err := html.Parse(strings.NewReader(page))
+	if err != nil {
+		t.Fatalf("Failed to parse HTML: %v", err)
+	}
+	pageURL, _ := url.Parse("https://example.com/")
+	refs := collectResourceRefs(doc, pageURL)
+
+	var got []string
+	for _, m := range detectMixedContent(refs, pageURL) {
+		got = append(got, m.Kind+" "+m.Severity+" "+m.Tag+"["+m.Attribute+"] "+m.URL)
+	}
+	want := []string{
+		"active error script[src] http://cdn.example/app.js",
+		"active error link[href] http://cdn.example/site.css",
+		"active error style[style] http://cdn.example/more.css",
+		"passive warning style[style] http://cdn.example/bg.png",
+		"passive warning img[srcset] http://cdn.example/b.png",
+		"passive warning source[srcset] http://cdn.example/d.webp",
+		"passive warning video[src] http://cdn.example/v.mp4",
+		"active error iframe[src] http://widgets.example/",
+		"active error form[action] http://example.com/subscribe",
+		"passive warning div[style] http://cdn.example/e.png",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected mixed content:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+
+	httpPage, _ := url.Parse("http://example.com/")
+	if mixed := detectMixedContent(collectResourceRefs(doc, httpPage), httpPage); len(mixed) != 0 {
+		t.Errorf("Expected no mixed content on an HTTP page, got %+v", mixed)
+	}
+}
+
+func TestAnalyze_MixedContent(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><img src="http://insecure.example/a.png"><script src="/app.js"></script></body></html>`))
+	}))
+	defer server.Close()
+
+	result, err := New(WithHTTPClient(server.Client()), WithLinkCheck(false)).Analyze(t.Context(), server.URL)
+	if err != nil {
+		t.Fatalf("Analyze failed unexpectedly: %v", err)
+	}
+	if len(result.MixedContent) != 1 || result.MixedContent[0].URL != "http://insecure.example/a.png" || result.MixedContent[0].Kind != MixedContentPassive {
+		t.Errorf("Expected the image as passive mixed content, got %+v", result.MixedContent)
+	}
+}
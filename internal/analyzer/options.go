@@ -0,0 +1,197 @@
+package analyzer
+
+import (
+	"net/http"
+	"slices"
+	"time"
+)
+
+// Defaults used by New when no option overrides them
+const (
+	DefaultFetchTimeout      = 30 * time.Second
+	DefaultLinkTimeout       = 10 * time.Second
+	DefaultLinkConcurrency   = 10
+	DefaultUserAgent         = "WebAnalyzerBot/1.0 (+http://example.com/bot)"
+	DefaultCertExpiryWarning = 30 * 24 * time.Hour
+	DefaultMaxRedirects      = 10 // the limit of http.Client's default redirect policy
+	DefaultHostConcurrency   = 2
+	DefaultHostRate          = 5.0 // requests per second
+	DefaultHostBurst         = 5
+	DefaultMaxRetries        = 2
+	DefaultRetryBackoff      = 500 * time.Millisecond
+)
+
+// Option configures an Analyzer
+type Option func(*Analyzer)
+
+// WithHTTPClient sets the client used for the page fetch and all link checks.
+// Timeouts are applied per request via context, so the client's own Timeout may be left unset.
+func WithHTTPClient(c *http.Client) Option {
+	return func(a *Analyzer) {
+		if c != nil {
+			a.client = c
+		}
+	}
+}
+
+// WithFetchTimeout bounds fetching and parsing the analyzed page. Zero disables the timeout.
+func WithFetchTimeout(d time.Duration) Option {
+	return func(a *Analyzer) { a.fetchTimeout = d }
+}
+
+// WithLinkTimeout bounds each request of a link check: the HEAD, any GET fallback and every
+// retry get it afresh. Waits for Crawl-delay, the host's rate limit and retries are not
+// counted. Zero disables the timeout.
+func WithLinkTimeout(d time.Duration) Option {
+	return func(a *Analyzer) { a.linkTimeout = d }
+}
+
+// WithUserAgent sets the User-Agent header sent with every request
+func WithUserAgent(ua string) Option {
+	return func(a *Analyzer) {
+		if ua != "" {
+			a.userAgent = ua
+		}
+	}
+}
+
+// WithLinkConcurrency sets how many links are checked in parallel
+func WithLinkConcurrency(n int) Option {
+	return func(a *Analyzer) {
+		if n > 0 {
+			a.linkConcurrency = n
+		}
+	}
+}
+
+// WithHostConcurrency sets how many links on the same host are checked in parallel, within the
+// overall limit set by WithLinkConcurrency
+func WithHostConcurrency(n int) Option {
+	return func(a *Analyzer) {
+		if n > 0 {
+			a.hostConcurrency = n
+		}
+	}
+}
+
+// WithHostRateLimit sets how many link check requests per second each host receives, allowing
+// bursts of up to burst requests after a quiet period. A rate of 0 disables the limit; a host's
+// Retry-After is honoured either way.
+func WithHostRateLimit(rate float64, burst int) Option {
+	return func(a *Analyzer) {
+		if rate >= 0 {
+			a.hostRate = rate
+		}
+		if burst > 0 {
+			a.hostBurst = burst
+		}
+	}
+}
+
+// WithHostThrottle limits link checks by t as well as by the analyzer's own per-host limits.
+// Share one HostThrottle between the Analyzers of a server so that concurrent analyses of the
+// same host, and a host's Retry-After, are limited together.
+func WithHostThrottle(t *HostThrottle) Option {
+	return func(a *Analyzer) { a.sharedThrottle = t }
+}
+
+// WithRetries sets how often a link check failing with 429, 502, 503, 504 or a connection error
+// is retried, and the backoff before the first retry. The backoff doubles for each further
+// retry and is jittered; a Retry-After header replaces it and pauses the whole host. A retry is
+// skipped if its wait is longer than 30s or than the analysis has left.
+func WithRetries(n int, backoff time.Duration) Option {
+	return func(a *Analyzer) {
+		if n >= 0 {
+			a.maxRetries = n
+		}
+		if backoff > 0 {
+			a.retryBackoff = backoff
+		}
+	}
+}
+
+// WithLinkCheck enables or disables the (slow) link accessibility check
+func WithLinkCheck(enabled bool) Option {
+	return func(a *Analyzer) { a.checkLinks = enabled }
+}
+
+// WithSitemapCheck enables or disables sitemap discovery and validation for the page's site.
+// It is off by default because it fetches the site's sitemaps and checks the URLs they list.
+func WithSitemapCheck(enabled bool) Option {
+	return func(a *Analyzer) { a.checkSitemaps = enabled }
+}
+
+// WithPerformanceCheck enables or disables probing every resource of the page for its transfer
+// size and cache headers. The page itself is always measured.
+func WithPerformanceCheck(enabled bool) Option {
+	return func(a *Analyzer) { a.checkPerformance = enabled }
+}
+
+// WithPerformanceBudget sets the transfer size limits the page is compared against
+func WithPerformanceBudget(b PerformanceBudget) Option {
+	return func(a *Analyzer) {
+		if b != nil {
+			a.performanceBudget = b
+		}
+	}
+}
+
+// WithCertExpiryWarning sets how long before expiry a TLS certificate of the page gets a warning
+func WithCertExpiryWarning(d time.Duration) Option {
+	return func(a *Analyzer) { a.certExpiryWarning = d }
+}
+
+// WithMaxRedirects sets how many redirects are followed for the analyzed page before giving
+// up. Link checks use the redirect policy of the HTTP client.
+func WithMaxRedirects(n int) Option {
+	return func(a *Analyzer) {
+		if n >= 0 {
+			a.maxRedirects = n
+		}
+	}
+}
+
+// WithLinkPolicy sets how links and resources are classified as internal or external.
+// The default is DefaultLinkPolicy; an unknown scope falls back to LinkScopeHost.
+func WithLinkPolicy(p LinkPolicy) Option {
+	return func(a *Analyzer) { a.linkPolicy = p.withDefaults() }
+}
+
+// WithIgnoredQueryParams sets query parameters that don't make links different, so links that
+// differ only in them are checked once. A trailing "*" matches a prefix, e.g. "utm_*".
+func WithIgnoredQueryParams(params ...string) Option {
+	return func(a *Analyzer) { a.ignoredQueryParams = slices.Clone(params) }
+}
+
+// WithLoginFormDetection enables or disables setting ContainsLoginForm. Forms are inventoried either way.
+func WithLoginFormDetection(enabled bool) Option {
+	return func(a *Analyzer) { a.detectLoginForms = enabled }
+}
+
+// WithRobotsPolicy sets whether robots.txt is honoured for the page fetch and link checks.
+// The default is RobotsObey; use RobotsIgnore for audits of sites you control.
+func WithRobotsPolicy(p RobotsPolicy) Option {
+	return func(a *Analyzer) {
+		if _, ok := ParseRobotsPolicy(string(p)); ok {
+			a.robotsPolicy = p
+		}
+	}
+}
+
+// WithRobotsCache uses c for robots.txt rules and Crawl-delay spacing instead of a cache of
+// the Analyzer's own. Share one RobotsCache between the Analyzers of a server so that each
+// host's robots.txt is fetched once and its Crawl-delay holds across concurrent analyses.
+func WithRobotsCache(c *RobotsCache) Option {
+	return func(a *Analyzer) {
+		if c != nil {
+			a.robots = c
+		}
+	}
+}
+
+// WithProgress registers a function that is told about each phase of Analyze and
+// about every checked link. Since the function is fixed per Analyzer, create a
+// dedicated Analyzer for each analysis whose progress should be tracked.
+func WithProgress(fn ProgressFunc) Option {
+	return func(a *Analyzer) { a.progress = fn }
+}
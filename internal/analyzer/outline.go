@@ -0,0 +1,79 @@
+package analyzer
+
+import (
+	"golang.org/x/net/html"
+)
+
+// Heading is one <h1>-<h6> element of the document outline
+type Heading struct {
+	Level  int    `json:"level"` // 1 for <h1> through 6 for <h6>
+	Text   string `json:"text"`  // whitespace-normalized text content, including image alt text
+	Path   string `json:"path"`  // CSS-like selector of the element
+	Depth  int    `json:"depth"` // nesting depth in the outline, 0 for top-level headings
+	Parent int    `json:"parent"`
+	// SkippedLevel is set when the heading is more than one level deeper than the one before it
+	SkippedLevel bool `json:"skipped_level"`
+	Empty        bool `json:"empty"`
+}
+
+// DocumentOutline is the heading structure of a document
+type DocumentOutline struct {
+	Headings      []Heading `json:"headings"` // in document order; Parent is the index of the enclosing heading, or -1
+	H1Count       int       `json:"h1_count"`
+	MultipleH1    bool      `json:"multiple_h1"`
+	SkippedLevels int       `json:"skipped_levels"` // headings with SkippedLevel set
+	EmptyHeadings int       `json:"empty_headings"`
+}
+
+// outlineBuilder assembles a DocumentOutline from headings fed in document order
+type outlineBuilder struct {
+	outline DocumentOutline
+	open    []int // indexes of the headings enclosing the next one, outermost first
+}
+
+func newOutlineBuilder() *outlineBuilder {
+	return &outlineBuilder{outline: DocumentOutline{Headings: []Heading{}}}
+}
+
+// add appends the heading element n to the outline
+func (b *outlineBuilder) add(n *html.Node) {
+	h := Heading{Level: int(n.Data[1] - '0'), Text: textWithAlt(n), Path: cssPath(n), Parent: -1}
+	h.Empty = h.Text == ""
+
+	headings := b.outline.Headings
+	if len(headings) > 0 && h.Level > headings[len(headings)-1].Level+1 {
+		h.SkippedLevel = true
+		b.outline.SkippedLevels++
+	}
+	for len(b.open) > 0 && headings[b.open[len(b.open)-1]].Level >= h.Level {
+		b.open = b.open[:len(b.open)-1]
+	}
+	if len(b.open) > 0 {
+		h.Parent = b.open[len(b.open)-1]
+	}
+	h.Depth = len(b.open)
+
+	if h.Level == 1 {
+		b.outline.H1Count++
+	}
+	if h.Empty {
+		b.outline.EmptyHeadings++
+	}
+	b.open = append(b.open, len(headings))
+	b.outline.Headings = append(headings, h)
+}
+
+// finish returns the completed outline
+func (b *outlineBuilder) finish() DocumentOutline {
+	b.outline.MultipleH1 = b.outline.H1Count > 1
+	return b.outline
+}
+
+// Counts returns the number of headings per tag, e.g. {"h1": 1, "h2": 3}
+func (o DocumentOutline) Counts() map[string]int {
+	counts := make(map[string]int)
+	for _, h := range o.Headings {
+		counts["h"+string(rune('0'+h.Level))]++
+	}
+	return counts
+}
@@ -0,0 +1,66 @@
+package analyzer
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestOutline(t *testing.T) {
+	page := `<html><body>
+		<h1>Site</h1>
+		<h2>  Intro
+			text </h2>
+		<h4>Deep</h4>
+		<h3><img src="x.png" alt="Pictured"></h3>
+		<h2></h2>
+		<h1>Second title</h1>
+		<h3>After</h3>
+	</body></html>`
+	doc, err := html.Parse(strings.NewReader(page))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	b := newOutlineBuilder()
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode && len(n.Data) == 2 && n.Data[0] == 'h' && n.Data[1] >= '1' && n.Data[1] <= '6' {
+			b.add(n)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(doc)
+	outline := b.finish()
+
+	// level text depth parent skipped empty
+	want := []string{
+		"1 Site 0 -1 false false",
+		"2 Intro text 1 0 false false",
+		"4 Deep 2 1 true false",
+		"3 Pictured 2 1 false false",
+		"2  1 0 false true",
+		"1 Second title 0 -1 false false",
+		"3 After 1 5 true false",
+	}
+	var got []string
+	for _, h := range outline.Headings {
+		got = append(got, fmt.Sprintf("%d %s %d %d %t %t", h.Level, h.Text, h.Depth, h.Parent, h.SkippedLevel, h.Empty))
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected headings:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+	if outline.H1Count != 2 || !outline.MultipleH1 || outline.SkippedLevels != 2 || outline.EmptyHeadings != 1 {
+		t.Errorf("Unexpected outline summary: %+v", outline)
+	}
+	counts := outline.Counts()
+	if fmt.Sprint(counts) != "map[h1:2 h2:2 h3:2 h4:1]" {
+		t.Errorf("Unexpected counts %v", counts)
+	}
+	if outline.Headings[2].Path != "html > body > h4" {
+		t.Errorf("Unexpected path %q", outline.Headings[2].Path)
+	}
+}
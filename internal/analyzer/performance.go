@@ -0,0 +1,311 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// ResourceDocument is the kind of the analyzed page itself in a PerformanceReport
+	ResourceDocument ResourceKind = "document"
+	// ResourceTotal is the PerformanceBudget key limiting the weight of the whole page
+	ResourceTotal ResourceKind = "total"
+
+	// maxProbeBytes caps how much of a resource body is read to measure its size
+	maxProbeBytes = 50 << 20
+	// probeAcceptEncoding is sent with resource probes so sizes reflect compressed transfers
+	probeAcceptEncoding = "gzip, deflate, br"
+)
+
+// DefaultPerformanceBudget is the budget used by New, in bytes
+var DefaultPerformanceBudget = PerformanceBudget{
+	ResourceTotal:      3 << 20,
+	ResourceScript:     1 << 20,
+	ResourceStylesheet: 256 << 10,
+	ResourceImage:      2 << 20,
+	ResourceFont:       512 << 10,
+}
+
+// PerformanceBudget limits the transfer size in bytes per resource kind. The ResourceTotal
+// key limits the sum over all kinds; kinds without a key are unlimited.
+type PerformanceBudget map[ResourceKind]int64
+
+// PerformanceReport describes how heavy the page is
+type PerformanceReport struct {
+	Document     TransferStats   `json:"document"`
+	DownloadTime time.Duration   `json:"download_time_ns"` // from sending the page request to reading the last byte
+	Resources    []TransferStats `json:"resources"`        // one per unique resource URL; empty unless the performance check is enabled
+	// ResourcesProbed is set when the resources were probed; otherwise Totals only cover the document
+	ResourcesProbed bool                   `json:"resources_probed"`
+	Totals          map[ResourceKind]int64 `json:"totals"` // bytes per kind, with the sum under ResourceTotal
+	Budget          PerformanceBudget      `json:"budget"`
+	Violations      []BudgetViolation      `json:"violations"`
+}
+
+// TransferStats describes the transfer of the document or one of its resources
+type TransferStats struct {
+	URL        string       `json:"url"`
+	Kind       ResourceKind `json:"kind"`
+	StatusCode int          `json:"status_code,omitempty"`
+	// TransferSize is the number of bytes on the wire, before decompression, or -1 if unknown
+	TransferSize    int64         `json:"transfer_size"`
+	SizeMeasured    bool          `json:"size_measured"` // TransferSize was counted from the body rather than taken from Content-Length
+	ContentEncoding string        `json:"content_encoding,omitempty"`
+	CacheControl    string        `json:"cache_control,omitempty"`
+	Expires         string        `json:"expires,omitempty"`
+	ETag            string        `json:"etag,omitempty"`
+	LastModified    string        `json:"last_modified,omitempty"`
+	TTFB            time.Duration `json:"ttfb_ns"` // from sending the request to the first response byte
+	Error           string        `json:"error,omitempty"`
+	SkippedByRobots bool          `json:"skipped_by_robots,omitempty"`
+}
+
+// BudgetViolation is a resource kind, or ResourceTotal, whose transfer size exceeds the budget
+type BudgetViolation struct {
+	Kind   ResourceKind `json:"kind"`
+	Limit  int64        `json:"limit"`
+	Actual int64        `json:"actual"`
+}
+
+// Cacheable reports whether the response allows caching, judging by Cache-Control and Expires
+func (s TransferStats) Cacheable() bool {
+	cc := strings.ToLower(s.CacheControl)
+	if strings.Contains(cc, "no-store") || strings.Contains(cc, "no-cache") || strings.Contains(cc, "max-age=0") {
+		return false
+	}
+	return strings.Contains(cc, "max-age") || strings.Contains(cc, "immutable") || s.Expires != ""
+}
+
+// TTFBMillis returns the time to first byte in whole milliseconds, for display
+func (s TransferStats) TTFBMillis() int64 {
+	return s.TTFB.Milliseconds()
+}
+
+// DownloadMillis returns the document download time in whole milliseconds, for display
+func (r PerformanceReport) DownloadMillis() int64 {
+	return r.DownloadTime.Milliseconds()
+}
+
+// Over reports whether kind exceeds the budget
+func (r PerformanceReport) Over(kind ResourceKind) bool {
+	for _, v := range r.Violations {
+		if v.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// ParsePerformanceBudget parses a budget like "script=1MB,image=500KB,total=3MB". Sizes are
+// bytes unless suffixed with KB or MB (multiples of 1024).
+func ParsePerformanceBudget(s string) (PerformanceBudget, error) {
+	budget := PerformanceBudget{}
+	for _, item := range splitList(s) {
+		kind, size, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("budget entry %q is not kind=size", item)
+		}
+		n, err := parseByteSize(size)
+		if err != nil {
+			return nil, fmt.Errorf("budget entry %q: %w", item, err)
+		}
+		budget[ResourceKind(strings.ToLower(strings.TrimSpace(kind)))] = n
+	}
+	return budget, nil
+}
+
+// parseByteSize parses a size such as "512", "200KB" or "1.5MB"
+func parseByteSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	multiplier := 1.0
+	switch {
+	case strings.HasSuffix(s, "MB"):
+		multiplier, s = 1<<20, strings.TrimSuffix(s, "MB")
+	case strings.HasSuffix(s, "KB"):
+		multiplier, s = 1<<10, strings.TrimSuffix(s, "KB")
+	case strings.HasSuffix(s, "B"):
+		s = strings.TrimSuffix(s, "B")
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(n * multiplier), nil
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// transferTrace records when the first response byte arrived. With redirects, the last hop wins.
+type transferTrace struct {
+	mu        sync.Mutex
+	firstByte time.Time
+}
+
+// withTrace returns ctx instrumented to record the time of the first response byte
+func (t *transferTrace) withTrace(ctx context.Context) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotFirstResponseByte: func() {
+			t.mu.Lock()
+			t.firstByte = time.Now()
+			t.mu.Unlock()
+		},
+	})
+}
+
+// since returns the time from start to the first response byte, or 0 if none arrived
+func (t *transferTrace) since(start time.Time) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.firstByte.IsZero() {
+		return 0
+	}
+	return t.firstByte.Sub(start)
+}
+
+// fromResponse fills the status and header-derived fields of s from resp
+func (s *TransferStats) fromResponse(resp *http.Response) {
+	s.StatusCode = resp.StatusCode
+	s.ContentEncoding = resp.Header.Get("Content-Encoding")
+	s.CacheControl = resp.Header.Get("Cache-Control")
+	s.Expires = resp.Header.Get("Expires")
+	s.ETag = resp.Header.Get("ETag")
+	s.LastModified = resp.Header.Get("Last-Modified")
+	s.TransferSize = resp.ContentLength
+}
+
+// probeResources measures the transfer of every unique HTTP(S) resource URL, concurrently and
+// honouring robots.txt, and returns the stats in inventory order
+func (a *Analyzer) probeResources(ctx context.Context, resources []Resource) []TransferStats {
+	var targets []Resource
+	seen := map[string]bool{}
+	for _, r := range resources {
+		if !seen[r.URL] && absoluteHTTPURL(r.URL) {
+			seen[r.URL] = true
+			targets = append(targets, r)
+		}
+	}
+
+	stats := make([]TransferStats, len(targets))
+	semaphore := make(chan struct{}, max(a.linkConcurrency, 1))
+	var wg sync.WaitGroup
+	for i, r := range targets {
+		select {
+		case semaphore <- struct{}{}:
+		case <-ctx.Done():
+			stats[i] = TransferStats{URL: r.URL, Kind: r.Kind, TransferSize: -1, Error: ctx.Err().Error()}
+			continue
+		}
+		wg.Add(1)
+		go func(i int, r Resource) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			// Crawl-delay is waited for before the probe's own timeout starts
+			if allowed, err := a.robotsAllowed(ctx, r.URL); err != nil {
+				stats[i] = TransferStats{URL: r.URL, Kind: r.Kind, TransferSize: -1, Error: err.Error()}
+				return
+			} else if !allowed {
+				stats[i] = TransferStats{URL: r.URL, Kind: r.Kind, TransferSize: -1, SkippedByRobots: true}
+				return
+			}
+			probeCtx, cancel := withTimeout(ctx, a.linkTimeout)
+			defer cancel()
+			stats[i] = a.probeTransfer(probeCtx, r)
+		}(i, r)
+	}
+	wg.Wait()
+	return stats
+}
+
+// probeTransfer measures one resource: HEAD when the server states the size, otherwise GET
+// with the body counted
+func (a *Analyzer) probeTransfer(ctx context.Context, r Resource) TransferStats {
+	s := TransferStats{URL: r.URL, Kind: r.Kind, TransferSize: -1}
+	for _, method := range []string{http.MethodHead, http.MethodGet} {
+		trace := &transferTrace{}
+		req, err := http.NewRequestWithContext(trace.withTrace(ctx), method, r.URL, nil)
+		if err != nil {
+			s.Error = err.Error()
+			return s
+		}
+		req.Header.Set("User-Agent", a.userAgent)
+		// Setting Accept-Encoding ourselves stops the transport from decompressing, so the
+		// body is counted as transferred
+		req.Header.Set("Accept-Encoding", probeAcceptEncoding)
+
+		start := time.Now()
+		resp, err := a.client.Do(req)
+		if err != nil {
+			s.Error = err.Error()
+			if method == http.MethodHead {
+				continue // some servers mishandle HEAD
+			}
+			return s
+		}
+		s.Error = ""
+		s.fromResponse(resp)
+		s.TTFB = trace.since(start)
+		if method == http.MethodHead {
+			resp.Body.Close()
+			if resp.StatusCode < 400 && resp.ContentLength >= 0 {
+				return s
+			}
+			continue
+		}
+		counter := &countingReader{r: io.LimitReader(resp.Body, maxProbeBytes)}
+		_, err = io.Copy(io.Discard, counter)
+		resp.Body.Close()
+		if err != nil {
+			s.Error = err.Error()
+		}
+		if s.TransferSize < 0 {
+			s.TransferSize, s.SizeMeasured = counter.n, true
+		}
+	}
+	return s
+}
+
+// finishPerformance totals the transfer sizes by kind and compares them with the budget
+func finishPerformance(p *PerformanceReport, budget PerformanceBudget) {
+	p.Totals = map[ResourceKind]int64{}
+	p.Budget = budget
+	p.Violations = []BudgetViolation{}
+	for _, s := range append([]TransferStats{p.Document}, p.Resources...) {
+		if s.TransferSize > 0 {
+			p.Totals[s.Kind] += s.TransferSize
+			p.Totals[ResourceTotal] += s.TransferSize
+		}
+	}
+
+	kinds := make([]string, 0, len(budget))
+	for kind := range budget {
+		kinds = append(kinds, string(kind))
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		limit := budget[ResourceKind(kind)]
+		if actual := p.Totals[ResourceKind(kind)]; actual > limit {
+			p.Violations = append(p.Violations, BudgetViolation{Kind: ResourceKind(kind), Limit: limit, Actual: actual})
+			slog.Info("Performance budget exceeded", "kind", kind, "limit", limit, "actual", actual)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package analyzer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePerformanceBudget(t *testing.T) {
+	testCases := []struct {
+		input   string
+		want    PerformanceBudget
+		wantErr bool
+	}{
+		{input: "", want: PerformanceBudget{}},
+		{input: "script=1MB, image=500kb,Total=3MB", want: PerformanceBudget{ResourceScript: 1 << 20, ResourceImage: 500 << 10, ResourceTotal: 3 << 20}},
+		{input: "font=1.5KB,document=2048B,stylesheet=100", want: PerformanceBudget{ResourceFont: 1536, ResourceDocument: 2048, ResourceStylesheet: 100}},
+		{input: "script", wantErr: true},
+		{input: "script=lots", wantErr: true},
+		{input: "script=-1KB", wantErr: true},
+	}
+	for _, tc := range testCases {
+		got, err := ParsePerformanceBudget(tc.input)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("ParsePerformanceBudget(%q): expected error %v, got %v", tc.input, tc.wantErr, err)
+			continue
+		}
+		if !tc.wantErr && !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("ParsePerformanceBudget(%q): expected %v, got %v", tc.input, tc.want, got)
+		}
+	}
+}
+
+func TestAnalyze_Performance(t *testing.T) {
+	page := `<html><head><title>Weighed</title><script src="/app.js"></script></head>
+		<body><img src="/photo.jpg"><img src="/photo.jpg"></body></html>`
+	var gzipped bytes.Buffer
+	gz := gzip.NewWriter(&gzipped)
+	gz.Write([]byte(page))
+	gz.Close()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write(gzipped.Bytes())
+			return
+		}
+		w.Write([]byte(page))
+	})
+	mux.HandleFunc("/app.js", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		w.Header().Set("Content-Length", "2000")
+		if r.Method == http.MethodGet {
+			w.Write(make([]byte, 2000))
+		}
+	})
+	mux.HandleFunc("/photo.jpg", func(w http.ResponseWriter, r *http.Request) {
+		// Flushing before writing forces a chunked response without Content-Length
+		w.Header().Set("Cache-Control", "no-cache")
+		w.(http.Flusher).Flush()
+		if r.Method == http.MethodGet {
+			w.Write(make([]byte, 700))
+		}
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	t.Run("Document only", func(t *testing.T) {
+		result, err := New(WithLinkCheck(false)).Analyze(t.Context(), server.URL)
+		if err != nil {
+			t.Fatalf("Analyze failed unexpectedly: %v", err)
+		}
+		if result.PageTitle != "Weighed" {
+			t.Errorf("Expected the gzipped page to be decompressed, got title %q", result.PageTitle)
+		}
+		perf := result.Performance
+		doc := perf.Document
+		if doc.Kind != ResourceDocument || doc.TransferSize != int64(gzipped.Len()) || doc.ContentEncoding != "gzip" || !doc.SizeMeasured {
+			t.Errorf("Expected a measured gzip document of %d bytes, got %+v", gzipped.Len(), doc)
+		}
+		if doc.TTFB <= 0 || perf.DownloadTime < doc.TTFB {
+			t.Errorf("Expected a positive TTFB no longer than the download time, got %s and %s", doc.TTFB, perf.DownloadTime)
+		}
+		if perf.ResourcesProbed || len(perf.Resources) != 0 || perf.Totals[ResourceTotal] != int64(gzipped.Len()) {
+			t.Errorf("Expected only the document to be weighed, got %+v", perf)
+		}
+	})
+
+	t.Run("Resources and budget", func(t *testing.T) {
+		budget := PerformanceBudget{ResourceScript: 1000, ResourceImage: 1000, ResourceTotal: 2500}
+		result, err := New(WithLinkCheck(false), WithPerformanceCheck(true), WithPerformanceBudget(budget)).Analyze(t.Context(), server.URL)
+		if err != nil {
+			t.Fatalf("Analyze failed unexpectedly: %v", err)
+		}
+		perf := result.Performance
+		if !perf.ResourcesProbed || len(perf.Resources) != 2 {
+			t.Fatalf("Expected 2 unique resources to be probed, got %+v", perf.Resources)
+		}
+		script, photo := perf.Resources[0], perf.Resources[1]
+		if script.Kind != ResourceScript || script.TransferSize != 2000 || script.SizeMeasured || !script.Cacheable() {
+			t.Errorf("Expected a cacheable 2000 byte script sized from Content-Length, got %+v", script)
+		}
+		if photo.Kind != ResourceImage || photo.TransferSize != 700 || !photo.SizeMeasured || photo.Cacheable() || photo.CacheControl != "no-cache" {
+			t.Errorf("Expected an uncacheable 700 byte image measured with GET, got %+v", photo)
+		}
+		wantTotal := int64(gzipped.Len()) + 2700
+		if perf.Totals[ResourceTotal] != wantTotal || perf.Totals[ResourceScript] != 2000 || perf.Totals[ResourceImage] != 700 {
+			t.Errorf("Expected totals of %d bytes, 2000 script and 700 image, got %v", wantTotal, perf.Totals)
+		}
+		want := []BudgetViolation{{Kind: ResourceScript, Limit: 1000, Actual: 2000}, {Kind: ResourceTotal, Limit: 2500, Actual: wantTotal}}
+		if !reflect.DeepEqual(perf.Violations, want) {
+			t.Errorf("Expected violations %+v, got %+v", want, perf.Violations)
+		}
+		if !perf.Over(ResourceScript) || perf.Over(ResourceImage) {
+			t.Errorf("Expected only scripts and the total over budget, got %+v", perf.Violations)
+		}
+	})
+}
@@ -0,0 +1,31 @@
+package analyzer
+
+// Phase names a stage of an analysis, in the order they happen
+type Phase string
+
+const (
+	PhaseFetch       Phase = "fetch"       // requesting the page
+	PhaseParse       Phase = "parse"       // parsing and walking the HTML
+	PhaseCheckLinks  Phase = "check_links" // checking link accessibility
+	PhaseSitemap     Phase = "sitemap"     // discovering and checking sitemaps
+	PhasePerformance Phase = "performance" // measuring the transfer size of every resource
+	PhaseDone        Phase = "done"        // analysis finished successfully
+)
+
+// Progress describes how far an analysis has got
+type Progress struct {
+	Phase        Phase `json:"phase"`
+	LinksChecked int   `json:"links_checked"`
+	LinksTotal   int   `json:"links_total"`
+}
+
+// ProgressFunc receives progress updates. Calls are serialized, but may come from
+// link check goroutines, so the function should return quickly.
+type ProgressFunc func(Progress)
+
+// reportProgress forwards p to the configured ProgressFunc, if any
+func (a *Analyzer) reportProgress(p Progress) {
+	if a.progress != nil {
+		a.progress(p)
+	}
+}
@@ -0,0 +1,142 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// longRedirectChain is the number of redirects from which a chain gets a warning
+	longRedirectChain = 3
+	// maxRedirectBodyBytes caps how much of a redirect response body is drained before closing it
+	maxRedirectBodyBytes = 64 << 10
+)
+
+// RedirectHop is one response on the way to the analyzed page
+type RedirectHop struct {
+	URL        string        `json:"url"`
+	StatusCode int           `json:"status_code"`
+	Location   string        `json:"location,omitempty"` // Location header as sent, possibly relative
+	Latency    time.Duration `json:"latency_ns"`         // from sending the request to receiving the response headers
+}
+
+// RedirectReport describes how the requested URL led to the analyzed page
+type RedirectReport struct {
+	Hops           []RedirectHop `json:"hops"`            // every response in order, ending with the page itself
+	HTTPSUpgrade   bool          `json:"https_upgrade"`   // the chain started on http:// and ended on https://
+	HTTPSDowngrade bool          `json:"https_downgrade"` // some hop redirected from https:// to http://
+	HostChanged    bool          `json:"host_changed"`    // the page is on a different host than the requested URL
+	Warnings       []string      `json:"warnings"`
+}
+
+// Count returns the number of redirects followed
+func (r RedirectReport) Count() int {
+	return max(len(r.Hops)-1, 0)
+}
+
+// LatencyMillis returns the hop latency in whole milliseconds, for display
+func (h RedirectHop) LatencyMillis() int64 {
+	return h.Latency.Milliseconds()
+}
+
+// isRedirectStatus reports whether an HTTP client follows responses with the given status
+func isRedirectStatus(code int) bool {
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// fetchPage requests pageURL, following redirects one hop at a time so that each is recorded,
+// robots.txt is honoured for every URL and loops are caught. Failures to build a request or
+// to follow a redirect are returned as *AnalysisError; transport errors are returned as is.
+func (a *Analyzer) fetchPage(ctx context.Context, pageURL string) (*http.Response, RedirectReport, error) {
+	client := *a.client
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+
+	report := RedirectReport{Hops: []RedirectHop{}, Warnings: []string{}}
+	visited := map[string]bool{}
+	target := pageURL
+	var via *http.Response
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+		if err != nil {
+			return nil, report, &AnalysisError{Message: fmt.Sprintf("Failed to fetch URL: %v", err), StatusCode: 0, Category: ErrorCategoryInvalidURL, Err: err}
+		}
+		req.Header.Set("User-Agent", a.userAgent)
+		// Decompressing the body ourselves lets the transfer size be measured before decompression
+		req.Header.Set("Accept-Encoding", "gzip")
+		req.Response = via // as http.Client does for redirected requests
+		visited[req.URL.String()] = true
+
+		start := time.Now()
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, report, err
+		}
+		report.Hops = append(report.Hops, RedirectHop{URL: req.URL.String(), StatusCode: resp.StatusCode, Location: resp.Header.Get("Location"), Latency: time.Since(start)})
+		if !isRedirectStatus(resp.StatusCode) || resp.Header.Get("Location") == "" {
+			finishRedirects(&report)
+			return resp, report, nil
+		}
+		io.Copy(io.Discard, io.LimitReader(resp.Body, maxRedirectBodyBytes))
+		resp.Body.Close()
+
+		next, err := resp.Location()
+		if err != nil {
+			return nil, report, &AnalysisError{Message: fmt.Sprintf("Failed to follow redirect from %s: %v", req.URL, err), StatusCode: resp.StatusCode, Category: ErrorCategoryRedirect, Err: err}
+		}
+		next.Fragment = "" // fragments are not sent and don't make a different request
+		slog.Debug("Following redirect", "from", req.URL.String(), "to", next.String(), "status", resp.StatusCode)
+		switch {
+		case visited[next.String()]:
+			return nil, report, &AnalysisError{Message: "Redirect loop: " + redirectPath(report.Hops, next.String()), StatusCode: resp.StatusCode, Category: ErrorCategoryRedirect}
+		case report.Count() >= a.maxRedirects:
+			return nil, report, &AnalysisError{Message: fmt.Sprintf("Stopped after %d redirects: %s", report.Count(), redirectPath(report.Hops, next.String())), StatusCode: resp.StatusCode, Category: ErrorCategoryRedirect}
+		case next.Scheme != "http" && next.Scheme != "https":
+			return nil, report, &AnalysisError{Message: fmt.Sprintf("Redirect to unsupported URL %s", next), StatusCode: resp.StatusCode, Category: ErrorCategoryRedirect}
+		}
+		if allowed, err := a.robotsAllowed(ctx, next.String()); err != nil {
+			return nil, report, err
+		} else if !allowed {
+			return nil, report, &AnalysisError{Message: fmt.Sprintf("robots.txt disallows fetching %s, which the page redirects to, for %s", next, userAgentToken(a.userAgent)), StatusCode: 0, Category: ErrorCategoryRobots}
+		}
+		target, via = next.String(), resp
+	}
+}
+
+// redirectPath renders the URLs of hops followed by next, e.g. "a -> b -> a"
+func redirectPath(hops []RedirectHop, next string) string {
+	urls := make([]string, 0, len(hops)+1)
+	for _, h := range hops {
+		urls = append(urls, h.URL)
+	}
+	return strings.Join(append(urls, next), " -> ")
+}
+
+// finishRedirects sets the flags and warnings of a chain that reached a page
+func finishRedirects(r *RedirectReport) {
+	if r.Count() == 0 {
+		return
+	}
+	first, _ := url.Parse(r.Hops[0].URL)
+	final, _ := url.Parse(r.Hops[len(r.Hops)-1].URL)
+	r.HTTPSUpgrade = first.Scheme == "http" && final.Scheme == "https"
+	r.HostChanged = !strings.EqualFold(first.Hostname(), final.Hostname())
+	for i := 1; i < len(r.Hops); i++ {
+		if strings.HasPrefix(r.Hops[i-1].URL, "https:") && strings.HasPrefix(r.Hops[i].URL, "http:") {
+			r.HTTPSDowngrade = true
+			r.Warnings = append(r.Warnings, fmt.Sprintf("%s redirects from HTTPS to plain HTTP", r.Hops[i-1].URL))
+		}
+	}
+	if r.Count() >= longRedirectChain {
+		r.Warnings = append(r.Warnings, fmt.Sprintf("%d redirects before reaching the page; link to %s directly", r.Count(), final))
+	}
+}
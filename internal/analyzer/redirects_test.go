@@ -0,0 +1,105 @@
+package analyzer
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAnalyze_Redirects(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/start", http.RedirectHandler("/middle", http.StatusMovedPermanently))
+	mux.Handle("/middle", http.RedirectHandler("/page#top", http.StatusFound))
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><a href="/other">Other</a></body></html>`))
+	})
+	mux.Handle("/loop-a", http.RedirectHandler("/loop-b", http.StatusFound))
+	mux.Handle("/loop-b", http.RedirectHandler("/loop-a", http.StatusFound))
+	mux.Handle("/bad-scheme", http.RedirectHandler("ftp://example.com/", http.StatusFound))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	result, err := New(WithLinkCheck(false)).Analyze(t.Context(), server.URL+"/start")
+	if err != nil {
+		t.Fatalf("Analyze failed unexpectedly: %v", err)
+	}
+	want := []RedirectHop{
+		{URL: server.URL + "/start", StatusCode: http.StatusMovedPermanently, Location: "/middle"},
+		{URL: server.URL + "/middle", StatusCode: http.StatusFound, Location: "/page#top"},
+		{URL: server.URL + "/page", StatusCode: http.StatusOK},
+	}
+	hops := result.Redirects.Hops
+	if len(hops) != len(want) {
+		t.Fatalf("Expected %d hops, got %+v", len(want), hops)
+	}
+	for i, w := range want {
+		if hops[i].URL != w.URL || hops[i].StatusCode != w.StatusCode || hops[i].Location != w.Location {
+			t.Errorf("Expected hop %d to be %+v, got %+v", i, w, hops[i])
+		}
+		if hops[i].Latency <= 0 {
+			t.Errorf("Expected hop %d to have a latency, got %v", i, hops[i].Latency)
+		}
+	}
+	if result.Redirects.Count() != 2 || result.Redirects.HostChanged || result.Redirects.HTTPSUpgrade {
+		t.Errorf("Expected 2 redirects on the same host, got %+v", result.Redirects)
+	}
+	if result.FinalURL != server.URL+"/page" || result.InternalLinksCount != 1 {
+		t.Errorf("Expected final URL %s/page with 1 internal link, got %s with %d", server.URL, result.FinalURL, result.InternalLinksCount)
+	}
+
+	errorCases := []struct {
+		name     string
+		path     string
+		opts     []Option
+		wantText string
+	}{
+		{"Loop", "/loop-a", nil, "Redirect loop"},
+		{"Too many", "/start", []Option{WithMaxRedirects(1)}, "Stopped after 1 redirects"},
+		{"Unsupported scheme", "/bad-scheme", nil, "unsupported URL"},
+	}
+	for _, tc := range errorCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := New(append(tc.opts, WithLinkCheck(false))...).Analyze(t.Context(), server.URL+tc.path)
+			var analysisErr *AnalysisError
+			if !errors.As(err, &analysisErr) || analysisErr.Category != ErrorCategoryRedirect || !strings.Contains(analysisErr.Message, tc.wantText) {
+				t.Errorf("Expected a %s error containing %q, got %v", ErrorCategoryRedirect, tc.wantText, err)
+			}
+		})
+	}
+}
+
+func TestFinishRedirects(t *testing.T) {
+	testCases := []struct {
+		name          string
+		urls          []string
+		wantUpgrade   bool
+		wantDowngrade bool
+		wantHost      bool
+		wantWarnings  int
+	}{
+		{"No redirect", []string{"http://example.com/"}, false, false, false, 0},
+		{"HTTPS upgrade", []string{"http://example.com/", "https://example.com/"}, true, false, false, 0},
+		{"Host change", []string{"https://example.com/", "https://www.example.com/"}, false, false, true, 0},
+		{"Downgrade", []string{"https://example.com/", "http://example.com/"}, false, true, false, 1},
+		{"Long chain", []string{"http://example.com/", "https://example.com/", "https://www.example.com/", "https://www.example.com/home"}, true, false, true, 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := RedirectReport{Warnings: []string{}}
+			for _, u := range tc.urls {
+				r.Hops = append(r.Hops, RedirectHop{URL: u})
+			}
+			finishRedirects(&r)
+			if r.HTTPSUpgrade != tc.wantUpgrade || r.HTTPSDowngrade != tc.wantDowngrade || r.HostChanged != tc.wantHost {
+				t.Errorf("Expected upgrade=%v downgrade=%v host change=%v, got %+v", tc.wantUpgrade, tc.wantDowngrade, tc.wantHost, r)
+			}
+			if len(r.Warnings) != tc.wantWarnings {
+				t.Errorf("Expected %d warning(s), got %v", tc.wantWarnings, r.Warnings)
+			}
+		})
+	}
+}
@@ -0,0 +1,267 @@
+package analyzer
+
+import (
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// ResourceKind classifies a subresource of a page
+type ResourceKind string
+
+const (
+	ResourceScript     ResourceKind = "script"
+	ResourceStylesheet ResourceKind = "stylesheet"
+	ResourceImage      ResourceKind = "image"
+	ResourceMedia      ResourceKind = "media" // audio, video and text tracks
+	ResourceIframe     ResourceKind = "iframe"
+	ResourceFont       ResourceKind = "font"
+	ResourceObject     ResourceKind = "object" // <object> and <embed> content
+	ResourceOther      ResourceKind = "other"  // e.g. web app manifests and preloads of other types
+
+	// resourceFormTarget marks form actions, which are collected for mixed content but are not subresources
+	resourceFormTarget ResourceKind = "form_target"
+)
+
+// Resource is a subresource the page loads
+type Resource struct {
+	Kind       ResourceKind      `json:"kind"`
+	URL        string            `json:"url"`       // absolute URL
+	Tag        string            `json:"tag"`       // element the reference came from
+	Attribute  string            `json:"attribute"` // e.g. "src", "srcset", or "style" for CSS url() and @import
+	Path       string            `json:"path"`      // CSS-like selector of the element
+	FirstParty bool              `json:"first_party"`
+	Attributes map[string]string `json:"attributes,omitempty"` // loading-related attributes of the element, e.g. async, defer, loading
+}
+
+// resourceAttributes are the element attributes recorded in Resource.Attributes when present
+var resourceAttributes = []string{
+	"async", "defer", "type", "nomodule", "loading", "decoding", "fetchpriority", "crossorigin",
+	"integrity", "referrerpolicy", "as", "media", "sandbox", "allow",
+}
+
+// fontExtensions are the file extensions that make a CSS url() a font rather than an image
+var fontExtensions = setOf(".woff", ".woff2", ".ttf", ".otf", ".eot")
+
+// resourceRef is a URL the page loads or submits to, other than by navigation
+type resourceRef struct {
+	URL       *url.URL
+	Kind      ResourceKind
+	Tag       string // element the reference came from
+	Attribute string // attribute holding the URL, or "style" for CSS in a <style> element
+	Path      string // CSS-like selector of the element
+	Active    bool   // the resource can change the page or read its data (scripts, styles, frames, form targets)
+	Node      *html.Node
+}
+
+var (
+	// cssImport matches an @import rule with its URL, quoted or in url()
+	cssImport = regexp.MustCompile(`(?i)@import\s+(?:url\(\s*)?["']?([^"')\s;]+)`)
+	// cssURL matches a url() reference, quoted or not
+	cssURL = regexp.MustCompile(`(?i)url\(\s*["']?([^"')]+?)["']?\s*\)`)
+)
+
+// collectResourceRefs returns the subresources and form targets referenced by doc, in
+// document order, resolved against base. Unparseable and data: URLs are skipped.
+func collectResourceRefs(doc *html.Node, base *url.URL) []resourceRef {
+	var refs []resourceRef
+	add := func(n *html.Node, attr, raw string, kind ResourceKind, active bool) {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			return
+		}
+		u, err := base.Parse(raw)
+		if err != nil || u.Scheme == "data" {
+			return
+		}
+		refs = append(refs, resourceRef{URL: u, Kind: kind, Tag: n.Data, Attribute: attr, Path: cssPath(n), Active: active, Node: n})
+	}
+	addAttr := func(n *html.Node, attr string, kind ResourceKind, active bool) {
+		if v, ok := attrLookup(n, attr); ok {
+			add(n, attr, v, kind, active)
+		}
+	}
+	addSrcset := func(n *html.Node) {
+		for _, candidate := range parseSrcset(attrValue(n, "srcset")) {
+			add(n, "srcset", candidate, ResourceImage, false)
+		}
+	}
+	addCSS := func(n *html.Node, attr, css string) {
+		for _, m := range cssImport.FindAllStringSubmatch(css, -1) {
+			add(n, attr, m[1], ResourceStylesheet, true)
+		}
+		for _, m := range cssURL.FindAllStringSubmatch(cssImport.ReplaceAllString(css, ""), -1) {
+			kind := ResourceImage
+			if u, err := url.Parse(strings.TrimSpace(m[1])); err == nil && fontExtensions[strings.ToLower(path.Ext(u.Path))] {
+				kind = ResourceFont
+			}
+			add(n, attr, m[1], kind, false)
+		}
+	}
+
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.DataAtom {
+			case atom.Script:
+				addAttr(n, "src", ResourceScript, true)
+			case atom.Link:
+				rel := attrValue(n, "rel")
+				switch {
+				case hasRelToken(rel, "stylesheet"):
+					addAttr(n, "href", ResourceStylesheet, true)
+				case hasRelToken(rel, "modulepreload"):
+					addAttr(n, "href", ResourceScript, true)
+				case hasRelToken(rel, "preload"):
+					addAttr(n, "href", preloadKind(attrValue(n, "as")), true)
+				case hasRelToken(rel, "manifest"):
+					addAttr(n, "href", ResourceOther, true)
+				case hasRelToken(rel, "icon"), hasRelToken(rel, "apple-touch-icon"):
+					addAttr(n, "href", ResourceImage, false)
+				}
+			case atom.Iframe, atom.Frame:
+				addAttr(n, "src", ResourceIframe, true)
+			case atom.Embed:
+				addAttr(n, "src", ResourceObject, true)
+			case atom.Object:
+				addAttr(n, "data", ResourceObject, true)
+			case atom.Form:
+				addAttr(n, "action", resourceFormTarget, true)
+			case atom.Button:
+				addAttr(n, "formaction", resourceFormTarget, true)
+			case atom.Input:
+				addAttr(n, "formaction", resourceFormTarget, true)
+				if strings.EqualFold(attrValue(n, "type"), "image") {
+					addAttr(n, "src", ResourceImage, false)
+				}
+			case atom.Img:
+				addAttr(n, "src", ResourceImage, false)
+				addSrcset(n)
+			case atom.Source:
+				// <source> holds media inside <audio>/<video> and images inside <picture>
+				kind := ResourceImage
+				if p := n.Parent; p != nil && (p.DataAtom == atom.Audio || p.DataAtom == atom.Video) {
+					kind = ResourceMedia
+				}
+				addAttr(n, "src", kind, false)
+				addSrcset(n)
+			case atom.Audio, atom.Track:
+				addAttr(n, "src", ResourceMedia, false)
+			case atom.Video:
+				addAttr(n, "src", ResourceMedia, false)
+				addAttr(n, "poster", ResourceImage, false)
+			case atom.Style:
+				addCSS(n, "style", nodeRawText(n))
+			}
+			if style, ok := attrLookup(n, "style"); ok {
+				addCSS(n, "style", style)
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(doc)
+	return refs
+}
+
+// preloadKind returns the kind of resource a <link rel="preload"> fetches, from its as attribute
+func preloadKind(as string) ResourceKind {
+	switch strings.ToLower(strings.TrimSpace(as)) {
+	case "script":
+		return ResourceScript
+	case "style":
+		return ResourceStylesheet
+	case "font":
+		return ResourceFont
+	case "image":
+		return ResourceImage
+	case "audio", "video", "track":
+		return ResourceMedia
+	default:
+		return ResourceOther
+	}
+}
+
+// inventoryResources returns the subresources among refs, leaving out form targets.
+// First-party resources are those policy considers internal to pageURL.
+func inventoryResources(refs []resourceRef, pageURL *url.URL, policy LinkPolicy) []Resource {
+	resources := []Resource{}
+	for _, ref := range refs {
+		if ref.Kind == resourceFormTarget {
+			continue
+		}
+		r := Resource{Kind: ref.Kind, URL: ref.URL.String(), Tag: ref.Tag, Attribute: ref.Attribute, Path: ref.Path, FirstParty: policy.Internal(pageURL, ref.URL)}
+		for _, key := range resourceAttributes {
+			if v, ok := attrLookup(ref.Node, key); ok {
+				if r.Attributes == nil {
+					r.Attributes = map[string]string{}
+				}
+				r.Attributes[key] = v
+			}
+		}
+		resources = append(resources, r)
+	}
+	return resources
+}
+
+// countResources returns the number of resources per kind, e.g. {"script": 3, "image": 12}
+func countResources(resources []Resource) map[ResourceKind]int {
+	counts := make(map[ResourceKind]int)
+	for _, r := range resources {
+		counts[r.Kind]++
+	}
+	return counts
+}
+
+// resourceLinks returns the HTTP(S) resources to check for accessibility. Repeated URLs are
+// kept, as the link check counts every occurrence.
+func resourceLinks(resources []Resource) []PageLink {
+	var toCheck []PageLink
+	for _, r := range resources {
+		if absoluteHTTPURL(r.URL) {
+			toCheck = append(toCheck, PageLink{URL: r.URL, Tag: r.Tag, Path: r.Path, Internal: r.FirstParty})
+		}
+	}
+	return toCheck
+}
+
+// parseSrcset returns the image URLs of a srcset attribute. A URL runs up to the next
+// whitespace, and a comma separates candidates only after a URL and its descriptors.
+func parseSrcset(srcset string) []string {
+	var urls []string
+	s := srcset
+	for {
+		s = strings.TrimLeft(s, " \t\n\r\f,")
+		if s == "" {
+			return urls
+		}
+		end := strings.IndexAny(s, " \t\n\r\f")
+		if end < 0 {
+			end = len(s)
+		}
+		candidate := s[:end]
+		s = s[end:]
+		if trimmed := strings.TrimRight(candidate, ","); trimmed != candidate {
+			// A URL ending in commas has no descriptors
+			urls = append(urls, trimmed)
+			continue
+		}
+		urls = append(urls, candidate)
+		// Skip the descriptors, which can't contain commas outside parentheses
+		depth, i := 0, 0
+		for ; i < len(s) && (s[i] != ',' || depth > 0); i++ {
+			switch s[i] {
+			case '(':
+				depth++
+			case ')':
+				depth--
+			}
+		}
+		s = s[i:]
+	}
+}
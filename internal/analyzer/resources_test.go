@@ -0,0 +1,141 @@
+package analyzer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestInventoryResources(t *testing.T) {
+	page := `<html><head>
+		<script src="/app.js" async></script>
+		<script src="https://cdn.example/lib.js" defer integrity="sha384-x" crossorigin="anonymous"></script>
+		<script>inline()</script>
+		<link rel="stylesheet" href="/site.css" media="print">
+		<link rel="preload" href="/f.woff2" as="font" crossorigin>
+		<link rel="icon" href="/favicon.ico">
+		<style>@font-face { src: url("/g.woff") } body { background: url(/bg.png) }</style>
+	</head><body>
+		<img src="/a.png" srcset="/a-2x.png 2x" loading="lazy">
+		<video poster="/p.jpg"><source src="/v.mp4"><track src="/captions.vtt"></video>
+		<picture><source srcset="/b.webp"></picture>
+		<iframe src="https://widgets.example/" sandbox="allow-scripts"></iframe>
+		<object data="/doc.pdf"></object>
+		<form action="/search"><input type="image" src="/go.png"></form>
+	</body></html>`
+	doc, err := html.Parse(strings.NewReader(page))
+	if err != nil {
+		t.Fatalf("Failed to parse HTML: %v", err)
+	}
+	pageURL, _ := url.Parse("https://example.com/")
+	resources := inventoryResources(collectResourceRefs(doc, pageURL), pageURL, DefaultLinkPolicy)
+
+	var got []string
+	for _, r := range resources {
+		party := "first"
+		if !r.FirstParty {
+			party = "third"
+		}
+		got = append(got, string(r.Kind)+" "+party+" "+r.URL)
+	}
+	want := []string{
+		"script first https://example.com/app.js",
+		"script third https://cdn.example/lib.js",
+		"stylesheet first https://example.com/site.css",
+		"font first https://example.com/f.woff2",
+		"image first https://example.com/favicon.ico",
+		"font first https://example.com/g.woff",
+		"image first https://example.com/bg.png",
+		"image first https://example.com/a.png",
+		"image first https://example.com/a-2x.png",
+		"image first https://example.com/p.jpg",
+		"media first https://example.com/v.mp4",
+		"media first https://example.com/captions.vtt",
+		"image first https://example.com/b.webp",
+		"iframe third https://widgets.example/",
+		"object first https://example.com/doc.pdf",
+		"image first https://example.com/go.png",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected resources:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+
+	wantAttrs := map[string]string{"defer": "", "integrity": "sha384-x", "crossorigin": "anonymous"}
+	if !reflect.DeepEqual(resources[1].Attributes, wantAttrs) {
+		t.Errorf("Expected attributes %v, got %v", wantAttrs, resources[1].Attributes)
+	}
+	if resources[7].Attributes["loading"] != "lazy" || resources[0].Attributes["async"] != "" {
+		t.Errorf("Expected loading=lazy on the image and async on the script, got %v and %v", resources[7].Attributes, resources[0].Attributes)
+	}
+	if counts := countResources(resources); counts[ResourceImage] != 7 || counts[ResourceScript] != 2 || counts[ResourceFont] != 2 {
+		t.Errorf("Expected 7 images, 2 scripts and 2 fonts, got %v", counts)
+	}
+}
+
+func TestParseSrcset(t *testing.T) {
+	testCases := []struct {
+		srcset string
+		want   []string
+	}{
+		{"a.png", []string{"a.png"}},
+		{"a.png 1x, b.png 2x", []string{"a.png", "b.png"}},
+		{" a.png 480w,b.png  800w ,c.png", []string{"a.png", "b.png", "c.png"}},
+		{"a,b.png 1x, c.png 2x", []string{"a,b.png", "c.png"}},
+		{"", nil},
+	}
+	for _, tc := range testCases {
+		if got := parseSrcset(tc.srcset); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("parseSrcset(%q): expected %q, got %q", tc.srcset, tc.want, got)
+		}
+	}
+}
+
+func TestAnalyze_ResourcesChecked(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><link rel="stylesheet" href="/ok.css"><script src="/missing.js"></script></head>
+			<body><img src="/ok.png"><img src="/missing.png" srcset="/ok.png 1x"><a href="/ok.png">image</a></body></html>`))
+	})
+	mux.HandleFunc("/ok.css", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/ok.png", func(w http.ResponseWriter, r *http.Request) {})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	var total int
+	var done Progress
+	a := New(WithProgress(func(p Progress) {
+		switch p.Phase {
+		case PhaseCheckLinks:
+			total = p.LinksTotal
+		case PhaseDone:
+			done = p
+		}
+	}))
+	result, err := a.Analyze(t.Context(), server.URL)
+	if err != nil {
+		t.Fatalf("Analyze failed unexpectedly: %v", err)
+	}
+	// ok.css and ok.png are also links, so only the two missing resources are added
+	if total != 4 {
+		t.Errorf("Expected 4 unique URLs to be checked, got %d", total)
+	}
+	if done.LinksChecked != 4 || done.LinksTotal != 4 {
+		t.Errorf("Expected the final progress to report the same 4/4 URLs, got %d/%d", done.LinksChecked, done.LinksTotal)
+	}
+	var got []string
+	for _, l := range result.InaccessibleLinks {
+		got = append(got, l.Tag+" "+strings.TrimPrefix(l.URL, server.URL))
+	}
+	if want := []string{"script /missing.js", "img /missing.png"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected inaccessible resources %v, got %v", want, got)
+	}
+	if result.InternalLinksCount != 2 {
+		t.Errorf("Expected resources not to be counted as links, got %d internal links", result.InternalLinksCount)
+	}
+}
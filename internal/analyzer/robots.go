@@ -0,0 +1,378 @@
+package analyzer
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RobotsPolicy decides whether robots.txt rules are honoured
+type RobotsPolicy string
+
+const (
+	RobotsObey   RobotsPolicy = "obey"   // skip disallowed URLs and respect Crawl-delay
+	RobotsIgnore RobotsPolicy = "ignore" // never fetch robots.txt (e.g. for internal staging audits)
+)
+
+// ParseRobotsPolicy converts a flag or API value into a RobotsPolicy
+func ParseRobotsPolicy(s string) (RobotsPolicy, bool) {
+	switch p := RobotsPolicy(strings.ToLower(strings.TrimSpace(s))); p {
+	case RobotsObey, RobotsIgnore:
+		return p, true
+	}
+	return "", false
+}
+
+const (
+	robotsTimeout    = 5 * time.Second  // robots.txt fetch budget, within the caller's own deadline
+	robotsCacheTTL   = time.Hour        // how long a host's robots.txt is reused
+	robotsMaxBytes   = 500 * 1024       // robots.txt content beyond this is ignored, as crawlers commonly do
+	maxCrawlDelay    = 10 * time.Second // Crawl-delay values above this are capped so a single host can't stall an analysis
+	robotsWildcardUA = "*"
+)
+
+// robotsRule is a single Allow or Disallow line
+type robotsRule struct {
+	allow   bool
+	pattern string
+	re      *regexp.Regexp
+}
+
+// robotsRules are the rules of robots.txt that apply to our user agent
+type robotsRules struct {
+	rules      []robotsRule
+	crawlDelay time.Duration
+	sitemaps   []string // Sitemap: lines, which apply regardless of user agent
+}
+
+var (
+	allowAllRobots    = &robotsRules{}
+	disallowAllRobots = &robotsRules{rules: []robotsRule{compileRobotsRule(false, "/")}}
+)
+
+// allowed reports whether the path (including any query) may be fetched.
+// The longest matching pattern wins; on a tie Allow beats Disallow.
+func (r *robotsRules) allowed(path string) bool {
+	if path == "/robots.txt" {
+		return true
+	}
+	best := -1
+	allow := true
+	for _, rule := range r.rules {
+		if !rule.re.MatchString(path) {
+			continue
+		}
+		if l := len(rule.pattern); l > best || (l == best && rule.allow) {
+			best = l
+			allow = rule.allow
+		}
+	}
+	return allow
+}
+
+// compileRobotsRule turns a robots.txt path pattern into an anchored regexp.
+// "*" matches any sequence of characters and a trailing "$" anchors the end.
+func compileRobotsRule(allow bool, pattern string) robotsRule {
+	anchored := strings.HasSuffix(pattern, "$")
+	body := strings.TrimSuffix(pattern, "$")
+	parts := strings.Split(body, "*")
+	for i, p := range parts {
+		parts[i] = regexp.QuoteMeta(p)
+	}
+	expr := "^" + strings.Join(parts, ".*")
+	if anchored {
+		expr += "$"
+	}
+	return robotsRule{allow: allow, pattern: pattern, re: regexp.MustCompile(expr)}
+}
+
+// robotsGroup is one User-agent group while parsing
+type robotsGroup struct {
+	agents     []string
+	rules      []robotsRule
+	crawlDelay time.Duration
+}
+
+// parseRobots extracts the rules for the given product token (e.g. "webanalyzerbot").
+// The group whose User-agent line is the longest match for the token wins; groups
+// naming the same agent are merged, and "*" is used when nothing matches.
+func parseRobots(r io.Reader, token string) *robotsRules {
+	token = strings.ToLower(token)
+	var groups []*robotsGroup
+	var current *robotsGroup
+	var sitemaps []string
+	inRules := false
+
+	scanner := bufio.NewScanner(io.LimitReader(r, robotsMaxBytes))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if current == nil || inRules {
+				current = &robotsGroup{}
+				groups = append(groups, current)
+				inRules = false
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+		case "allow", "disallow":
+			if current == nil {
+				continue // rules before any User-agent line apply to nobody
+			}
+			inRules = true
+			if value == "" {
+				continue // an empty Disallow allows everything; an empty Allow means nothing
+			}
+			current.rules = append(current.rules, compileRobotsRule(key == "allow", value))
+		case "crawl-delay":
+			if current == nil {
+				continue
+			}
+			inRules = true
+			if secs, err := strconv.ParseFloat(value, 64); err == nil && secs > 0 {
+				current.crawlDelay = min(time.Duration(secs*float64(time.Second)), maxCrawlDelay)
+			}
+		case "sitemap":
+			if value != "" {
+				sitemaps = append(sitemaps, value)
+			}
+		}
+	}
+
+	// Pick the most specific matching agent name, falling back to "*"
+	bestAgent := ""
+	for _, g := range groups {
+		for _, agent := range g.agents {
+			if agent != robotsWildcardUA && strings.HasPrefix(token, agent) && len(agent) > len(bestAgent) {
+				bestAgent = agent
+			}
+		}
+	}
+	if bestAgent == "" {
+		bestAgent = robotsWildcardUA
+	}
+
+	rules := &robotsRules{sitemaps: sitemaps}
+	for _, g := range groups {
+		for _, agent := range g.agents {
+			if agent == bestAgent {
+				rules.rules = append(rules.rules, g.rules...)
+				rules.crawlDelay = max(rules.crawlDelay, g.crawlDelay)
+				break
+			}
+		}
+	}
+	return rules
+}
+
+// userAgentToken returns the product token of a User-Agent string ("WebAnalyzerBot/1.0 (...)" -> "WebAnalyzerBot")
+func userAgentToken(userAgent string) string {
+	token, _, _ := strings.Cut(userAgent, "/")
+	token, _, _ = strings.Cut(token, " ")
+	return token
+}
+
+// robotsEntry is the cached robots.txt of one origin. ready is closed once rules is set.
+type robotsEntry struct {
+	ready     chan struct{}
+	rules     *robotsRules
+	fetchedAt time.Time
+	nextSlot  time.Time // earliest time the next request may start, for Crawl-delay
+	abandoned bool      // the fetch was cut short by the caller's ctx, so the rules are not real
+}
+
+// RobotsCache fetches robots.txt at most once per origin, user agent and TTL, and spaces
+// requests to an origin by its Crawl-delay. It is safe for concurrent use; share one between
+// Analyzers with WithRobotsCache so that concurrent analyses of the same host are spaced together.
+type RobotsCache struct {
+	mu        sync.Mutex
+	entries   map[string]*robotsEntry
+	lastSweep time.Time
+}
+
+// NewRobotsCache creates an empty RobotsCache
+func NewRobotsCache() *RobotsCache {
+	return &RobotsCache{entries: make(map[string]*robotsEntry), lastSweep: time.Now()}
+}
+
+// sweep drops entries that have expired and whose Crawl-delay slot has passed, so a
+// long-lived cache doesn't keep every origin it has seen. c.mu must be held.
+func (c *RobotsCache) sweep(now time.Time) {
+	for key, entry := range c.entries {
+		select {
+		case <-entry.ready:
+			if now.Sub(entry.fetchedAt) > robotsCacheTTL && now.After(entry.nextSlot) {
+				delete(c.entries, key)
+			}
+		default: // still being fetched
+		}
+	}
+	c.lastSweep = now
+}
+
+// robotsFor returns the robots rules for the origin of u, fetching them if needed. A fetch
+// cut short by ctx is not cached, so a cancelled analysis can't hide a host's rules from
+// later ones; the caller gets rules allowing everything, as it is giving up anyway.
+func (a *Analyzer) robotsFor(ctx context.Context, u *url.URL) *robotsEntry {
+	origin := robotsOrigin(u)
+	key := userAgentToken(a.userAgent) + " " + origin // the rules that apply depend on the user agent
+
+	for {
+		a.robots.mu.Lock()
+		if now := time.Now(); now.Sub(a.robots.lastSweep) > robotsCacheTTL {
+			a.robots.sweep(now)
+		}
+		entry, ok := a.robots.entries[key]
+		if ok {
+			select {
+			case <-entry.ready:
+				if time.Since(entry.fetchedAt) > robotsCacheTTL {
+					ok = false // stale; fetch again below
+				}
+			default:
+			}
+		}
+		if !ok {
+			entry = &robotsEntry{ready: make(chan struct{})}
+			a.robots.entries[key] = entry
+			a.robots.mu.Unlock()
+
+			entry.rules = a.fetchRobots(ctx, origin)
+			entry.fetchedAt = time.Now()
+			if ctx.Err() != nil {
+				a.robots.mu.Lock()
+				if a.robots.entries[key] == entry {
+					delete(a.robots.entries, key)
+				}
+				a.robots.mu.Unlock()
+				entry.abandoned = true
+			}
+			close(entry.ready)
+			return entry
+		}
+		a.robots.mu.Unlock()
+
+		select {
+		case <-entry.ready:
+			if entry.abandoned {
+				continue // the fetching caller gave up; fetch again with our own ctx
+			}
+			return entry
+		case <-ctx.Done():
+			return &robotsEntry{rules: allowAllRobots} // caller is giving up anyway
+		}
+	}
+}
+
+// robotsOrigin returns the origin robots.txt is fetched and cached for: scheme and host
+// lowercased and a default port removed, so every spelling of a host shares its rules and
+// Crawl-delay
+func robotsOrigin(u *url.URL) string {
+	n := url.URL{Scheme: strings.ToLower(u.Scheme), Host: strings.ToLower(u.Host)}
+	if explicitPort(&n) == "" {
+		n.Host = strings.TrimSuffix(n.Host, ":"+n.Port())
+	}
+	return n.Scheme + "://" + n.Host
+}
+
+// fetchRobots downloads and parses robots.txt for an origin ("https://host:port").
+// A missing file (4xx) allows everything and a server error (5xx) disallows everything.
+// If the server can't be reached at all, everything is allowed so the link check itself
+// reports the real failure.
+func (a *Analyzer) fetchRobots(ctx context.Context, origin string) *robotsRules {
+	ctx, cancel := withTimeout(ctx, robotsTimeout)
+	defer cancel()
+
+	robotsURL := origin + "/robots.txt"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, robotsURL, nil)
+	if err != nil {
+		return allowAllRobots
+	}
+	req.Header.Set("User-Agent", a.userAgent)
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		slog.Debug("Could not fetch robots.txt, allowing all", "url", robotsURL, "error", err)
+		return allowAllRobots
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 500:
+		slog.Debug("robots.txt returned server error, disallowing all", "url", robotsURL, "status_code", resp.StatusCode)
+		return disallowAllRobots
+	case resp.StatusCode >= 400:
+		return allowAllRobots
+	}
+	rules := parseRobots(resp.Body, userAgentToken(a.userAgent))
+	slog.Debug("Fetched robots.txt", "url", robotsURL, "rules", len(rules.rules), "crawl_delay", rules.crawlDelay)
+	return rules
+}
+
+// robotsAllowed reports whether rawURL may be fetched under the analyzer's robots policy.
+// When allowed, it also waits for the origin's Crawl-delay slot. If ctx is done before the
+// rules are known or the slot comes up, it returns ctx's error and the URL must not be
+// fetched; no slot is used up in that case.
+func (a *Analyzer) robotsAllowed(ctx context.Context, rawURL string) (bool, error) {
+	if a.robotsPolicy != RobotsObey {
+		return true, nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return true, nil // not something robots.txt can govern; let the request report the problem
+	}
+
+	entry := a.robotsFor(ctx, u)
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	if !entry.rules.allowed(path) {
+		return false, nil
+	}
+
+	delay := entry.rules.crawlDelay
+	if delay <= 0 {
+		return true, nil
+	}
+	// Take the slot only once it has come up, so a caller giving up while waiting doesn't
+	// delay everyone after it
+	for {
+		a.robots.mu.Lock()
+		now := time.Now()
+		wait := entry.nextSlot.Sub(now)
+		if wait <= 0 {
+			entry.nextSlot = now.Add(delay)
+			a.robots.mu.Unlock()
+			return true, nil
+		}
+		a.robots.mu.Unlock()
+
+		if !sleep(ctx, wait) {
+			return false, ctx.Err()
+		}
+	}
+}
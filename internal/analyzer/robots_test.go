@@ -0,0 +1,312 @@
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseRobots(t *testing.T) {
+	robotsTxt := `
+# Example robots.txt
+User-agent: *
+Disallow: /private/
+Allow: /private/public-report.html
+Crawl-delay: 1
+
+User-agent: WebAnalyzer
+User-agent: OtherBot
+Disallow: /generic-bot-area
+
+User-agent: WebAnalyzerBot
+Disallow: /*.pdf$
+Disallow: /search?
+Allow: /search?q=allowed
+Disallow: /tmp
+Crawl-delay: 0.5
+
+Sitemap: https://example.com/sitemap.xml
+`
+	testCases := []struct {
+		name  string
+		token string
+		path  string
+		want  bool
+	}{
+		{"SpecificGroupPdf", "WebAnalyzerBot", "/docs/manual.pdf", false},
+		{"SpecificGroupPdfWithQuery", "WebAnalyzerBot", "/docs/manual.pdf?x=1", true}, // "$" anchors the end
+		{"SpecificGroupSearch", "WebAnalyzerBot", "/search?q=other", false},
+		{"SpecificGroupSearchAllowedLongerMatch", "WebAnalyzerBot", "/search?q=allowed", true},
+		{"SpecificGroupPrefix", "WebAnalyzerBot", "/tmpfile", false},
+		{"SpecificGroupIgnoresWildcardRules", "WebAnalyzerBot", "/private/secret", true},
+		{"SpecificGroupIgnoresLessSpecificGroup", "WebAnalyzerBot", "/generic-bot-area", true},
+		{"WildcardGroupDisallow", "SomeOtherCrawler", "/private/secret", false},
+		{"WildcardGroupAllowOverride", "SomeOtherCrawler", "/private/public-report.html", true},
+		{"WildcardGroupUnlisted", "SomeOtherCrawler", "/docs/manual.pdf", true},
+		{"RobotsTxtAlwaysAllowed", "WebAnalyzerBot", "/robots.txt", true},
+		{"SharedGroup", "OtherBot", "/generic-bot-area/x", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rules := parseRobots(strings.NewReader(robotsTxt), tc.token)
+			if got := rules.allowed(tc.path); got != tc.want {
+				t.Errorf("allowed(%q) for %s: expected %v, got %v", tc.path, tc.token, tc.want, got)
+			}
+		})
+	}
+
+	rules := parseRobots(strings.NewReader(robotsTxt), "WebAnalyzerBot")
+	if rules.crawlDelay != 500*time.Millisecond {
+		t.Errorf("Expected crawl delay 500ms, got %v", rules.crawlDelay)
+	}
+	if len(rules.sitemaps) != 1 || rules.sitemaps[0] != "https://example.com/sitemap.xml" {
+		t.Errorf("Expected one sitemap, got %v", rules.sitemaps)
+	}
+	if d := parseRobots(strings.NewReader(robotsTxt), "SomeOtherCrawler").crawlDelay; d != time.Second {
+		t.Errorf("Expected wildcard crawl delay 1s, got %v", d)
+	}
+}
+
+func TestAnalyze_Robots(t *testing.T) {
+	var mu sync.Mutex
+	robotsRequests := 0
+	requested := make(map[string]bool)
+	server := newMockServer(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested[r.URL.Path] = true
+		if r.URL.Path == "/robots.txt" {
+			robotsRequests++
+		}
+		mu.Unlock()
+
+		switch r.URL.Path {
+		case "/robots.txt":
+			fmt.Fprint(w, "User-agent: *\nDisallow: /private\nDisallow: /blocked-page\n")
+		case "/", "/blocked-page":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<html><body><a href="/public">ok</a><a href="/private/a">a</a><a href="/private/b">b</a></body></html>`)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	})
+	defer server.Close()
+
+	t.Run("Obey", func(t *testing.T) {
+		result, err := New().Analyze(context.Background(), server.URL)
+		if err != nil {
+			t.Fatalf("Analyze failed unexpectedly: %v", err)
+		}
+		if len(result.SkippedLinks) != 2 {
+			t.Fatalf("Expected 2 links skipped by robots.txt, got %+v", result.SkippedLinks)
+		}
+		for _, link := range result.SkippedLinks {
+			if !link.SkippedByRobots || !strings.Contains(link.URL, "/private/") {
+				t.Errorf("Unexpected skipped link: %+v", link)
+			}
+		}
+		if result.RobotsPolicy != RobotsObey {
+			t.Errorf("Expected robots policy %q in result, got %q", RobotsObey, result.RobotsPolicy)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if requested["/private/a"] || requested["/private/b"] {
+			t.Errorf("Expected disallowed links not to be requested")
+		}
+		if !requested["/public"] {
+			t.Errorf("Expected allowed link to be checked")
+		}
+		if robotsRequests != 1 {
+			t.Errorf("Expected robots.txt to be fetched once, got %d", robotsRequests)
+		}
+	})
+
+	t.Run("DisallowedPage", func(t *testing.T) {
+		_, err := New().Analyze(context.Background(), server.URL+"/blocked-page")
+		var ae *AnalysisError
+		if !errors.As(err, &ae) || ae.Category != ErrorCategoryRobots {
+			t.Errorf("Expected AnalysisError with category %q, got %v", ErrorCategoryRobots, err)
+		}
+	})
+
+	t.Run("Ignore", func(t *testing.T) {
+		result, err := New(WithRobotsPolicy(RobotsIgnore)).Analyze(context.Background(), server.URL+"/blocked-page")
+		if err != nil {
+			t.Fatalf("Analyze failed unexpectedly: %v", err)
+		}
+		if len(result.SkippedLinks) != 0 {
+			t.Errorf("Expected no skipped links with RobotsIgnore, got %+v", result.SkippedLinks)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if !requested["/private/a"] {
+			t.Errorf("Expected disallowed links to be checked with RobotsIgnore")
+		}
+	})
+}
+
+func TestAnalyze_RobotsCrawlDelay(t *testing.T) {
+	var mu sync.Mutex
+	var linkTimes []time.Time
+	server := newMockServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			fmt.Fprint(w, "User-agent: *\nCrawl-delay: 0.2\n")
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<html><body><a href="/1">1</a><a href="/2">2</a><a href="/3">3</a></body></html>`)
+		default:
+			mu.Lock()
+			linkTimes = append(linkTimes, time.Now())
+			mu.Unlock()
+		}
+	})
+	defer server.Close()
+
+	start := time.Now()
+	if _, err := New().Analyze(context.Background(), server.URL); err != nil {
+		t.Fatalf("Analyze failed unexpectedly: %v", err)
+	}
+	// The page fetch and three link checks must be spaced by the crawl delay
+	if elapsed := time.Since(start); elapsed < 600*time.Millisecond {
+		t.Errorf("Expected requests to be spaced by the 200ms crawl delay, whole analysis took %v", elapsed)
+	}
+	if len(linkTimes) != 3 {
+		t.Fatalf("Expected 3 link requests, got %d", len(linkTimes))
+	}
+}
+
+func TestAnalyze_RobotsCrawlDelayOutsideLinkTimeout(t *testing.T) {
+	server := newMockServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			fmt.Fprint(w, "User-agent: *\nCrawl-delay: 0.2\n")
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<html><body><a href="/1">1</a><a href="/2">2</a><a href="/3">3</a><a href="/4">4</a><a href="/5">5</a><a href="/6">6</a></body></html>`)
+		}
+	})
+	defer server.Close()
+
+	// Waiting for the crawl delay of the later links takes far longer than the link timeout
+	result, err := New(WithLinkTimeout(300*time.Millisecond)).Analyze(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("Analyze failed unexpectedly: %v", err)
+	}
+	if len(result.InaccessibleLinks) != 0 {
+		t.Errorf("Expected the crawl delay not to count against the link timeout, got %+v", result.InaccessibleLinks)
+	}
+}
+
+func TestRobotsAllowed_Cancelled(t *testing.T) {
+	var robotsFetches atomic.Int32
+	server := newMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			if robotsFetches.Add(1) == 1 {
+				time.Sleep(200 * time.Millisecond)
+			}
+			fmt.Fprint(w, "User-agent: *\nDisallow: /private\nCrawl-delay: 0.5\n")
+		}
+	})
+	defer server.Close()
+	a := New()
+
+	// A robots.txt fetch cut short by the caller is not cached as allowing everything
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := a.robotsAllowed(ctx, server.URL+"/private"); err == nil {
+		t.Fatal("Expected an error when ctx ends during the robots.txt fetch")
+	}
+	if allowed, err := a.robotsAllowed(context.Background(), server.URL+"/private"); allowed || err != nil {
+		t.Fatalf("Expected /private to be disallowed after the abandoned fetch, got %v, %v", allowed, err)
+	}
+
+	// The first allowed request takes the crawl delay slot; a caller giving up on the next
+	// one doesn't push back the one after it
+	if allowed, err := a.robotsAllowed(context.Background(), server.URL+"/a"); !allowed || err != nil {
+		t.Fatalf("Expected /a to be allowed, got %v, %v", allowed, err)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if allowed, err := a.robotsAllowed(ctx, server.URL+"/b"); allowed || err == nil {
+		t.Fatalf("Expected /b to give up waiting for its slot, got %v, %v", allowed, err)
+	}
+	start := time.Now()
+	if allowed, err := a.robotsAllowed(context.Background(), server.URL+"/c"); !allowed || err != nil {
+		t.Fatalf("Expected /c to be allowed, got %v, %v", allowed, err)
+	}
+	if elapsed := time.Since(start); elapsed > 600*time.Millisecond {
+		t.Errorf("Expected /c to take the slot /b gave up within one crawl delay, waited %v", elapsed)
+	}
+}
+
+func TestWithRobotsCache_Shared(t *testing.T) {
+	var robotsFetches atomic.Int32
+	server := newMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			robotsFetches.Add(1)
+			fmt.Fprint(w, "User-agent: OtherBot\nDisallow: /\n")
+		}
+	})
+	defer server.Close()
+
+	cache := NewRobotsCache()
+	for range 2 {
+		if allowed, _ := New(WithRobotsCache(cache)).robotsAllowed(context.Background(), server.URL+"/page"); !allowed {
+			t.Fatal("Expected /page to be allowed for the default user agent")
+		}
+	}
+	if got := robotsFetches.Load(); got != 1 {
+		t.Errorf("Expected analyzers sharing a cache to fetch robots.txt once, got %d fetches", got)
+	}
+	// Rules are cached per user agent
+	if allowed, _ := New(WithRobotsCache(cache), WithUserAgent("OtherBot/2.0")).robotsAllowed(context.Background(), server.URL+"/page"); allowed {
+		t.Error("Expected /page to be disallowed for OtherBot")
+	}
+}
+
+func TestRobotsFor_HostSpelling(t *testing.T) {
+	var robotsFetches atomic.Int32
+	server := newMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			robotsFetches.Add(1)
+			fmt.Fprint(w, "User-agent: *\nDisallow: /private\n")
+		}
+	})
+	defer server.Close()
+
+	a := New()
+	lower := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+	for _, page := range []string{lower + "/a", strings.Replace(lower, "localhost", "LocalHost", 1) + "/b"} {
+		if allowed, err := a.robotsAllowed(context.Background(), page); !allowed || err != nil {
+			t.Fatalf("Expected %s to be allowed, got %v, %v", page, allowed, err)
+		}
+	}
+	if got := robotsFetches.Load(); got != 1 {
+		t.Errorf("Expected one robots.txt fetch for both spellings of the host, got %d", got)
+	}
+}
+
+func TestRobotsCache_Sweep(t *testing.T) {
+	now := time.Now()
+	fetched := &robotsEntry{ready: make(chan struct{}), rules: allowAllRobots, fetchedAt: now}
+	close(fetched.ready)
+	fetching := &robotsEntry{ready: make(chan struct{})}
+	cache := NewRobotsCache()
+	cache.entries["a"], cache.entries["b"] = fetched, fetching
+
+	cache.sweep(now.Add(time.Minute))
+	if len(cache.entries) != 2 {
+		t.Fatalf("Expected fresh entries to be kept, got %d", len(cache.entries))
+	}
+	cache.sweep(now.Add(robotsCacheTTL + time.Minute))
+	if _, ok := cache.entries["a"]; ok || cache.entries["b"] != fetching {
+		t.Errorf("Expected only the expired entry to be dropped, got %v", cache.entries)
+	}
+}
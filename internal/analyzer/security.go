@@ -0,0 +1,342 @@
+package analyzer
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Headers audited by auditSecurity
+const (
+	HeaderHSTS                = "Strict-Transport-Security"
+	HeaderCSP                 = "Content-Security-Policy"
+	HeaderCSPReportOnly       = "Content-Security-Policy-Report-Only"
+	HeaderFrameOptions        = "X-Frame-Options"
+	HeaderContentTypeOptions  = "X-Content-Type-Options"
+	HeaderReferrerPolicy      = "Referrer-Policy"
+	HeaderPermissionsPolicy   = "Permissions-Policy"
+	HeaderCrossOriginOpener   = "Cross-Origin-Opener-Policy"
+	HeaderCrossOriginEmbedder = "Cross-Origin-Embedder-Policy"
+	HeaderSetCookie           = "Set-Cookie"
+)
+
+const (
+	transportFinding       = "Transport"        // Header of findings about the connection itself
+	minHSTSMaxAge          = 180 * 24 * 60 * 60 // seconds; shorter policies lapse between visits
+	securityErrorPenalty   = 15                 // score points lost per error finding
+	securityWarningPenalty = 5                  // score points lost per warning finding
+)
+
+// auditedHeaders lists the headers in the order they are reported
+var auditedHeaders = []string{
+	HeaderHSTS, HeaderCSP, HeaderCSPReportOnly, HeaderFrameOptions, HeaderContentTypeOptions,
+	HeaderReferrerPolicy, HeaderPermissionsPolicy, HeaderCrossOriginOpener, HeaderCrossOriginEmbedder,
+}
+
+// SecurityReport is the audit of the security headers and transport of the fetched page
+type SecurityReport struct {
+	HTTPS    bool              `json:"https"`
+	Headers  []SecurityHeader  `json:"headers"` // every audited header, present or not
+	HSTS     *HSTSPolicy       `json:"hsts,omitempty"`
+	CSP      *CSPPolicy        `json:"csp,omitempty"` // enforced policy, or the report-only one if there is none
+	Cookies  []CookieAudit     `json:"cookies"`
+	Findings []SecurityFinding `json:"findings"`
+	Score    int               `json:"score"` // 0 to 100
+	Grade    string            `json:"grade"` // A to F
+}
+
+// SecurityHeader is the value of an audited response header
+type SecurityHeader struct {
+	Name    string `json:"name"`
+	Value   string `json:"value,omitempty"` // repeated headers are joined with ", "
+	Present bool   `json:"present"`
+}
+
+// HSTSPolicy is a parsed Strict-Transport-Security header
+type HSTSPolicy struct {
+	MaxAge            int64 `json:"max_age"` // seconds, -1 if missing or invalid
+	IncludeSubDomains bool  `json:"include_subdomains"`
+	Preload           bool  `json:"preload"`
+}
+
+// CSPPolicy is a parsed Content-Security-Policy header
+type CSPPolicy struct {
+	ReportOnly bool                `json:"report_only"`
+	Directives map[string][]string `json:"directives"` // directive name to its sources, names lowercased
+}
+
+// CookieAudit describes the security attributes of a cookie set by the page
+type CookieAudit struct {
+	Name     string `json:"name"`
+	Secure   bool   `json:"secure"`
+	HttpOnly bool   `json:"http_only"`
+	SameSite string `json:"same_site,omitempty"` // Strict, Lax, None, or empty when not set
+}
+
+// SecurityFinding is a weakness found by the security audit
+type SecurityFinding struct {
+	Header   string `json:"header"`   // header the finding is about, or "Transport"
+	Severity string `json:"severity"` // SeverityError or SeverityWarning
+	Message  string `json:"message"`
+}
+
+// auditSecurity audits the security headers and cookies of the response for pageURL, the
+// final URL after redirects
+func auditSecurity(h http.Header, cookies []*http.Cookie, pageURL *url.URL) SecurityReport {
+	report := SecurityReport{HTTPS: pageURL.Scheme == "https", Headers: []SecurityHeader{}, Cookies: []CookieAudit{}, Findings: []SecurityFinding{}}
+	add := func(header, severity, format string, args ...any) {
+		report.Findings = append(report.Findings, SecurityFinding{Header: header, Severity: severity, Message: fmt.Sprintf(format, args...)})
+	}
+	value := func(name string) string {
+		return strings.TrimSpace(strings.Join(h.Values(name), ", "))
+	}
+	for _, name := range auditedHeaders {
+		v := value(name)
+		report.Headers = append(report.Headers, SecurityHeader{Name: name, Value: v, Present: v != ""})
+	}
+
+	// Transport and HSTS. Browsers ignore HSTS received over plain HTTP.
+	if !report.HTTPS {
+		add(transportFinding, SeverityError, "page is served over plain HTTP")
+	} else if v := value(HeaderHSTS); v == "" {
+		add(HeaderHSTS, SeverityError, "missing; browsers may connect over plain HTTP first")
+	} else {
+		report.HSTS = parseHSTS(h.Get(HeaderHSTS))
+		switch {
+		case report.HSTS.MaxAge < 0:
+			add(HeaderHSTS, SeverityError, "max-age is missing or invalid, so the header is ignored")
+		case report.HSTS.MaxAge == 0:
+			add(HeaderHSTS, SeverityError, "max-age=0 removes the HSTS policy")
+		case report.HSTS.MaxAge < minHSTSMaxAge:
+			add(HeaderHSTS, SeverityWarning, "max-age of %d seconds is shorter than 180 days", report.HSTS.MaxAge)
+		}
+	}
+
+	// Content Security Policy
+	frameAncestors := false
+	if v := value(HeaderCSP); v != "" {
+		report.CSP = parseCSP(v, false)
+	} else if v := value(HeaderCSPReportOnly); v != "" {
+		report.CSP = parseCSP(v, true)
+		add(HeaderCSP, SeverityWarning, "only a report-only policy is set, so nothing is enforced")
+	} else {
+		add(HeaderCSP, SeverityError, "missing; injected scripts run unrestricted")
+	}
+	if report.CSP != nil {
+		report.Findings = append(report.Findings, cspWeaknesses(report.CSP)...)
+		_, frameAncestors = report.CSP.Directives["frame-ancestors"]
+		frameAncestors = frameAncestors && !report.CSP.ReportOnly
+	}
+
+	// Framing
+	switch xfo := strings.ToUpper(value(HeaderFrameOptions)); {
+	case xfo == "DENY" || xfo == "SAMEORIGIN":
+	case xfo == "" && !frameAncestors:
+		add(HeaderFrameOptions, SeverityError, "neither X-Frame-Options nor CSP frame-ancestors is set; the page can be framed for clickjacking")
+	case xfo != "" && !frameAncestors:
+		add(HeaderFrameOptions, SeverityWarning, "invalid value %q; only DENY and SAMEORIGIN are supported", value(HeaderFrameOptions))
+	}
+
+	if v := strings.ToLower(value(HeaderContentTypeOptions)); v != "nosniff" {
+		add(HeaderContentTypeOptions, SeverityWarning, "should be nosniff to stop MIME type sniffing")
+	}
+
+	// The last valid token of Referrer-Policy wins
+	referrer := ""
+	for _, token := range splitList(value(HeaderReferrerPolicy)) {
+		switch t := strings.ToLower(token); t {
+		case "no-referrer", "no-referrer-when-downgrade", "origin", "origin-when-cross-origin", "same-origin",
+			"strict-origin", "strict-origin-when-cross-origin", "unsafe-url":
+			referrer = t
+		}
+	}
+	switch referrer {
+	case "":
+		add(HeaderReferrerPolicy, SeverityWarning, "missing or invalid; the browser default applies")
+	case "unsafe-url", "no-referrer-when-downgrade":
+		add(HeaderReferrerPolicy, SeverityWarning, "%s leaks full URLs to other origins", referrer)
+	}
+
+	if value(HeaderPermissionsPolicy) == "" {
+		add(HeaderPermissionsPolicy, SeverityWarning, "missing; powerful features such as camera and geolocation are not restricted")
+	}
+
+	switch v := strings.ToLower(firstToken(value(HeaderCrossOriginOpener))); v {
+	case "same-origin", "same-origin-allow-popups", "noopener-allow-popups":
+	case "", "unsafe-none":
+		add(HeaderCrossOriginOpener, SeverityWarning, "cross-origin windows opened by or opening the page keep a reference to it")
+	default:
+		add(HeaderCrossOriginOpener, SeverityWarning, "invalid value %q", v)
+	}
+	// COEP is only needed for cross-origin isolation, so only invalid values are reported
+	switch v := strings.ToLower(firstToken(value(HeaderCrossOriginEmbedder))); v {
+	case "", "unsafe-none", "require-corp", "credentialless":
+	default:
+		add(HeaderCrossOriginEmbedder, SeverityWarning, "invalid value %q", v)
+	}
+
+	for _, c := range cookies {
+		audit := CookieAudit{Name: c.Name, Secure: c.Secure, HttpOnly: c.HttpOnly}
+		switch c.SameSite {
+		case http.SameSiteStrictMode:
+			audit.SameSite = "Strict"
+		case http.SameSiteLaxMode:
+			audit.SameSite = "Lax"
+		case http.SameSiteNoneMode:
+			audit.SameSite = "None"
+		}
+		report.Cookies = append(report.Cookies, audit)
+
+		header := HeaderSetCookie + ": " + c.Name
+		if report.HTTPS && !c.Secure {
+			add(header, SeverityError, "missing Secure; the cookie is also sent over plain HTTP")
+		}
+		if !c.HttpOnly {
+			add(header, SeverityWarning, "missing HttpOnly; scripts can read the cookie")
+		}
+		switch {
+		case audit.SameSite == "":
+			add(header, SeverityWarning, "missing SameSite; the browser default applies")
+		case audit.SameSite == "None" && !c.Secure:
+			add(header, SeverityError, "SameSite=None without Secure is rejected by browsers")
+		}
+	}
+
+	errors, warnings := report.Counts()
+	report.Score = 100 - errors*securityErrorPenalty - warnings*securityWarningPenalty
+	report.Score = max(report.Score, 0)
+	report.Grade = securityGrade(report.Score)
+	return report
+}
+
+// securityGrade maps a score to a letter grade
+func securityGrade(score int) string {
+	switch {
+	case score >= 90:
+		return "A"
+	case score >= 75:
+		return "B"
+	case score >= 60:
+		return "C"
+	case score >= 40:
+		return "D"
+	default:
+		return "F"
+	}
+}
+
+// parseHSTS parses a Strict-Transport-Security header value
+func parseHSTS(v string) *HSTSPolicy {
+	p := &HSTSPolicy{MaxAge: -1}
+	for _, directive := range strings.Split(v, ";") {
+		name, val, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "max-age":
+			if n, err := strconv.ParseInt(strings.Trim(strings.TrimSpace(val), `"`), 10, 64); err == nil && n >= 0 {
+				p.MaxAge = n
+			}
+		case "includesubdomains":
+			p.IncludeSubDomains = true
+		case "preload":
+			p.Preload = true
+		}
+	}
+	return p
+}
+
+// parseCSP parses a Content-Security-Policy header value. Multiple policies joined with
+// commas are merged; the first occurrence of a directive wins, as within one policy.
+func parseCSP(v string, reportOnly bool) *CSPPolicy {
+	p := &CSPPolicy{ReportOnly: reportOnly, Directives: map[string][]string{}}
+	for _, policy := range strings.Split(v, ",") {
+		for _, directive := range strings.Split(policy, ";") {
+			fields := strings.Fields(directive)
+			if len(fields) == 0 {
+				continue
+			}
+			name := strings.ToLower(fields[0])
+			if _, seen := p.Directives[name]; !seen {
+				p.Directives[name] = fields[1:]
+			}
+		}
+	}
+	return p
+}
+
+// sources returns the sources that apply to a fetch directive, falling back to default-src
+func (p *CSPPolicy) sources(directive string) ([]string, bool) {
+	if s, ok := p.Directives[directive]; ok {
+		return s, true
+	}
+	s, ok := p.Directives["default-src"]
+	return s, ok
+}
+
+// cspWeaknesses returns the weaknesses of a policy
+func cspWeaknesses(p *CSPPolicy) []SecurityFinding {
+	var findings []SecurityFinding
+	add := func(severity, format string, args ...any) {
+		findings = append(findings, SecurityFinding{Header: HeaderCSP, Severity: severity, Message: fmt.Sprintf(format, args...)})
+	}
+
+	scripts, ok := p.sources("script-src")
+	if !ok {
+		add(SeverityError, "no script-src or default-src, so scripts are not restricted")
+	} else {
+		lower := make([]string, len(scripts))
+		for i, s := range scripts {
+			lower[i] = strings.ToLower(s)
+		}
+		// A nonce or hash makes browsers ignore 'unsafe-inline'
+		nonceOrHash := slices.ContainsFunc(lower, func(s string) bool {
+			return strings.HasPrefix(s, "'nonce-") || strings.HasPrefix(s, "'sha256-") || strings.HasPrefix(s, "'sha384-") || strings.HasPrefix(s, "'sha512-")
+		})
+		if slices.Contains(lower, "'unsafe-inline'") && !nonceOrHash {
+			add(SeverityError, "script-src allows 'unsafe-inline', which defeats XSS protection")
+		}
+		if slices.Contains(lower, "'unsafe-eval'") {
+			add(SeverityWarning, "script-src allows 'unsafe-eval'")
+		}
+		// 'strict-dynamic' makes browsers ignore host and scheme sources
+		if !slices.Contains(lower, "'strict-dynamic'") {
+			for _, s := range lower {
+				switch s {
+				case "*", "http:", "https:", "data:":
+					add(SeverityError, "script-src allows %s, so scripts can be loaded from anywhere", s)
+				}
+			}
+		}
+	}
+
+	if objects, ok := p.sources("object-src"); !ok || !slices.ContainsFunc(objects, func(s string) bool { return strings.EqualFold(s, "'none'") }) {
+		add(SeverityWarning, "object-src is not 'none', so plugins can run scripts")
+	}
+	if _, ok := p.Directives["base-uri"]; !ok {
+		add(SeverityWarning, "base-uri is not set, so injected <base> tags can redirect relative scripts")
+	}
+	if styles, ok := p.sources("style-src"); ok && slices.ContainsFunc(styles, func(s string) bool { return strings.EqualFold(s, "'unsafe-inline'") }) {
+		add(SeverityWarning, "style-src allows 'unsafe-inline'")
+	}
+	return findings
+}
+
+// firstToken returns v up to the first ';', trimmed, for headers with parameters such as COOP
+func firstToken(v string) string {
+	token, _, _ := strings.Cut(v, ";")
+	return strings.TrimSpace(token)
+}
+
+// Counts returns the number of error and warning findings
+func (r SecurityReport) Counts() (errors, warnings int) {
+	for _, f := range r.Findings {
+		if f.Severity == SeverityError {
+			errors++
+		} else {
+			warnings++
+		}
+	}
+	return errors, warnings
+}
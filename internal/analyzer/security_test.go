@@ -0,0 +1,171 @@
+package analyzer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// hardenedHeaders is a response that passes every check of auditSecurity
+func hardenedHeaders() http.Header {
+	h := http.Header{}
+	h.Set(HeaderHSTS, "max-age=31536000; includeSubDomains; preload")
+	h.Set(HeaderCSP, "default-src 'self'; script-src 'self' 'nonce-abc' 'unsafe-inline'; object-src 'none'; base-uri 'none'; frame-ancestors 'none'")
+	h.Set(HeaderContentTypeOptions, "nosniff")
+	h.Set(HeaderReferrerPolicy, "strict-origin-when-cross-origin")
+	h.Set(HeaderPermissionsPolicy, "camera=(), geolocation=()")
+	h.Set(HeaderCrossOriginOpener, "same-origin")
+	return h
+}
+
+func TestAuditSecurity(t *testing.T) {
+	testCases := []struct {
+		name      string
+		pageURL   string
+		modify    func(h http.Header)
+		want      []SecurityFinding // Message is a substring of the expected message
+		wantGrade string
+	}{
+		{
+			name:      "Hardened",
+			pageURL:   "https://example.com/",
+			modify:    func(h http.Header) { h.Add(HeaderSetCookie, "sid=1; Secure; HttpOnly; SameSite=Lax") },
+			wantGrade: "A",
+		},
+		{
+			name:    "Plain HTTP",
+			pageURL: "http://example.com/",
+			modify:  func(h http.Header) { h.Add(HeaderSetCookie, "sid=1; HttpOnly; SameSite=None") },
+			want: []SecurityFinding{
+				{"Transport", SeverityError, "plain HTTP"},
+				{"Set-Cookie: sid", SeverityError, "SameSite=None without Secure"},
+			},
+			wantGrade: "C",
+		},
+		{
+			name:    "Missing headers",
+			pageURL: "https://example.com/",
+			modify: func(h http.Header) {
+				for k := range h {
+					h.Del(k)
+				}
+				h.Add(HeaderSetCookie, "sid=1")
+			},
+			want: []SecurityFinding{
+				{"Strict-Transport-Security", SeverityError, "missing"},
+				{"Content-Security-Policy", SeverityError, "missing"},
+				{"X-Frame-Options", SeverityError, "clickjacking"},
+				{"X-Content-Type-Options", SeverityWarning, "nosniff"},
+				{"Referrer-Policy", SeverityWarning, "missing"},
+				{"Permissions-Policy", SeverityWarning, "missing"},
+				{"Cross-Origin-Opener-Policy", SeverityWarning, "keep a reference"},
+				{"Set-Cookie: sid", SeverityError, "missing Secure"},
+				{"Set-Cookie: sid", SeverityWarning, "missing HttpOnly"},
+				{"Set-Cookie: sid", SeverityWarning, "missing SameSite"},
+			},
+			wantGrade: "F",
+		},
+		{
+			name:    "Weak values",
+			pageURL: "https://example.com/",
+			modify: func(h http.Header) {
+				h.Set(HeaderHSTS, "max-age=3600")
+				h.Set(HeaderCSP, "script-src 'self' 'unsafe-inline' 'unsafe-eval' https:; style-src 'unsafe-inline'")
+				h.Set(HeaderFrameOptions, "ALLOW-FROM https://example.org")
+				h.Set(HeaderReferrerPolicy, "no-referrer, unsafe-url")
+				h.Set(HeaderCrossOriginEmbedder, "require-everything")
+			},
+			want: []SecurityFinding{
+				{"Strict-Transport-Security", SeverityWarning, "shorter than 180 days"},
+				{"Content-Security-Policy", SeverityError, "'unsafe-inline'"},
+				{"Content-Security-Policy", SeverityWarning, "'unsafe-eval'"},
+				{"Content-Security-Policy", SeverityError, "allows https:"},
+				{"Content-Security-Policy", SeverityWarning, "object-src"},
+				{"Content-Security-Policy", SeverityWarning, "base-uri"},
+				{"Content-Security-Policy", SeverityWarning, "style-src"},
+				{"X-Frame-Options", SeverityWarning, "invalid value"},
+				{"Referrer-Policy", SeverityWarning, "unsafe-url"},
+				{"Cross-Origin-Embedder-Policy", SeverityWarning, "invalid value"},
+			},
+			wantGrade: "F",
+		},
+		{
+			name:    "Report-only CSP",
+			pageURL: "https://example.com/",
+			modify: func(h http.Header) {
+				h.Set(HeaderCSPReportOnly, h.Get(HeaderCSP))
+				h.Del(HeaderCSP)
+				h.Set(HeaderFrameOptions, "sameorigin")
+			},
+			want:      []SecurityFinding{{"Content-Security-Policy", SeverityWarning, "report-only"}},
+			wantGrade: "A",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := hardenedHeaders()
+			tc.modify(h)
+			pageURL, _ := url.Parse(tc.pageURL)
+			resp := http.Response{Header: h}
+			report := auditSecurity(h, resp.Cookies(), pageURL)
+
+			if len(report.Findings) != len(tc.want) {
+				t.Fatalf("Expected %d finding(s), got %d: %+v", len(tc.want), len(report.Findings), report.Findings)
+			}
+			for i, want := range tc.want {
+				f := report.Findings[i]
+				if f.Header != want.Header || f.Severity != want.Severity || !strings.Contains(f.Message, want.Message) {
+					t.Errorf("Expected finding %d to be like %+v, got %+v", i, want, f)
+				}
+			}
+			if report.Grade != tc.wantGrade {
+				t.Errorf("Expected grade %s, got %s (score %d)", tc.wantGrade, report.Grade, report.Score)
+			}
+		})
+	}
+}
+
+func TestParseCSP(t *testing.T) {
+	p := parseCSP("Default-Src 'self'; script-src 'self' cdn.example.com;; script-src 'none', img-src *", false)
+	want := map[string][]string{
+		"default-src": {"'self'"},
+		"script-src":  {"'self'", "cdn.example.com"},
+		"img-src":     {"*"},
+	}
+	if !reflect.DeepEqual(p.Directives, want) {
+		t.Errorf("Expected directives %v, got %v", want, p.Directives)
+	}
+}
+
+func TestAnalyze_Security(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Header().Set(HeaderFrameOptions, "DENY")
+		http.SetCookie(w, &http.Cookie{Name: "sid", Value: "1", HttpOnly: true, SameSite: http.SameSiteStrictMode})
+		w.Write([]byte("<html><body></body></html>"))
+	}))
+	defer server.Close()
+
+	result, err := New(WithLinkCheck(false)).Analyze(t.Context(), server.URL)
+	if err != nil {
+		t.Fatalf("Analyze failed unexpectedly: %v", err)
+	}
+	sec := result.Security
+	if sec.HTTPS {
+		t.Errorf("Expected HTTPS to be false for %s", server.URL)
+	}
+	wantCookies := []CookieAudit{{Name: "sid", HttpOnly: true, SameSite: "Strict"}}
+	if !reflect.DeepEqual(sec.Cookies, wantCookies) {
+		t.Errorf("Expected cookies %+v, got %+v", wantCookies, sec.Cookies)
+	}
+	if len(sec.Headers) != len(auditedHeaders) || sec.Headers[3].Name != HeaderFrameOptions || sec.Headers[3].Value != "DENY" || !sec.Headers[3].Present {
+		t.Errorf("Expected every audited header with X-Frame-Options: DENY, got %+v", sec.Headers)
+	}
+	if sec.Grade == "" {
+		t.Error("Expected a grade to be set")
+	}
+}
@@ -0,0 +1,290 @@
+package analyzer
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+const (
+	maxSitemapFiles = 20       // sitemap files fetched per site, including those listed in indexes
+	maxSitemapURLs  = 50000    // URLs collected per site; the protocol's limit for a single file
+	maxSitemapBytes = 50 << 20 // uncompressed size limit of one sitemap file, as in the protocol
+	maxSitemapCheck = 100      // sitemap URLs whose status is checked
+)
+
+// Where a sitemap file was found
+const (
+	SitemapSourceRobots  = "robots.txt" // a Sitemap: line in robots.txt
+	SitemapSourceDefault = "default"    // the conventional /sitemap.xml location
+	SitemapSourceIndex   = "index"      // listed in a sitemap index
+)
+
+// Kinds of sitemap file
+const (
+	SitemapTypeURLSet = "urlset"
+	SitemapTypeIndex  = "sitemapindex"
+)
+
+// SitemapFile describes one fetched sitemap file
+type SitemapFile struct {
+	URL      string `json:"url"`
+	Source   string `json:"source"`
+	Type     string `json:"type,omitempty"` // empty when the file could not be read
+	Gzipped  bool   `json:"gzipped"`
+	URLCount int    `json:"url_count"` // <url> entries, or <sitemap> entries of an index
+	Error    string `json:"error,omitempty"`
+}
+
+// SitemapURLIssue is a sitemap URL that is not in canonical form
+type SitemapURLIssue struct {
+	URL    string `json:"url"`
+	Reason string `json:"reason"`
+}
+
+// SitemapReport is the outcome of sitemap discovery and validation for a site
+type SitemapReport struct {
+	Files        []SitemapFile     `json:"files"`
+	URLs         []string          `json:"-"` // every distinct URL listed, in document order
+	URLsTotal    int               `json:"urls_total"`
+	URLsChecked  int               `json:"urls_checked"`
+	Truncated    bool              `json:"truncated"` // more URLs were listed than were checked
+	Broken       []LinkCheckResult `json:"broken"`
+	Redirecting  []LinkCheckResult `json:"redirecting"`
+	Blocked      []LinkCheckResult `json:"blocked"` // disallowed by robots.txt
+	NonCanonical []SitemapURLIssue `json:"non_canonical"`
+}
+
+// Found reports whether at least one sitemap file could be read
+func (r *SitemapReport) Found() bool {
+	for _, f := range r.Files {
+		if f.Error == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// xmlSitemap holds the parts of a <urlset> or <sitemapindex> document the analyzer uses.
+// Tags without a namespace match the sitemaps.org elements regardless of their namespace.
+type xmlSitemap struct {
+	URLs     []xmlSitemapLoc `xml:"url"`
+	Sitemaps []xmlSitemapLoc `xml:"sitemap"`
+}
+
+type xmlSitemapLoc struct {
+	Loc string `xml:"loc"`
+}
+
+// CheckSitemaps discovers the sitemaps of the site siteURL belongs to, from the Sitemap:
+// lines of its robots.txt (unless the robots policy is RobotsIgnore) and the default
+// /sitemap.xml, follows sitemap indexes, and checks the listed URLs with the same machinery
+// as page links.
+func (a *Analyzer) CheckSitemaps(ctx context.Context, siteURL string) (*SitemapReport, error) {
+	site, err := ValidateURL(siteURL)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &SitemapReport{
+		Files:        []SitemapFile{},
+		URLs:         []string{},
+		Broken:       []LinkCheckResult{},
+		Redirecting:  []LinkCheckResult{},
+		Blocked:      []LinkCheckResult{},
+		NonCanonical: []SitemapURLIssue{},
+	}
+
+	type pending struct{ url, source string }
+	var queue []pending
+	queued := make(map[string]bool)
+	enqueue := func(u, source string) {
+		if !queued[u] {
+			queued[u] = true
+			queue = append(queue, pending{u, source})
+		}
+	}
+	if a.robotsPolicy == RobotsObey { // RobotsIgnore never fetches robots.txt, so only /sitemap.xml is tried
+		for _, u := range a.robotsFor(ctx, site).rules.sitemaps {
+			enqueue(u, SitemapSourceRobots)
+		}
+	}
+	defaultURL := site.Scheme + "://" + site.Host + "/sitemap.xml"
+	enqueue(defaultURL, SitemapSourceDefault)
+
+	seenURLs := make(map[string]bool)
+	for len(queue) > 0 && len(report.Files) < maxSitemapFiles && ctx.Err() == nil {
+		next := queue[0]
+		queue = queue[1:]
+
+		file, doc, status := a.fetchSitemap(ctx, next.url)
+		file.Source = next.source
+		if next.source == SitemapSourceDefault && status == http.StatusNotFound {
+			slog.Debug("No sitemap at the default location", "url", next.url)
+			continue // not having /sitemap.xml is fine unless something pointed to it
+		}
+		report.Files = append(report.Files, file)
+		if doc == nil {
+			continue
+		}
+
+		for _, s := range doc.Sitemaps {
+			if loc := strings.TrimSpace(s.Loc); loc != "" {
+				enqueue(loc, SitemapSourceIndex)
+			}
+		}
+		for _, u := range doc.URLs {
+			loc := strings.TrimSpace(u.Loc)
+			if loc == "" || seenURLs[loc] {
+				continue
+			}
+			seenURLs[loc] = true
+			report.URLsTotal++
+			if len(report.URLs) < maxSitemapURLs {
+				report.URLs = append(report.URLs, loc)
+			}
+		}
+	}
+
+	for _, u := range report.URLs {
+		if reason := nonCanonicalReason(site, u, a.linkPolicy); reason != "" {
+			report.NonCanonical = append(report.NonCanonical, SitemapURLIssue{URL: u, Reason: reason})
+		}
+	}
+
+	toCheck := report.URLs
+	if len(toCheck) > maxSitemapCheck {
+		toCheck = toCheck[:maxSitemapCheck]
+	}
+	report.Truncated = report.URLsTotal > len(toCheck)
+	links := make([]PageLink, len(toCheck))
+	for i, u := range toCheck {
+		links[i] = PageLink{URL: u, Tag: "sitemap", Internal: true}
+	}
+	results := a.checkLinkResults(ctx, links, nil)
+	report.URLsChecked = len(results)
+	for _, res := range results {
+		switch {
+		case res.SkippedByRobots:
+			report.Blocked = append(report.Blocked, res)
+		case !res.Accessible():
+			report.Broken = append(report.Broken, res)
+		case len(res.RedirectChain) > 0:
+			report.Redirecting = append(report.Redirecting, res)
+		}
+	}
+	sort.SliceStable(report.Broken, func(i, j int) bool { return report.Broken[i].URL < report.Broken[j].URL })
+	sort.SliceStable(report.Redirecting, func(i, j int) bool { return report.Redirecting[i].URL < report.Redirecting[j].URL })
+	sort.SliceStable(report.Blocked, func(i, j int) bool { return report.Blocked[i].URL < report.Blocked[j].URL })
+
+	slog.Info("Sitemap check complete", "site", site.String(), "files", len(report.Files), "urls", report.URLsTotal,
+		"broken", len(report.Broken), "redirecting", len(report.Redirecting), "non_canonical", len(report.NonCanonical))
+	return report, nil
+}
+
+// fetchSitemap downloads and parses one sitemap file. It returns the parsed document, or nil
+// with file.Error set, and the HTTP status code (0 if no response was received).
+func (a *Analyzer) fetchSitemap(ctx context.Context, sitemapURL string) (file SitemapFile, doc *xmlSitemap, status int) {
+	file = SitemapFile{URL: sitemapURL}
+
+	ctx, cancel := withTimeout(ctx, a.fetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, sitemapURL, nil)
+	if err != nil {
+		file.Error = fmt.Sprintf("invalid sitemap URL: %v", err)
+		return file, nil, 0
+	}
+	req.Header.Set("User-Agent", a.userAgent)
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		file.Error = fmt.Sprintf("fetch failed: %v", err)
+		return file, nil, 0
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		file.Error = fmt.Sprintf("HTTP status %d", resp.StatusCode)
+		return file, nil, resp.StatusCode
+	}
+
+	// Compressed sitemaps are usually served as application/gzip rather than with a
+	// Content-Encoding the transport would undo, so sniff the gzip magic number instead
+	body := bufio.NewReader(resp.Body)
+	var r io.Reader = body
+	if magic, _ := body.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(body)
+		if err != nil {
+			file.Error = fmt.Sprintf("invalid gzip data: %v", err)
+			return file, nil, resp.StatusCode
+		}
+		defer zr.Close()
+		file.Gzipped = true
+		r = zr
+	}
+
+	doc, kind, err := parseSitemap(io.LimitReader(r, maxSitemapBytes))
+	if err != nil {
+		file.Error = err.Error()
+		return file, nil, resp.StatusCode
+	}
+	file.Type = kind
+	file.URLCount = len(doc.URLs) + len(doc.Sitemaps)
+	return file, doc, resp.StatusCode
+}
+
+// parseSitemap decodes a <urlset> or <sitemapindex> document and reports which one it was
+func parseSitemap(r io.Reader) (*xmlSitemap, string, error) {
+	dec := xml.NewDecoder(r)
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			if err == io.EOF {
+				return nil, "", fmt.Errorf("no sitemap root element found")
+			}
+			return nil, "", fmt.Errorf("invalid XML: %v", err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if start.Name.Local != SitemapTypeURLSet && start.Name.Local != SitemapTypeIndex {
+			return nil, "", fmt.Errorf("unexpected root element <%s>", start.Name.Local)
+		}
+		var doc xmlSitemap
+		if err := dec.DecodeElement(&doc, &start); err != nil {
+			return nil, "", fmt.Errorf("invalid XML: %v", err)
+		}
+		if start.Name.Local == SitemapTypeURLSet {
+			doc.Sitemaps = nil
+		} else {
+			doc.URLs = nil
+		}
+		return &doc, start.Name.Local, nil
+	}
+}
+
+// nonCanonicalReason explains why a sitemap URL is not in the canonical form the protocol
+// asks for, or returns "" if it is. Sitemap URLs must be absolute and belong to the site
+// under the analyzer's link policy.
+func nonCanonicalReason(site *url.URL, raw string, policy LinkPolicy) string {
+	u, err := url.Parse(raw)
+	switch {
+	case err != nil || !u.IsAbs() || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https"):
+		return "not an absolute HTTP/HTTPS URL"
+	case !policy.Internal(site, u):
+		return "different scheme or host than the site"
+	case u.Fragment != "" || strings.HasSuffix(raw, "#"):
+		return "contains a fragment"
+	}
+	return ""
+}
@@ -0,0 +1,278 @@
+package analyzer
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParseSitemap(t *testing.T) {
+	testCases := []struct {
+		name     string
+		doc      string
+		wantType string
+		wantLocs int
+		wantErr  string
+	}{
+		{
+			name:     "URLSet",
+			doc:      `<?xml version="1.0" encoding="UTF-8"?><urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"><url><loc>https://example.com/</loc></url><url><loc> https://example.com/a </loc><lastmod>2024-01-01</lastmod></url></urlset>`,
+			wantType: SitemapTypeURLSet,
+			wantLocs: 2,
+		},
+		{
+			name:     "Index",
+			doc:      `<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"><sitemap><loc>https://example.com/s1.xml</loc></sitemap></sitemapindex>`,
+			wantType: SitemapTypeIndex,
+			wantLocs: 1,
+		},
+		{name: "WrongRoot", doc: `<rss><channel></channel></rss>`, wantErr: "unexpected root element <rss>"},
+		{name: "NotXML", doc: `<html><body>oops`, wantErr: "unexpected root element <html>"},
+		{name: "Truncated", doc: `<urlset><url><loc>https://example.com/`, wantErr: "invalid XML"},
+		{name: "Empty", doc: ``, wantErr: "no sitemap root element found"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, kind, err := parseSitemap(strings.NewReader(tc.doc))
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("Expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseSitemap failed unexpectedly: %v", err)
+			}
+			if kind != tc.wantType {
+				t.Errorf("Expected type %q, got %q", tc.wantType, kind)
+			}
+			if got := len(doc.URLs) + len(doc.Sitemaps); got != tc.wantLocs {
+				t.Errorf("Expected %d entries, got %d", tc.wantLocs, got)
+			}
+		})
+	}
+}
+
+func TestCheckSitemaps(t *testing.T) {
+	var base string // server URL, known once the server has started
+	server := newMockServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			fmt.Fprintf(w, "User-agent: *\nDisallow: /private\n\nSitemap: %s/sitemap_index.xml\n", base)
+		case "/sitemap_index.xml":
+			fmt.Fprintf(w, `<sitemapindex><sitemap><loc>%[1]s/pages.xml</loc></sitemap><sitemap><loc>%[1]s/more.xml.gz</loc></sitemap><sitemap><loc>%[1]s/gone.xml</loc></sitemap></sitemapindex>`, base)
+		case "/pages.xml":
+			fmt.Fprintf(w, `<urlset>
+				<url><loc>%[1]s/</loc></url>
+				<url><loc>%[1]s/missing</loc></url>
+				<url><loc>%[1]s/old</loc></url>
+				<url><loc>%[1]s/private/page</loc></url>
+				<url><loc>%[1]s/#section</loc></url>
+				<url><loc>https://elsewhere.invalid/page</loc></url>
+				<url><loc>/relative</loc></url>
+			</urlset>`, base)
+		case "/more.xml.gz":
+			w.Header().Set("Content-Type", "application/gzip")
+			zw := gzip.NewWriter(w)
+			fmt.Fprintf(zw, `<urlset><url><loc>%[1]s/compressed</loc></url><url><loc>%[1]s/</loc></url></urlset>`, base)
+			zw.Close()
+		case "/old":
+			http.Redirect(w, r, "/", http.StatusMovedPermanently)
+		case "/", "/compressed", "/private/page":
+			fmt.Fprint(w, "ok")
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	defer server.Close()
+	base = server.URL
+
+	report, err := New().CheckSitemaps(context.Background(), server.URL+"/some/page")
+	if err != nil {
+		t.Fatalf("CheckSitemaps failed unexpectedly: %v", err)
+	}
+
+	files := make(map[string]SitemapFile)
+	for _, f := range report.Files {
+		files[strings.TrimPrefix(f.URL, server.URL)] = f
+	}
+	if len(files) != 4 {
+		t.Errorf("Expected 4 sitemap files (the missing default /sitemap.xml is not one), got %+v", report.Files)
+	}
+	if f := files["/sitemap_index.xml"]; f.Source != SitemapSourceRobots || f.Type != SitemapTypeIndex || f.URLCount != 3 {
+		t.Errorf("Unexpected index file %+v", f)
+	}
+	if f := files["/more.xml.gz"]; f.Source != SitemapSourceIndex || !f.Gzipped || f.URLCount != 2 {
+		t.Errorf("Unexpected gzipped file %+v", f)
+	}
+	if f := files["/gone.xml"]; f.Error != "HTTP status 404" {
+		t.Errorf("Expected /gone.xml to report a 404, got %+v", f)
+	}
+	if !report.Found() {
+		t.Errorf("Expected Found() to be true")
+	}
+
+	// "/" is listed twice but counted once
+	if report.URLsTotal != 8 || report.URLsChecked != 8 || report.Truncated {
+		t.Errorf("Expected 8 URLs, all checked, got %d/%d (truncated=%v)", report.URLsTotal, report.URLsChecked, report.Truncated)
+	}
+
+	urls := func(results []LinkCheckResult) string {
+		var paths []string
+		for _, r := range results {
+			paths = append(paths, strings.TrimPrefix(r.URL, server.URL))
+		}
+		return strings.Join(paths, " ")
+	}
+	// /relative and the other host fail too, which is fine: they are unusable as listed
+	if got := urls(report.Broken); !strings.Contains(got, "/missing") {
+		t.Errorf("Expected /missing among broken URLs, got %q", got)
+	}
+	if got := urls(report.Redirecting); got != "/old" {
+		t.Errorf("Expected /old to be redirecting, got %q", got)
+	}
+	if got := urls(report.Blocked); got != "/private/page" {
+		t.Errorf("Expected /private/page to be blocked, got %q", got)
+	}
+
+	reasons := make(map[string]string)
+	for _, issue := range report.NonCanonical {
+		reasons[strings.TrimPrefix(issue.URL, server.URL)] = issue.Reason
+	}
+	expected := map[string]string{
+		"/#section":                      "contains a fragment",
+		"https://elsewhere.invalid/page": "different scheme or host than the site",
+		"/relative":                      "not an absolute HTTP/HTTPS URL",
+	}
+	if fmt.Sprint(reasons) != fmt.Sprint(expected) {
+		t.Errorf("Expected non-canonical URLs %v, got %v", expected, reasons)
+	}
+}
+
+func TestCheckSitemaps_NoneFound(t *testing.T) {
+	server := newMockServer(func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+	defer server.Close()
+
+	report, err := New().CheckSitemaps(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("CheckSitemaps failed unexpectedly: %v", err)
+	}
+	if len(report.Files) != 0 || report.Found() || report.URLsTotal != 0 {
+		t.Errorf("Expected an empty report, got %+v", report)
+	}
+}
+
+func TestAnalyze_SitemapCheck(t *testing.T) {
+	server := newMockServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sitemap.xml":
+			fmt.Fprint(w, `<urlset><url><loc>http://`+r.Host+`/</loc></url></urlset>`)
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<html><head><title>Home</title></head><body></body></html>`)
+		}
+	})
+	defer server.Close()
+
+	result, err := New().Analyze(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("Analyze failed unexpectedly: %v", err)
+	}
+	if result.Sitemap != nil {
+		t.Errorf("Expected no sitemap report by default, got %+v", result.Sitemap)
+	}
+
+	result, err = New(WithSitemapCheck(true)).Analyze(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("Analyze failed unexpectedly: %v", err)
+	}
+	if result.Sitemap == nil || len(result.Sitemap.Files) != 1 || result.Sitemap.Files[0].Source != SitemapSourceDefault || result.Sitemap.URLsTotal != 1 {
+		t.Errorf("Expected the default sitemap with 1 URL, got %+v", result.Sitemap)
+	}
+}
+
+func TestAnalyze_SitemapCheckAfterRedirect(t *testing.T) {
+	target := newMockServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sitemap.xml":
+			fmt.Fprint(w, `<urlset><url><loc>http://`+r.Host+`/</loc></url></urlset>`)
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<html><head><title>Home</title></head><body></body></html>`)
+		}
+	})
+	defer target.Close()
+	origin := newMockServer(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			http.Redirect(w, r, target.URL+"/", http.StatusMovedPermanently)
+			return
+		}
+		http.NotFound(w, r)
+	})
+	defer origin.Close()
+
+	result, err := New(WithSitemapCheck(true), WithLinkCheck(false)).Analyze(context.Background(), origin.URL)
+	if err != nil {
+		t.Fatalf("Analyze failed unexpectedly: %v", err)
+	}
+	if result.Sitemap == nil || len(result.Sitemap.Files) != 1 || result.Sitemap.Files[0].URL != target.URL+"/sitemap.xml" || len(result.Sitemap.NonCanonical) != 0 {
+		t.Errorf("Expected the sitemap of the redirect target with no off-site URLs, got %+v", result.Sitemap)
+	}
+}
+
+func TestNonCanonicalReason_LinkPolicy(t *testing.T) {
+	site, _ := url.Parse("https://example.com/")
+	aliased := LinkPolicy{Scope: LinkScopeHost, MatchScheme: true, Aliases: []string{"www.example.com"}}
+	domain := LinkPolicy{Scope: LinkScopeDomain, MatchScheme: true}
+
+	testCases := []struct {
+		name   string
+		policy LinkPolicy
+		raw    string
+		want   bool // off-site
+	}{
+		{"Default policy, other host", DefaultLinkPolicy, "https://www.example.com/a", true},
+		{"Alias", aliased, "https://www.example.com/a", false},
+		{"Same registrable domain", domain, "https://blog.example.com/a", false},
+		{"Other domain", domain, "https://example.org/a", true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := nonCanonicalReason(site, tc.raw, tc.policy) != ""; got != tc.want {
+				t.Errorf("Expected %s to be off-site: %v under %+v", tc.raw, tc.want, tc.policy)
+			}
+		})
+	}
+}
+
+func TestCheckSitemaps_RobotsIgnore(t *testing.T) {
+	var robotsFetched bool
+	server := newMockServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			robotsFetched = true
+			fmt.Fprint(w, "Sitemap: http://"+r.Host+"/other.xml\n")
+		case "/sitemap.xml":
+			fmt.Fprint(w, `<urlset><url><loc>http://`+r.Host+`/</loc></url></urlset>`)
+		}
+	})
+	defer server.Close()
+
+	report, err := New(WithRobotsPolicy(RobotsIgnore)).CheckSitemaps(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("CheckSitemaps failed unexpectedly: %v", err)
+	}
+	if robotsFetched {
+		t.Error("Expected robots.txt not to be fetched under RobotsIgnore")
+	}
+	if len(report.Files) != 1 || report.Files[0].Source != SitemapSourceDefault {
+		t.Errorf("Expected only the default sitemap, got %+v", report.Files)
+	}
+}
@@ -0,0 +1,218 @@
+package analyzer
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Card types a SocialIssue can refer to
+const (
+	SocialCardOpenGraph = "open_graph"
+	SocialCardTwitter   = "twitter"
+)
+
+// Twitter card types, as given by the twitter:card property
+var twitterCardTypes = map[string]bool{"summary": true, "summary_large_image": true, "app": true, "player": true}
+
+// SocialMetadata holds the Open Graph, Twitter Card and article properties of a page and
+// what link previews on social networks and chat apps will make of them
+type SocialMetadata struct {
+	// Property values keyed by full property name ("og:title"). Properties such as
+	// og:image and article:tag may be repeated, so every value is kept in document order.
+	OpenGraph map[string][]string `json:"open_graph"`
+	Twitter   map[string][]string `json:"twitter"`
+	Article   map[string][]string `json:"article"`
+
+	Preview     SocialPreview    `json:"preview"`
+	ImageStatus *LinkCheckResult `json:"image_status,omitempty"` // check of the preview image, set when links are checked
+	Issues      []SocialIssue    `json:"issues"`
+}
+
+// SocialPreview is what a link preview card shows, after the fallbacks platforms apply
+// (Twitter falls back to Open Graph, which falls back to the title and meta description)
+type SocialPreview struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Image       string `json:"image,omitempty"`          // absolute URL
+	ImageFrom   string `json:"image_property,omitempty"` // property Image was taken from, e.g. "og:image" or "twitter:image"
+	SiteName    string `json:"site_name"`                // og:site_name, or the host of the page
+	URL         string `json:"url"`
+	Card        string `json:"card"` // Twitter card type, "summary" if not declared
+}
+
+// SocialIssue is a missing or invalid property that degrades link previews
+type SocialIssue struct {
+	Card     string `json:"card"` // SocialCardOpenGraph or SocialCardTwitter
+	Property string `json:"property"`
+	Message  string `json:"message"`
+}
+
+// firstProp returns the first value of a property, or ""
+func firstProp(props map[string][]string, name string) string {
+	if values := props[name]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// collectSocial records the og:*, twitter:* and article:* property of a <meta> element.
+// Open Graph uses the property attribute and Twitter the name attribute, but sites mix
+// them up and both are read by the platforms, so either is accepted.
+func collectSocial(s *SocialMetadata, n *html.Node) {
+	name := strings.TrimSpace(attrValue(n, "property"))
+	if name == "" {
+		name = strings.TrimSpace(attrValue(n, "name"))
+	}
+	name = strings.ToLower(name)
+	content := strings.TrimSpace(attrValue(n, "content"))
+	if content == "" {
+		return
+	}
+
+	var props *map[string][]string
+	switch {
+	case strings.HasPrefix(name, "og:"):
+		props = &s.OpenGraph
+	case strings.HasPrefix(name, "twitter:"):
+		props = &s.Twitter
+	case strings.HasPrefix(name, "article:"):
+		props = &s.Article
+	default:
+		return
+	}
+	if *props == nil {
+		*props = make(map[string][]string)
+	}
+	(*props)[name] = append((*props)[name], content)
+}
+
+// finishSocial fills in the preview and validates the collected properties once the whole
+// document has been read. pageURL identifies the page and base resolves relative URLs.
+func finishSocial(s *SocialMetadata, title, description string, pageURL, base *url.URL) {
+	for _, props := range []*map[string][]string{&s.OpenGraph, &s.Twitter, &s.Article} {
+		if *props == nil {
+			*props = map[string][]string{}
+		}
+	}
+	s.Issues = []SocialIssue{}
+	og, tw := s.OpenGraph, s.Twitter
+
+	// Open Graph basic metadata, which every object must have (https://ogp.me/#metadata)
+	for _, prop := range []string{"og:title", "og:type", "og:image", "og:url"} {
+		if firstProp(og, prop) == "" {
+			s.Issues = append(s.Issues, SocialIssue{Card: SocialCardOpenGraph, Property: prop, Message: "required property is missing"})
+		}
+	}
+	for _, prop := range []string{"og:url", "og:image"} {
+		if v := firstProp(og, prop); v != "" && !absoluteHTTPURL(v) {
+			s.Issues = append(s.Issues, SocialIssue{Card: SocialCardOpenGraph, Property: prop, Message: "should be an absolute HTTP/HTTPS URL"})
+		}
+	}
+	if len(s.Article) > 0 && firstProp(og, "og:type") != "article" {
+		s.Issues = append(s.Issues, SocialIssue{Card: SocialCardOpenGraph, Property: "og:type", Message: `article:* properties are only read when og:type is "article"`})
+	}
+
+	card := firstProp(tw, "twitter:card")
+	switch {
+	case card == "":
+		s.Issues = append(s.Issues, SocialIssue{Card: SocialCardTwitter, Property: "twitter:card", Message: "required property is missing"})
+		card = "summary"
+	case !twitterCardTypes[card]:
+		s.Issues = append(s.Issues, SocialIssue{Card: SocialCardTwitter, Property: "twitter:card", Message: "unknown card type " + card})
+		card = "summary"
+	}
+
+	twTitle := firstNonEmpty(firstProp(tw, "twitter:title"), firstProp(og, "og:title"))
+	twImage := firstNonEmpty(firstProp(tw, "twitter:image"), firstProp(tw, "twitter:image:src"), firstProp(og, "og:image"))
+	if twTitle == "" {
+		s.Issues = append(s.Issues, SocialIssue{Card: SocialCardTwitter, Property: "twitter:title", Message: "required property is missing (no og:title to fall back to)"})
+	}
+	var required []string
+	switch card {
+	case "summary_large_image":
+		if twImage == "" {
+			s.Issues = append(s.Issues, SocialIssue{Card: SocialCardTwitter, Property: "twitter:image", Message: "summary_large_image card has no image (no og:image to fall back to)"})
+		}
+	case "player":
+		required = []string{"twitter:site", "twitter:player", "twitter:player:width", "twitter:player:height"}
+		if twImage == "" {
+			s.Issues = append(s.Issues, SocialIssue{Card: SocialCardTwitter, Property: "twitter:image", Message: "required property is missing (no og:image to fall back to)"})
+		}
+	case "app":
+		required = []string{"twitter:site"}
+		if firstProp(tw, "twitter:app:id:iphone") == "" && firstProp(tw, "twitter:app:id:ipad") == "" && firstProp(tw, "twitter:app:id:googleplay") == "" {
+			s.Issues = append(s.Issues, SocialIssue{Card: SocialCardTwitter, Property: "twitter:app:id:*", Message: "app card needs at least one app ID"})
+		}
+	}
+	for _, prop := range required {
+		if firstProp(tw, prop) == "" {
+			s.Issues = append(s.Issues, SocialIssue{Card: SocialCardTwitter, Property: prop, Message: "required property for the " + card + " card is missing"})
+		}
+	}
+
+	s.Preview = SocialPreview{
+		Title:       firstNonEmpty(firstProp(og, "og:title"), firstProp(tw, "twitter:title"), title),
+		Description: firstNonEmpty(firstProp(og, "og:description"), firstProp(tw, "twitter:description"), description),
+		SiteName:    firstNonEmpty(firstProp(og, "og:site_name"), pageURL.Hostname()),
+		URL:         pageURL.String(),
+		Card:        card,
+	}
+	for _, prop := range []string{"og:image", "twitter:image", "twitter:image:src"} {
+		props := og
+		if strings.HasPrefix(prop, "twitter:") {
+			props = tw
+		}
+		if image := firstProp(props, prop); image != "" {
+			if u, err := base.Parse(image); err == nil {
+				s.Preview.Image, s.Preview.ImageFrom = u.String(), prop
+			}
+			break
+		}
+	}
+	if ogURL := firstProp(og, "og:url"); ogURL != "" {
+		if u, err := base.Parse(ogURL); err == nil {
+			s.Preview.URL = u.String()
+		}
+	}
+}
+
+// checkSocialImage checks that the preview image can be fetched and is served as an image
+func (a *Analyzer) checkSocialImage(ctx context.Context, s *SocialMetadata) {
+	checked := a.checkLinkResults(ctx, []PageLink{{URL: s.Preview.Image, Tag: "meta"}}, nil)
+	if len(checked) == 0 {
+		return // cancelled
+	}
+	res := checked[0]
+	s.ImageStatus = &res
+	// Report the issue against the property the image actually came from
+	card, prop := SocialCardOpenGraph, s.Preview.ImageFrom
+	if strings.HasPrefix(prop, "twitter:") {
+		card = SocialCardTwitter
+	}
+	switch {
+	case res.SkippedByRobots:
+	case !res.Accessible():
+		s.Issues = append(s.Issues, SocialIssue{Card: card, Property: prop, Message: "preview image is not accessible"})
+	case !res.IsImage():
+		s.Issues = append(s.Issues, SocialIssue{Card: card, Property: prop, Message: "preview image is not served as an image (Content-Type: " + res.ContentType + ")"})
+	}
+}
+
+// absoluteHTTPURL reports whether raw is an absolute HTTP/HTTPS URL with a host
+func absoluteHTTPURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// firstNonEmpty returns the first of values that is not empty
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
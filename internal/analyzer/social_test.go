@@ -0,0 +1,105 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAnalyze_Social(t *testing.T) {
+	var pageHTML string
+	server := newMockServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, pageHTML)
+		case "/card.png":
+			w.Header().Set("Content-Type", "image/png")
+		case "/card.html":
+			w.Header().Set("Content-Type", "text/html")
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	defer server.Close()
+
+	testCases := []struct {
+		name        string
+		head        string
+		wantPreview SocialPreview
+		wantIssues  []string // "card property"
+	}{
+		{
+			name: "Complete",
+			head: `<meta property="og:title" content="OG Title">
+				<meta property="og:type" content="article">
+				<meta property="og:image" content="` + server.URL + `/card.png">
+				<meta property="og:url" content="` + server.URL + `/">
+				<meta property="og:site_name" content="Example">
+				<meta property="article:tag" content="go"><meta property="article:tag" content="seo">
+				<meta name="twitter:card" content="summary_large_image">`,
+			wantPreview: SocialPreview{Title: "OG Title", Description: "Meta description", Image: server.URL + "/card.png", ImageFrom: "og:image", SiteName: "Example", URL: server.URL + "/", Card: "summary_large_image"},
+		},
+		{
+			name:        "Missing",
+			head:        `<meta property="article:author" content="Someone">`,
+			wantPreview: SocialPreview{Title: "Page Title", Description: "Meta description", SiteName: "127.0.0.1", URL: server.URL + "/", Card: "summary"},
+			wantIssues:  []string{"open_graph og:title", "open_graph og:type", "open_graph og:image", "open_graph og:url", "open_graph og:type", "twitter twitter:card", "twitter twitter:title"},
+		},
+		{
+			name: "InvalidCardAndImages",
+			head: `<meta property="og:title" content="T"><meta property="og:type" content="website">
+				<meta property="og:image" content="/card.html"><meta property="og:url" content="` + server.URL + `/">
+				<meta name="twitter:card" content="player"><meta name="twitter:title" content="Tw">`,
+			wantPreview: SocialPreview{Title: "T", Description: "Meta description", Image: server.URL + "/card.html", ImageFrom: "og:image", SiteName: "127.0.0.1", URL: server.URL + "/", Card: "player"},
+			wantIssues: []string{"open_graph og:image", "twitter twitter:site", "twitter twitter:player", "twitter twitter:player:width",
+				"twitter twitter:player:height", "open_graph og:image"},
+		},
+		{
+			name: "TwitterImageOnly",
+			head: `<meta property="og:title" content="T"><meta property="og:type" content="website"><meta property="og:url" content="` + server.URL + `/">
+				<meta name="twitter:card" content="summary"><meta name="twitter:image" content="/missing.png">`,
+			wantPreview: SocialPreview{Title: "T", Description: "Meta description", Image: server.URL + "/missing.png", ImageFrom: "twitter:image", SiteName: "127.0.0.1", URL: server.URL + "/", Card: "summary"},
+			wantIssues:  []string{"open_graph og:image", "twitter twitter:image"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pageHTML = `<html><head><title>Page Title</title><meta name="description" content="Meta description">` + tc.head + `</head><body></body></html>`
+			result, err := New().Analyze(context.Background(), server.URL+"/")
+			if err != nil {
+				t.Fatalf("Analyze failed unexpectedly: %v", err)
+			}
+			if result.Social.Preview != tc.wantPreview {
+				t.Errorf("Expected preview %+v, got %+v", tc.wantPreview, result.Social.Preview)
+			}
+			var issues []string
+			for _, issue := range result.Social.Issues {
+				issues = append(issues, issue.Card+" "+issue.Property)
+			}
+			if strings.Join(issues, ",") != strings.Join(tc.wantIssues, ",") {
+				t.Errorf("Expected issues %v, got %+v", tc.wantIssues, result.Social.Issues)
+			}
+		})
+	}
+
+	t.Run("RepeatedProperties", func(t *testing.T) {
+		pageHTML = `<html><head><meta property="og:image" content="/a.png"><meta property="og:image" content="/b.png"><meta property="article:tag" content="x"><meta name="twitter:site" content="@example"></head></html>`
+		result, err := New(WithLinkCheck(false)).Analyze(context.Background(), server.URL+"/")
+		if err != nil {
+			t.Fatalf("Analyze failed unexpectedly: %v", err)
+		}
+		if got := result.Social.OpenGraph["og:image"]; len(got) != 2 || got[1] != "/b.png" {
+			t.Errorf("Expected both og:image values, got %v", got)
+		}
+		if result.Social.Twitter["twitter:site"][0] != "@example" || result.Social.Article["article:tag"][0] != "x" {
+			t.Errorf("Expected twitter and article properties, got %+v", result.Social)
+		}
+		if result.Social.ImageStatus != nil {
+			t.Errorf("Expected no image check with link checks disabled, got %+v", result.Social.ImageStatus)
+		}
+	})
+}
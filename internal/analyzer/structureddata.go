@@ -0,0 +1,456 @@
+package analyzer
+
+import (
+	_ "embed" // for the bundled schema.org rules
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// StructuredDataFormat names the syntax a structured data item was written in
+type StructuredDataFormat string
+
+const (
+	FormatJSONLD    StructuredDataFormat = "json-ld"
+	FormatMicrodata StructuredDataFormat = "microdata"
+	FormatRDFa      StructuredDataFormat = "rdfa"
+)
+
+// Severities of a StructuredDataIssue, an AccessibilityFinding or a SecurityFinding
+const (
+	SeverityError   = "error"   // e.g. a required property is missing, or content is unusable with assistive technology
+	SeverityWarning = "warning" // e.g. a recommended property is missing, or a best practice is not followed
+)
+
+// StructuredData holds every structured data item found on a page
+type StructuredData struct {
+	Items  []StructuredDataItem  `json:"items"`  // top-level items, in document order
+	Errors []StructuredDataError `json:"errors"` // JSON-LD blocks that could not be parsed
+	Issues []StructuredDataIssue `json:"issues"` // schema.org validation findings
+}
+
+// StructuredDataItem is a typed entity, whatever syntax it was written in
+type StructuredDataItem struct {
+	Format     StructuredDataFormat         `json:"format"`
+	Types      []string                     `json:"types"` // schema.org types without the vocabulary prefix, e.g. "Product"
+	ID         string                       `json:"id,omitempty"`
+	Properties map[string][]StructuredValue `json:"properties"`
+}
+
+// StructuredValue is one property value: either text or a nested item
+type StructuredValue struct {
+	Text string              `json:"text,omitempty"`
+	Item *StructuredDataItem `json:"item,omitempty"`
+}
+
+// StructuredDataError describes a JSON-LD block that is not valid JSON.
+// Block counts the page's JSON-LD scripts from 1; Line and Column are 1-based within the block.
+type StructuredDataError struct {
+	Block   int    `json:"block"`
+	Offset  int64  `json:"offset"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Message string `json:"message"`
+}
+
+// StructuredDataIssue is a property an item lacks according to the bundled schema.org rules
+type StructuredDataIssue struct {
+	Format   StructuredDataFormat `json:"format"`
+	Path     string               `json:"path"` // type of the top-level item and the properties leading to the checked one, e.g. "Product > offers"
+	Type     string               `json:"type"` // type whose rule was applied
+	Property string               `json:"property"`
+	Severity string               `json:"severity"`
+	Message  string               `json:"message"`
+}
+
+// schemaRule lists the properties a schema.org type must or should have
+type schemaRule struct {
+	Extends     string     `json:"extends"` // type whose rule also applies
+	Required    []string   `json:"required"`
+	OneOf       [][]string `json:"one_of"` // at least one property of each group is required
+	Recommended []string   `json:"recommended"`
+}
+
+//go:embed schema_rules.json
+var schemaRulesJSON []byte
+
+// schemaRules holds the bundled rules, keyed by type
+var schemaRules = mustParseSchemaRules(schemaRulesJSON)
+
+func mustParseSchemaRules(data []byte) map[string]schemaRule {
+	var rules map[string]schemaRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		panic(fmt.Sprintf("invalid bundled schema rules: %v", err))
+	}
+	return rules
+}
+
+// schemaTypeName strips the schema.org vocabulary from a type or property name
+func schemaTypeName(s string) string {
+	for _, prefix := range []string{"https://schema.org/", "http://schema.org/", "schema:"} {
+		if strings.HasPrefix(s, prefix) {
+			return strings.TrimPrefix(s, prefix)
+		}
+	}
+	return s
+}
+
+// extractStructuredData collects the JSON-LD, Microdata and RDFa items of doc and validates
+// them. base resolves the relative URLs of Microdata and RDFa values.
+func extractStructuredData(doc *html.Node, base *url.URL) StructuredData {
+	data := StructuredData{Items: []StructuredDataItem{}, Errors: []StructuredDataError{}, Issues: []StructuredDataIssue{}}
+	jsonLDBlocks := 0
+
+	// microItem and rdfaItem are the innermost enclosing items of each syntax, if any
+	var walk func(n *html.Node, microItem, rdfaItem *StructuredDataItem)
+	walk = func(n *html.Node, microItem, rdfaItem *StructuredDataItem) {
+		if n.Type == html.ElementNode {
+			if n.DataAtom == atom.Script && strings.EqualFold(strings.TrimSpace(attrValue(n, "type")), "application/ld+json") {
+				jsonLDBlocks++
+				items, err := parseJSONLD(nodeRawText(n), jsonLDBlocks)
+				if err != nil {
+					slog.Debug("Malformed JSON-LD block", "block", jsonLDBlocks, "error", err.Message)
+					data.Errors = append(data.Errors, *err)
+				}
+				data.Items = append(data.Items, items...)
+				return
+			}
+
+			microItem = microdataElement(n, microItem, base, &data)
+			rdfaItem = rdfaElement(n, rdfaItem, base, &data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c, microItem, rdfaItem)
+		}
+	}
+	walk(doc, nil, nil)
+
+	for i := range data.Items {
+		validateItem(&data.Items[i], nil, &data.Issues)
+	}
+	return data
+}
+
+// microdataElement handles the itemscope/itemprop attributes of n and returns the item its
+// descendants belong to
+func microdataElement(n *html.Node, parent *StructuredDataItem, base *url.URL, data *StructuredData) *StructuredDataItem {
+	_, hasScope := attrLookup(n, "itemscope")
+	props := strings.Fields(attrValue(n, "itemprop"))
+
+	var item *StructuredDataItem
+	if hasScope {
+		item = &StructuredDataItem{Format: FormatMicrodata, ID: strings.TrimSpace(attrValue(n, "itemid")), Properties: map[string][]StructuredValue{}}
+		for _, t := range strings.Fields(attrValue(n, "itemtype")) {
+			item.Types = append(item.Types, schemaTypeName(t))
+		}
+	}
+
+	switch {
+	case parent != nil && len(props) > 0:
+		value := StructuredValue{Item: item}
+		if item == nil {
+			value.Text = microdataValue(n, base)
+		}
+		for _, p := range props {
+			p = schemaTypeName(p)
+			parent.Properties[p] = append(parent.Properties[p], value)
+		}
+	case item != nil:
+		// A top-level item. The copy shares its Properties map with item, so the properties
+		// found further down the walk end up in the copy too.
+		data.Items = append(data.Items, *item)
+	}
+
+	if item != nil {
+		return item
+	}
+	return parent
+}
+
+// microdataValue returns the value of an itemprop element without itemscope, as defined by
+// the HTML Microdata specification
+func microdataValue(n *html.Node, base *url.URL) string {
+	urlAttr := ""
+	switch n.DataAtom {
+	case atom.Meta:
+		return strings.TrimSpace(attrValue(n, "content"))
+	case atom.Audio, atom.Embed, atom.Iframe, atom.Img, atom.Source, atom.Track, atom.Video:
+		urlAttr = "src"
+	case atom.A, atom.Area, atom.Link:
+		urlAttr = "href"
+	case atom.Object:
+		urlAttr = "data"
+	case atom.Data, atom.Meter:
+		return strings.TrimSpace(attrValue(n, "value"))
+	case atom.Time:
+		if v, ok := attrLookup(n, "datetime"); ok {
+			return strings.TrimSpace(v)
+		}
+	}
+	if urlAttr != "" {
+		return resolveValue(attrValue(n, urlAttr), base)
+	}
+	return nodeText(n)
+}
+
+// rdfaElement handles the RDFa Lite attributes (typeof, property, resource) of n and returns
+// the item its descendants belong to. Names in the schema.org vocabulary lose their prefix.
+func rdfaElement(n *html.Node, parent *StructuredDataItem, base *url.URL, data *StructuredData) *StructuredDataItem {
+	typeOf, hasTypeOf := attrLookup(n, "typeof")
+	props := strings.Fields(attrValue(n, "property"))
+	if parent == nil {
+		// Outside a typed element, property attributes are Open Graph and similar metadata
+		props = nil
+	}
+
+	var item *StructuredDataItem
+	if hasTypeOf {
+		item = &StructuredDataItem{Format: FormatRDFa, Properties: map[string][]StructuredValue{}}
+		if id := firstNonEmpty(attrValue(n, "resource"), attrValue(n, "about")); id != "" {
+			item.ID = resolveValue(id, base)
+		}
+		for _, t := range strings.Fields(typeOf) {
+			item.Types = append(item.Types, schemaTypeName(t))
+		}
+	}
+
+	switch {
+	case len(props) > 0:
+		value := StructuredValue{Item: item}
+		if item == nil {
+			value.Text = rdfaValue(n, base)
+		}
+		for _, p := range props {
+			p = schemaTypeName(p)
+			parent.Properties[p] = append(parent.Properties[p], value)
+		}
+	case item != nil:
+		data.Items = append(data.Items, *item)
+	}
+
+	if item != nil {
+		return item
+	}
+	return parent
+}
+
+// rdfaValue returns the value of an RDFa property element without typeof
+func rdfaValue(n *html.Node, base *url.URL) string {
+	if v, ok := attrLookup(n, "content"); ok {
+		return strings.TrimSpace(v)
+	}
+	for _, key := range []string{"resource", "href", "src"} {
+		if v, ok := attrLookup(n, key); ok {
+			return resolveValue(v, base)
+		}
+	}
+	if v, ok := attrLookup(n, "datetime"); ok {
+		return strings.TrimSpace(v)
+	}
+	return nodeText(n)
+}
+
+// resolveValue resolves a URL-valued attribute against base, keeping it as-is if it does not parse
+func resolveValue(raw string, base *url.URL) string {
+	raw = strings.TrimSpace(raw)
+	if u, err := base.Parse(raw); err == nil {
+		return u.String()
+	}
+	return raw
+}
+
+// nodeRawText returns the unprocessed text of an element such as <script>
+func nodeRawText(n *html.Node) string {
+	var sb strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.TextNode {
+			sb.WriteString(c.Data)
+		}
+	}
+	return sb.String()
+}
+
+// parseJSONLD decodes a JSON-LD block into items. A block may hold a single object, an
+// array of objects or an object with a @graph. Syntax errors are reported with their position.
+func parseJSONLD(text string, block int) ([]StructuredDataItem, *StructuredDataError) {
+	dec := json.NewDecoder(strings.NewReader(text))
+	dec.UseNumber()
+
+	var v any
+	err := dec.Decode(&v)
+	if err == nil {
+		end := dec.InputOffset()
+		if rest := strings.TrimLeft(text[end:], " \t\r\n"); rest != "" {
+			return nil, jsonLDError(text, block, int64(len(text)-len(rest)), "unexpected data after the JSON value")
+		}
+	}
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &syntaxErr):
+		// Offset counts the bytes read, including the offending one
+		return nil, jsonLDError(text, block, syntaxErr.Offset-1, syntaxErr.Error())
+	case errors.Is(err, io.EOF) && strings.TrimSpace(text) == "":
+		return nil, jsonLDError(text, block, 0, "empty JSON-LD block")
+	case errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, io.EOF):
+		return nil, jsonLDError(text, block, int64(len(text)), "unexpected end of JSON input")
+	case err != nil:
+		return nil, jsonLDError(text, block, dec.InputOffset(), err.Error())
+	}
+
+	var objects []any
+	switch val := v.(type) {
+	case []any:
+		objects = val
+	case map[string]any:
+		if graph, ok := val["@graph"].([]any); ok {
+			objects = graph
+		} else {
+			objects = []any{val}
+		}
+	default:
+		return nil, jsonLDError(text, block, 0, "JSON-LD must be an object or an array of objects")
+	}
+
+	var items []StructuredDataItem
+	for _, o := range objects {
+		if obj, ok := o.(map[string]any); ok {
+			items = append(items, *jsonLDItem(obj))
+		}
+	}
+	return items, nil
+}
+
+// jsonLDError builds a StructuredDataError, turning the byte offset into a line and column
+func jsonLDError(text string, block int, offset int64, msg string) *StructuredDataError {
+	offset = min(max(offset, 0), int64(len(text)))
+	before := text[:offset]
+	line := strings.Count(before, "\n") + 1
+	column := len(before) - strings.LastIndex(before, "\n")
+	return &StructuredDataError{Block: block, Offset: offset, Line: line, Column: column, Message: msg}
+}
+
+// jsonLDItem converts a JSON-LD node object into an item
+func jsonLDItem(obj map[string]any) *StructuredDataItem {
+	item := &StructuredDataItem{Format: FormatJSONLD, Properties: map[string][]StructuredValue{}}
+	for key, val := range obj {
+		switch key {
+		case "@type":
+			for _, t := range jsonLDValues(val) {
+				if t.Text != "" {
+					item.Types = append(item.Types, schemaTypeName(t.Text))
+				}
+			}
+		case "@id":
+			item.ID = fmt.Sprint(val)
+		case "@context":
+		default:
+			name := schemaTypeName(key)
+			item.Properties[name] = append(item.Properties[name], jsonLDValues(val)...)
+		}
+	}
+	return item
+}
+
+// jsonLDValues converts a JSON-LD property value, which may be an array, into values
+func jsonLDValues(val any) []StructuredValue {
+	switch v := val.(type) {
+	case []any:
+		var values []StructuredValue
+		for _, elem := range v {
+			values = append(values, jsonLDValues(elem)...)
+		}
+		return values
+	case map[string]any:
+		if literal, ok := v["@value"]; ok {
+			return []StructuredValue{{Text: fmt.Sprint(literal)}}
+		}
+		return []StructuredValue{{Item: jsonLDItem(v)}}
+	case nil:
+		return nil
+	default:
+		return []StructuredValue{{Text: fmt.Sprint(v)}}
+	}
+}
+
+// validateItem applies the rules of each of item's types, then validates nested items.
+// path holds the types and properties leading to item.
+func validateItem(item *StructuredDataItem, path []string, issues *[]StructuredDataIssue) {
+	label := strings.Join(item.Types, ",")
+	if label == "" {
+		label = "(untyped)"
+	}
+	if len(path) == 0 {
+		path = []string{label}
+	}
+	pathStr := strings.Join(path, " > ")
+
+	for _, t := range item.Types {
+		for ruleType, seen := t, map[string]bool{}; ruleType != "" && !seen[ruleType]; {
+			seen[ruleType] = true
+			rule, ok := schemaRules[ruleType]
+			if !ok {
+				break
+			}
+			report := func(prop, severity, msg string) {
+				*issues = append(*issues, StructuredDataIssue{Format: item.Format, Path: pathStr, Type: t, Property: prop, Severity: severity, Message: msg})
+			}
+			for _, prop := range rule.Required {
+				if !item.has(prop) {
+					report(prop, SeverityError, "required property is missing")
+				}
+			}
+			for _, group := range rule.OneOf {
+				if !item.hasAny(group) {
+					report(strings.Join(group, "|"), SeverityError, "one of these properties is required")
+				}
+			}
+			for _, prop := range rule.Recommended {
+				if !item.has(prop) {
+					report(prop, SeverityWarning, "recommended property is missing")
+				}
+			}
+			ruleType = rule.Extends
+		}
+	}
+
+	names := make([]string, 0, len(item.Properties))
+	for name := range item.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, v := range item.Properties[name] {
+			if v.Item != nil {
+				validateItem(v.Item, append(path[:len(path):len(path)], name), issues)
+			}
+		}
+	}
+}
+
+// has reports whether the item has a non-empty value for the property
+func (item *StructuredDataItem) has(prop string) bool {
+	for _, v := range item.Properties[prop] {
+		if v.Text != "" || v.Item != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// hasAny reports whether the item has a value for at least one of the properties
+func (item *StructuredDataItem) hasAny(props []string) bool {
+	for _, p := range props {
+		if item.has(p) {
+			return true
+		}
+	}
+	return false
+}
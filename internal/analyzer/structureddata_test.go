@@ -0,0 +1,113 @@
+package analyzer
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestParseJSONLD_Errors(t *testing.T) {
+	testCases := []struct {
+		name       string
+		text       string
+		wantLine   int
+		wantColumn int
+		wantMsg    string
+	}{
+		{"SyntaxError", "{\n  \"@type\": \"Article\",\n  \"headline\": oops\n}", 3, 15, "invalid character"},
+		{"Truncated", "{\"@type\": \"Article\"", 1, 20, "unexpected end of JSON input"},
+		{"TrailingData", "{} {}", 1, 4, "unexpected data after the JSON value"},
+		{"Empty", "  ", 1, 1, "empty JSON-LD block"},
+		{"NotAnObject", `"Article"`, 1, 1, "must be an object"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := parseJSONLD(tc.text, 2)
+			if err == nil {
+				t.Fatalf("Expected an error for %q", tc.text)
+			}
+			if err.Block != 2 || err.Line != tc.wantLine || err.Column != tc.wantColumn || !strings.Contains(err.Message, tc.wantMsg) {
+				t.Errorf("Expected block 2, line %d, column %d, message containing %q; got %+v", tc.wantLine, tc.wantColumn, tc.wantMsg, err)
+			}
+		})
+	}
+}
+
+func TestExtractStructuredData(t *testing.T) {
+	page := `<html><head>
+		<meta property="og:title" content="Not RDFa">
+		<script type="application/ld+json">
+		{"@context": "https://schema.org", "@graph": [
+			{"@type": "Organization", "name": "Acme", "url": "https://acme.example", "logo": "https://acme.example/logo.png"},
+			{"@type": "Product", "name": "Anvil", "offers": {"@type": "Offer", "price": 9.5}}
+		]}
+		</script>
+		<script type="application/ld+json">{broken</script>
+	</head><body>
+		<div itemscope itemtype="https://schema.org/BreadcrumbList">
+			<div itemprop="itemListElement" itemscope itemtype="https://schema.org/ListItem">
+				<a itemprop="item" href="/books"><span itemprop="name">Books</span></a>
+				<meta itemprop="position" content="1">
+			</div>
+		</div>
+		<article vocab="https://schema.org/" typeof="Article" resource="#post">
+			<h1 property="headline">Hello</h1>
+			<span property="author" typeof="Person"><span property="name">Ann</span></span>
+			<time property="datePublished" datetime="2024-05-01">May 1</time>
+			<img property="image" src="/hello.png">
+			<span property="dateModified publisher" content="x"></span>
+		</article>
+	</body></html>`
+	doc, err := html.Parse(strings.NewReader(page))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	base, _ := url.Parse("https://shop.example/dir/page")
+
+	data := extractStructuredData(doc, base)
+
+	if len(data.Errors) != 1 || data.Errors[0].Block != 2 {
+		t.Errorf("Expected one error for the second JSON-LD block, got %+v", data.Errors)
+	}
+	if len(data.Items) != 4 {
+		t.Fatalf("Expected 4 top-level items, got %d: %+v", len(data.Items), data.Items)
+	}
+	wantTypes := []string{"Organization", "Product", "BreadcrumbList", "Article"}
+	wantFormats := []StructuredDataFormat{FormatJSONLD, FormatJSONLD, FormatMicrodata, FormatRDFa}
+	for i, item := range data.Items {
+		if strings.Join(item.Types, ",") != wantTypes[i] || item.Format != wantFormats[i] {
+			t.Errorf("Item %d: expected %s %s, got %s %v", i, wantFormats[i], wantTypes[i], item.Format, item.Types)
+		}
+	}
+
+	breadcrumb := data.Items[2].Properties["itemListElement"][0].Item
+	if breadcrumb == nil || breadcrumb.Properties["item"][0].Text != "https://shop.example/books" ||
+		breadcrumb.Properties["name"][0].Text != "Books" || breadcrumb.Properties["position"][0].Text != "1" {
+		t.Errorf("Expected a flattened ListItem with resolved URL, got %+v", breadcrumb)
+	}
+	article := data.Items[3]
+	if article.ID != "https://shop.example/dir/page#post" || article.Properties["datePublished"][0].Text != "2024-05-01" ||
+		article.Properties["author"][0].Item.Properties["name"][0].Text != "Ann" || article.Properties["image"][0].Text != "https://shop.example/hello.png" {
+		t.Errorf("Unexpected RDFa article: %+v", article)
+	}
+
+	var issues []string
+	for _, issue := range data.Issues {
+		issues = append(issues, issue.Severity+" "+issue.Path+" "+issue.Type+"."+issue.Property)
+	}
+	want := []string{
+		"warning Product Product.image",
+		"warning Product Product.description",
+		"warning Product Product.brand",
+		"warning Product Product.sku",
+		"error Product > offers Offer.priceCurrency",
+		"warning Product > offers Offer.availability",
+		"warning Product > offers Offer.url",
+	}
+	if strings.Join(issues, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected issues:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(issues, "\n"))
+	}
+}
@@ -0,0 +1,240 @@
+package analyzer
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxRetryWait caps a single wait before a retry; a longer Retry-After is not waited for
+	maxRetryWait = 30 * time.Second
+	// maxBackoff caps the exponential backoff between retries
+	maxBackoff = 10 * time.Second
+	// hostIdleTTL is how long an unused host is remembered, so long-lived throttles don't grow forever
+	hostIdleTTL = 10 * time.Minute
+)
+
+// HostThrottle limits how hard link checks hit each host: a cap on requests in flight, a token
+// bucket for the request rate, and a pause after the host asks us to back off. Hosts are keyed
+// by scheme, host name and port, with default ports omitted, so each origin server is throttled
+// on its own. A HostThrottle is safe for concurrent use; share one between Analyzers with
+// WithHostThrottle so that concurrent analyses of the same host are limited together.
+type HostThrottle struct {
+	concurrency int     // requests in flight per host
+	rate        float64 // requests per second per host; 0 for no limit
+	burst       int     // requests a host may receive at once after being idle
+	parent      *HostThrottle
+
+	mu        sync.Mutex
+	hosts     map[string]*hostState
+	lastSweep time.Time
+}
+
+// hostState is the throttling state of one host
+type hostState struct {
+	slots        chan struct{}
+	tokens       float64
+	refilled     time.Time
+	blockedUntil time.Time // set from Retry-After
+	lastUsed     time.Time
+}
+
+// NewHostThrottle creates a HostThrottle allowing concurrency requests in flight and rate
+// requests per second, in bursts of up to burst, to each host. A rate of 0 disables the rate limit.
+func NewHostThrottle(concurrency int, rate float64, burst int) *HostThrottle {
+	return &HostThrottle{concurrency: max(concurrency, 1), rate: max(rate, 0), burst: max(burst, 1), hosts: make(map[string]*hostState), lastSweep: time.Now()}
+}
+
+// Limits returns the per-host limits the throttle was created with
+func (t *HostThrottle) Limits() (concurrency int, rate float64, burst int) {
+	return t.concurrency, t.rate, t.burst
+}
+
+// hostKey identifies the origin server of rawURL, e.g. "https://example.com:" for both
+// https://example.com/ and https://EXAMPLE.com:443/. URLs without a host share one key.
+func hostKey(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	return strings.ToLower(u.Scheme+"://"+u.Hostname()) + ":" + explicitPort(u)
+}
+
+// host returns the state of the host of rawURL, creating it on first use
+func (t *HostThrottle) host(rawURL string) *hostState {
+	key := hostKey(rawURL)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	if now.Sub(t.lastSweep) > hostIdleTTL {
+		t.sweep(now)
+	}
+	h, ok := t.hosts[key]
+	if !ok {
+		h = &hostState{slots: make(chan struct{}, t.concurrency), tokens: float64(t.burst), refilled: now}
+		t.hosts[key] = h
+	}
+	h.lastUsed = now
+	return h
+}
+
+// sweep forgets hosts that have been idle for hostIdleTTL: no request in flight or waiting,
+// no Retry-After pause left and a full token bucket, so forgetting them loses nothing.
+// t.mu must be held.
+func (t *HostThrottle) sweep(now time.Time) {
+	for key, h := range t.hosts {
+		full := t.rate == 0 || h.tokens+now.Sub(h.refilled).Seconds()*t.rate >= float64(t.burst)
+		if len(h.slots) == 0 && now.Sub(h.lastUsed) > hostIdleTTL && now.After(h.blockedUntil) && full {
+			delete(t.hosts, key)
+		}
+	}
+	t.lastSweep = now
+}
+
+// acquire blocks until a request slot for the host of rawURL is free, here and in any parent.
+// It returns a function releasing the slots, or false if ctx is done first.
+func (t *HostThrottle) acquire(ctx context.Context, rawURL string) (func(), bool) {
+	h := t.host(rawURL)
+	select {
+	case h.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, false
+	}
+	if t.parent == nil {
+		return func() { <-h.slots }, true
+	}
+	releaseParent, ok := t.parent.acquire(ctx, rawURL)
+	if !ok {
+		<-h.slots
+		return nil, false
+	}
+	return func() { releaseParent(); <-h.slots }, true
+}
+
+// wait blocks until the host of rawURL may receive another request, here and in any parent:
+// any Retry-After pause has passed and every token bucket has a token. The tokens are taken
+// together once all are available, so none is spent while the request still waits elsewhere.
+// It returns false if ctx is done first.
+func (t *HostThrottle) wait(ctx context.Context, rawURL string) bool {
+	var levels []*HostThrottle
+	var states []*hostState
+	for l := t; l != nil; l = l.parent {
+		levels, states = append(levels, l), append(states, l.host(rawURL))
+	}
+	for {
+		// Children are always locked before their parents, so this can't deadlock
+		for _, l := range levels {
+			l.mu.Lock()
+		}
+		now := time.Now()
+		var delay time.Duration
+		for i, l := range levels {
+			delay = max(delay, l.refill(states[i], now))
+		}
+		if delay <= 0 {
+			for i, l := range levels {
+				if l.rate > 0 {
+					states[i].tokens--
+				}
+			}
+		}
+		for _, l := range levels {
+			l.mu.Unlock()
+		}
+
+		if delay <= 0 {
+			return true
+		}
+		if !sleep(ctx, delay) {
+			return false
+		}
+	}
+}
+
+// refill tops up the token bucket of h and returns how long until it may receive a request.
+// t.mu must be held.
+func (t *HostThrottle) refill(h *hostState, now time.Time) time.Duration {
+	h.lastUsed = now
+	delay := h.blockedUntil.Sub(now)
+	if t.rate > 0 {
+		h.tokens = min(h.tokens+now.Sub(h.refilled).Seconds()*t.rate, float64(t.burst))
+		h.refilled = now
+		if h.tokens < 1 {
+			delay = max(delay, time.Duration((1-h.tokens)/t.rate*float64(time.Second)))
+		}
+	}
+	return delay
+}
+
+// block pauses requests to the host of rawURL for d, e.g. as asked by Retry-After, here and
+// in any parent
+func (t *HostThrottle) block(rawURL string, d time.Duration) {
+	h := t.host(rawURL)
+	t.mu.Lock()
+	if until := time.Now().Add(d); until.After(h.blockedUntil) {
+		h.blockedUntil = until
+	}
+	t.mu.Unlock()
+	if t.parent != nil {
+		t.parent.block(rawURL, d)
+	}
+}
+
+// sleep waits for d or until ctx is done, reporting whether the full duration passed
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// retryable reports whether a failed link check may succeed if tried again: the server was
+// overloaded or rate limiting, or the connection failed for no specific reason
+func retryable(res LinkCheckResult) bool {
+	switch res.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return res.StatusCode == 0 && res.ErrorClass == LinkErrorNetwork
+}
+
+// retryAfter parses a Retry-After header given in seconds or as an HTTP date, returning
+// false if it is absent or invalid
+func retryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(secs)*time.Second, 0), secs >= 0
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(at.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// backoff returns the wait before retry number attempt (1 for the first retry): base doubled
+// for each earlier retry, capped at maxBackoff, with the upper half randomized so that
+// checks failing together don't retry together
+func backoff(base time.Duration, attempt int) time.Duration {
+	d := base
+	for i := 1; i < attempt && d < maxBackoff; i++ {
+		d *= 2
+	}
+	d = min(d, maxBackoff)
+	if d <= 1 {
+		return d
+	}
+	return d/2 + rand.N(d/2)
+}
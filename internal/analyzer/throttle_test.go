@@ -0,0 +1,297 @@
+package analyzer
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name   string
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{"Seconds", "120", 2 * time.Minute, true},
+		{"Zero", "0", 0, true},
+		{"HTTP date", "Sun, 01 Jun 2025 12:00:30 GMT", 30 * time.Second, true},
+		{"Date in the past", "Sun, 01 Jun 2025 11:00:00 GMT", 0, true},
+		{"Negative", "-5", 0, false},
+		{"Empty", "", 0, false},
+		{"Garbage", "soon", 0, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := retryAfter(tc.value, now)
+			if got != tc.want || ok != tc.wantOK {
+				t.Errorf("Expected retryAfter(%q) to be %v, %v, got %v, %v", tc.value, tc.want, tc.wantOK, got, ok)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	base := 100 * time.Millisecond
+	for attempt, ceiling := range map[int]time.Duration{1: base, 2: 2 * base, 3: 4 * base, 20: maxBackoff} {
+		for range 50 {
+			if d := backoff(base, attempt); d < ceiling/2 || d >= ceiling {
+				t.Fatalf("Expected backoff for attempt %d in [%v, %v), got %v", attempt, ceiling/2, ceiling, d)
+			}
+		}
+	}
+}
+
+func TestHostThrottle_Rate(t *testing.T) {
+	throttle := NewHostThrottle(1, 20, 2)
+
+	start := time.Now()
+	for range 4 {
+		if !throttle.wait(t.Context(), "http://example.com/page") {
+			t.Fatal("Expected wait to succeed")
+		}
+	}
+	// The burst covers 2 requests, the other 2 wait 50ms each
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("Expected 4 requests at 20/s with a burst of 2 to take about 100ms, took %v", elapsed)
+	}
+
+	// Other hosts have their own bucket
+	start = time.Now()
+	throttle.wait(t.Context(), "http://other.example.com/")
+	if elapsed := time.Since(start); elapsed > 20*time.Millisecond {
+		t.Errorf("Expected another host not to wait, waited %v", elapsed)
+	}
+}
+
+func TestHostThrottle_Block(t *testing.T) {
+	throttle := NewHostThrottle(1, 0, 1)
+	throttle.block("http://example.com/a", 80*time.Millisecond)
+
+	start := time.Now()
+	throttle.wait(t.Context(), "http://EXAMPLE.com/b")
+	if elapsed := time.Since(start); elapsed < 70*time.Millisecond {
+		t.Errorf("Expected the blocked host to wait about 80ms, waited %v", elapsed)
+	}
+}
+
+func TestCheckLinkResults_HostConcurrency(t *testing.T) {
+	var inFlight, peak atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+		}
+		time.Sleep(20 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	var links []PageLink
+	for _, path := range []string{"/a", "/b", "/c", "/d", "/e", "/f"} {
+		links = append(links, PageLink{URL: server.URL + path, Tag: "a"})
+	}
+	a := New(WithRobotsPolicy(RobotsIgnore), WithLinkConcurrency(10), WithHostConcurrency(2), WithHostRateLimit(0, 1))
+	results := a.checkLinkResults(t.Context(), links, nil)
+
+	if len(results) != len(links) {
+		t.Fatalf("Expected %d results, got %d", len(links), len(results))
+	}
+	if got := peak.Load(); got > 2 {
+		t.Errorf("Expected at most 2 requests in flight to the host, got %d", got)
+	}
+}
+
+func TestCheckLink_Retries(t *testing.T) {
+	var mu sync.Mutex
+	requests := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path]++
+		n := requests[r.URL.Path]
+		mu.Unlock()
+
+		switch r.URL.Path {
+		case "/limited":
+			if n == 1 {
+				w.Header().Set("Retry-After", "1")
+				w.WriteHeader(http.StatusTooManyRequests)
+			}
+		case "/flaky":
+			if n < 3 {
+				w.WriteHeader(http.StatusBadGateway)
+			}
+		case "/down":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	a := New(WithRobotsPolicy(RobotsIgnore), WithHostRateLimit(0, 1), WithRetries(2, 10*time.Millisecond))
+
+	testCases := []struct {
+		path         string
+		wantStatus   int
+		wantAttempts int
+		minLatency   time.Duration
+	}{
+		{"/limited", http.StatusOK, 2, time.Second},
+		{"/flaky", http.StatusOK, 3, 0},
+		{"/down", http.StatusServiceUnavailable, 3, 0},
+		{"/missing", http.StatusNotFound, 1, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			res, _ := a.checkLink(t.Context(), PageLink{URL: server.URL + tc.path, Tag: "a"})
+			if res.StatusCode != tc.wantStatus || res.Attempts != tc.wantAttempts {
+				t.Errorf("Expected status %d after %d attempts, got %d after %d", tc.wantStatus, tc.wantAttempts, res.StatusCode, res.Attempts)
+			}
+			if res.Latency < tc.minLatency {
+				t.Errorf("Expected the check to wait at least %v, took %v", tc.minLatency, res.Latency)
+			}
+		})
+	}
+}
+
+func TestCheckLink_RetryAfterTooLong(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "5")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+	a := New(WithRobotsPolicy(RobotsIgnore))
+
+	// Longer than the analysis has left
+	ctx, cancel := context.WithTimeout(t.Context(), time.Second)
+	defer cancel()
+	res, checked := a.checkLink(ctx, PageLink{URL: server.URL, Tag: "a"})
+	if !checked || res.StatusCode != http.StatusTooManyRequests || res.Attempts != 1 || res.Latency > 500*time.Millisecond {
+		t.Fatalf("Expected a single 429 attempt without waiting, got %+v", res)
+	}
+	// The host is not paused for a wait nobody makes
+	start := time.Now()
+	a.throttle.wait(t.Context(), server.URL)
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("Expected the host not to be blocked, waited %v", elapsed)
+	}
+}
+
+func TestCheckLinkResults_RetryAfterOutsideLinkTimeout(t *testing.T) {
+	var limited atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/p0" && limited.CompareAndSwap(false, true) {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+
+	links := []PageLink{{URL: server.URL + "/p0", Tag: "a"}, {URL: server.URL + "/p1", Tag: "a"}, {URL: server.URL + "/p2", Tag: "a"}}
+	// The Retry-After pause of the host is longer than the link timeout, but is not part of it
+	a := New(WithRobotsPolicy(RobotsIgnore), WithLinkTimeout(300*time.Millisecond), WithHostConcurrency(1))
+	results := a.checkLinkResults(t.Context(), links, nil)
+
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results, got %d", len(results))
+	}
+	for _, res := range results {
+		if !res.Accessible() {
+			t.Errorf("Expected %s to be accessible, got %+v", res.URL, res)
+		}
+	}
+	if results[0].Attempts != 2 {
+		t.Errorf("Expected /p0 to be retried once, got %d attempts", results[0].Attempts)
+	}
+}
+
+func TestHostKey(t *testing.T) {
+	testCases := []struct{ a, b string }{
+		{"https://example.com/", "https://EXAMPLE.com:443/page"},
+		{"http://example.com", "http://example.com:80/"},
+		{"not a url %zz", "mailto:someone@example.com"},
+	}
+	for _, tc := range testCases {
+		if hostKey(tc.a) != hostKey(tc.b) {
+			t.Errorf("Expected %q and %q to share a host key, got %q and %q", tc.a, tc.b, hostKey(tc.a), hostKey(tc.b))
+		}
+	}
+	for _, other := range []string{"http://example.com/", "https://example.com:8443/", "https://www.example.com/"} {
+		if hostKey(other) == hostKey("https://example.com/") {
+			t.Errorf("Expected %q to have a host key of its own", other)
+		}
+	}
+}
+
+func TestWithHostThrottle_Shared(t *testing.T) {
+	var inFlight, peak atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+		}
+		time.Sleep(20 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	shared := NewHostThrottle(1, 0, 1)
+	var wg sync.WaitGroup
+	for _, path := range []string{"/a", "/b", "/c", "/d"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Separate analyzers, each allowing 2 requests to the host on its own
+			a := New(WithRobotsPolicy(RobotsIgnore), WithHostThrottle(shared), WithHostRateLimit(0, 1))
+			a.checkLinkResults(t.Context(), []PageLink{{URL: server.URL + path, Tag: "a"}}, nil)
+		}()
+	}
+	wg.Wait()
+
+	if got := peak.Load(); got != 1 {
+		t.Errorf("Expected the shared throttle to allow 1 request in flight to the host, got %d", got)
+	}
+}
+
+func TestHostThrottle_ParentWaitKeepsChildToken(t *testing.T) {
+	parent := NewHostThrottle(1, 1, 1)
+	child := NewHostThrottle(1, 1, 1)
+	child.parent = parent
+	parent.wait(t.Context(), "http://example.com/") // the parent has no token left for a second
+
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+	if child.wait(ctx, "http://example.com/") {
+		t.Fatal("Expected the wait to stop when ctx is done")
+	}
+	// The child's token was not spent on a request that never happened
+	if tokens := child.host("http://example.com/").tokens; tokens < 1 {
+		t.Errorf("Expected the child to keep its token, has %v", tokens)
+	}
+}
+
+func TestHostThrottle_Sweep(t *testing.T) {
+	throttle := NewHostThrottle(1, 10, 1)
+	throttle.wait(t.Context(), "http://idle.example.com/")
+	throttle.block("http://blocked.example.com/", time.Hour)
+	release, _ := throttle.acquire(t.Context(), "http://busy.example.com/")
+	defer release()
+
+	throttle.mu.Lock()
+	throttle.sweep(time.Now().Add(hostIdleTTL + time.Minute))
+	_, idle := throttle.hosts[hostKey("http://idle.example.com/")]
+	_, blocked := throttle.hosts[hostKey("http://blocked.example.com/")]
+	_, busy := throttle.hosts[hostKey("http://busy.example.com/")]
+	throttle.mu.Unlock()
+
+	if idle || !blocked || !busy {
+		t.Errorf("Expected only the idle host to be forgotten, got idle %v, blocked %v, busy %v", idle, blocked, busy)
+	}
+}
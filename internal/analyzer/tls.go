@@ -0,0 +1,114 @@
+package analyzer
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// TLSReport describes the TLS connection the page was fetched over
+type TLSReport struct {
+	Version     string            `json:"version"` // e.g. "TLS 1.3"
+	CipherSuite string            `json:"cipher_suite"`
+	ServerName  string            `json:"server_name"` // host the certificate must be valid for
+	Certificate CertificateInfo   `json:"certificate"` // leaf certificate
+	Chain       []CertificateInfo `json:"chain"`       // certificates sent by the server after the leaf
+	ChainValid  bool              `json:"chain_valid"` // the chain leads to a trusted root, ignoring the hostname
+	ChainError  string            `json:"chain_error,omitempty"`
+	// HostnameMatch is false when the leaf certificate is not valid for ServerName
+	HostnameMatch bool     `json:"hostname_match"`
+	Warnings      []string `json:"warnings"`
+}
+
+// CertificateInfo summarizes an X.509 certificate
+type CertificateInfo struct {
+	Subject       string    `json:"subject"`
+	Issuer        string    `json:"issuer"`
+	SANs          []string  `json:"sans,omitempty"` // DNS names and IP addresses
+	NotBefore     time.Time `json:"not_before"`
+	NotAfter      time.Time `json:"not_after"`
+	DaysRemaining int       `json:"days_remaining"` // negative once expired
+}
+
+// inspectTLS builds the TLSReport of a connection to host. roots are the trusted roots (nil
+// for the system pool); certificates expiring within expiryWarning of now get a warning.
+func inspectTLS(state *tls.ConnectionState, host string, roots *x509.CertPool, expiryWarning time.Duration, now time.Time) *TLSReport {
+	report := &TLSReport{
+		Version:     tls.VersionName(state.Version),
+		CipherSuite: tls.CipherSuiteName(state.CipherSuite),
+		ServerName:  host,
+		Chain:       []CertificateInfo{},
+		Warnings:    []string{},
+	}
+	if state.Version < tls.VersionTLS12 {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("%s is deprecated; use TLS 1.2 or later", report.Version))
+	}
+	if len(state.PeerCertificates) == 0 {
+		report.ChainError = "server sent no certificate"
+		report.Warnings = append(report.Warnings, report.ChainError)
+		return report
+	}
+
+	leaf := state.PeerCertificates[0]
+	report.Certificate = certificateInfo(leaf, now)
+	for _, c := range state.PeerCertificates[1:] {
+		report.Chain = append(report.Chain, certificateInfo(c, now))
+	}
+
+	// The chain is verified separately from the hostname so that each problem is reported on
+	// its own, even when the client was configured to skip verification
+	intermediates := x509.NewCertPool()
+	for _, c := range state.PeerCertificates[1:] {
+		intermediates.AddCert(c)
+	}
+	if _, err := leaf.Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates, CurrentTime: now}); err != nil {
+		report.ChainError = err.Error()
+		report.Warnings = append(report.Warnings, "certificate chain is not trusted: "+report.ChainError)
+	} else {
+		report.ChainValid = true
+	}
+	if err := leaf.VerifyHostname(host); err != nil {
+		report.Warnings = append(report.Warnings, err.Error())
+	} else {
+		report.HostnameMatch = true
+	}
+
+	for _, c := range state.PeerCertificates {
+		switch info := certificateInfo(c, now); {
+		case now.After(c.NotAfter):
+			report.Warnings = append(report.Warnings, fmt.Sprintf("certificate %q expired on %s", info.Subject, c.NotAfter.Format(time.DateOnly)))
+		case now.Before(c.NotBefore):
+			report.Warnings = append(report.Warnings, fmt.Sprintf("certificate %q is not valid until %s", info.Subject, c.NotBefore.Format(time.DateOnly)))
+		case c.NotAfter.Sub(now) < expiryWarning:
+			report.Warnings = append(report.Warnings, fmt.Sprintf("certificate %q expires in %d day(s), on %s", info.Subject, info.DaysRemaining, c.NotAfter.Format(time.DateOnly)))
+		}
+	}
+	return report
+}
+
+// certificateInfo summarizes c as of now
+func certificateInfo(c *x509.Certificate, now time.Time) CertificateInfo {
+	info := CertificateInfo{
+		Subject:       c.Subject.String(),
+		Issuer:        c.Issuer.String(),
+		SANs:          slices.Clone(c.DNSNames),
+		NotBefore:     c.NotBefore,
+		NotAfter:      c.NotAfter,
+		DaysRemaining: int(c.NotAfter.Sub(now).Hours() / 24),
+	}
+	for _, ip := range c.IPAddresses {
+		info.SANs = append(info.SANs, ip.String())
+	}
+	return info
+}
+
+// rootCAs returns the roots the analyzer's client trusts, or nil for the system pool
+func (a *Analyzer) rootCAs() *x509.CertPool {
+	if t, ok := a.client.Transport.(*http.Transport); ok && t.TLSClientConfig != nil {
+		return t.TLSClientConfig.RootCAs
+	}
+	return nil
+}
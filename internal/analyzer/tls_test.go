@@ -0,0 +1,79 @@
+package analyzer
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAnalyze_TLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><body></body></html>"))
+	}))
+	defer server.Close()
+
+	t.Run("Trusted", func(t *testing.T) {
+		result, err := New(WithHTTPClient(server.Client()), WithLinkCheck(false)).Analyze(t.Context(), server.URL)
+		if err != nil {
+			t.Fatalf("Analyze failed unexpectedly: %v", err)
+		}
+		report := result.TLS
+		if report == nil {
+			t.Fatal("Expected a TLS report for an HTTPS page")
+		}
+		if !strings.HasPrefix(report.Version, "TLS 1.") || report.CipherSuite == "" {
+			t.Errorf("Expected a TLS version and cipher suite, got %q and %q", report.Version, report.CipherSuite)
+		}
+		if report.ServerName != "127.0.0.1" || !report.ChainValid || !report.HostnameMatch || len(report.Warnings) != 0 {
+			t.Errorf("Expected a valid chain matching 127.0.0.1 without warnings, got %+v", report)
+		}
+		cert := server.Certificate()
+		if report.Certificate.Issuer != cert.Issuer.String() || !report.Certificate.NotAfter.Equal(cert.NotAfter) {
+			t.Errorf("Expected issuer %q expiring %s, got %+v", cert.Issuer, cert.NotAfter, report.Certificate)
+		}
+		if !strings.Contains(strings.Join(report.Certificate.SANs, " "), "127.0.0.1") {
+			t.Errorf("Expected 127.0.0.1 among the SANs, got %v", report.Certificate.SANs)
+		}
+	})
+
+	t.Run("Expiry window", func(t *testing.T) {
+		// The test certificate is valid for decades, so a longer window is needed to trigger the warning
+		a := New(WithHTTPClient(server.Client()), WithLinkCheck(false), WithCertExpiryWarning(200*365*24*time.Hour))
+		result, err := a.Analyze(t.Context(), server.URL)
+		if err != nil {
+			t.Fatalf("Analyze failed unexpectedly: %v", err)
+		}
+		if len(result.TLS.Warnings) != 1 || !strings.Contains(result.TLS.Warnings[0], "expires in") {
+			t.Errorf("Expected an expiry warning, got %v", result.TLS.Warnings)
+		}
+	})
+
+	t.Run("Untrusted", func(t *testing.T) {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+		result, err := New(WithHTTPClient(client), WithLinkCheck(false)).Analyze(t.Context(), server.URL)
+		if err != nil {
+			t.Fatalf("Analyze failed unexpectedly: %v", err)
+		}
+		if result.TLS.ChainValid || result.TLS.ChainError == "" || !result.TLS.HostnameMatch {
+			t.Errorf("Expected an untrusted chain with a matching hostname, got %+v", result.TLS)
+		}
+	})
+
+	t.Run("Hostname mismatch and old version", func(t *testing.T) {
+		roots := x509.NewCertPool()
+		roots.AddCert(server.Certificate())
+		state := &tls.ConnectionState{Version: tls.VersionTLS11, PeerCertificates: []*x509.Certificate{server.Certificate()}}
+		report := inspectTLS(state, "other.test", roots, DefaultCertExpiryWarning, time.Now())
+		if !report.ChainValid || report.HostnameMatch || len(report.Warnings) != 2 {
+			t.Fatalf("Expected a valid chain not matching other.test with 2 warnings, got %+v", report)
+		}
+		if !strings.Contains(report.Warnings[0], "TLS 1.1 is deprecated") || !strings.Contains(report.Warnings[1], "other.test") {
+			t.Errorf("Expected version and hostname warnings, got %v", report.Warnings)
+		}
+	})
+}
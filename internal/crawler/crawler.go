@@ -0,0 +1,333 @@
+// Package crawler audits a whole site by following internal links from a seed
+// page and running the analyzer on every page it finds.
+package crawler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/tharaka70/web_analyzer/internal/analyzer"
+)
+
+// Defaults used by New when no option overrides them
+const (
+	DefaultMaxDepth     = 2
+	DefaultMaxPages     = 50
+	DefaultConcurrency  = 4
+	DefaultPerHostLimit = 2
+)
+
+// Crawler follows internal links breadth-first, analyzing each page. Which links are internal
+// is decided by the analyzer's link policy (analyzer.WithLinkPolicy). Create one with New.
+type Crawler struct {
+	analyzerOpts  []analyzer.Option
+	maxDepth      int
+	maxPages      int
+	include       []*regexp.Regexp
+	exclude       []*regexp.Regexp
+	concurrency   int
+	perHostLimit  int
+	checkSitemaps bool
+}
+
+// Option configures a Crawler
+type Option func(*Crawler)
+
+// WithAnalyzerOptions sets the options used for the analyzer run on every page
+func WithAnalyzerOptions(opts ...analyzer.Option) Option {
+	return func(c *Crawler) { c.analyzerOpts = append(c.analyzerOpts, opts...) }
+}
+
+// WithMaxDepth limits how many links away from the seed the crawl goes. The seed has depth 0.
+func WithMaxDepth(n int) Option {
+	return func(c *Crawler) {
+		if n >= 0 {
+			c.maxDepth = n
+		}
+	}
+}
+
+// WithMaxPages limits how many pages are analyzed in total, including the seed
+func WithMaxPages(n int) Option {
+	return func(c *Crawler) {
+		if n > 0 {
+			c.maxPages = n
+		}
+	}
+}
+
+// WithInclude only follows links whose path matches at least one of the patterns
+func WithInclude(patterns ...*regexp.Regexp) Option {
+	return func(c *Crawler) { c.include = append(c.include, patterns...) }
+}
+
+// WithExclude never follows links whose path matches any of the patterns
+func WithExclude(patterns ...*regexp.Regexp) Option {
+	return func(c *Crawler) { c.exclude = append(c.exclude, patterns...) }
+}
+
+// WithConcurrency sets how many pages are analyzed in parallel overall
+func WithConcurrency(n int) Option {
+	return func(c *Crawler) {
+		if n > 0 {
+			c.concurrency = n
+		}
+	}
+}
+
+// WithPerHostLimit sets how many pages of the same host are analyzed in parallel
+func WithPerHostLimit(n int) Option {
+	return func(c *Crawler) {
+		if n > 0 {
+			c.perHostLimit = n
+		}
+	}
+}
+
+// WithSitemapCheck enables or disables validating the site's sitemaps once per crawl and
+// reporting the sitemap URLs the crawl did not reach
+func WithSitemapCheck(enabled bool) Option {
+	return func(c *Crawler) { c.checkSitemaps = enabled }
+}
+
+// New creates a Crawler with sensible defaults, overridden by the given options
+func New(opts ...Option) *Crawler {
+	c := &Crawler{
+		maxDepth:     DefaultMaxDepth,
+		maxPages:     DefaultMaxPages,
+		concurrency:  DefaultConcurrency,
+		perHostLimit: DefaultPerHostLimit,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// PageReport is the outcome of analyzing one crawled page
+type PageReport struct {
+	URL           string                   `json:"url"`
+	Depth         int                      `json:"depth"`
+	FoundOn       string                   `json:"found_on,omitempty"` // page that first linked here
+	Result        *analyzer.AnalysisResult `json:"result,omitempty"`
+	Err           error                    `json:"-"`
+	Error         string                   `json:"error,omitempty"`
+	ErrorCategory analyzer.ErrorCategory   `json:"error_category,omitempty"`
+	StatusCode    int                      `json:"status_code,omitempty"`
+}
+
+// pageTask is a page waiting to be analyzed
+type pageTask struct {
+	URL     string
+	Depth   int
+	FoundOn string
+}
+
+// Crawl analyzes seedURL and the internal pages reachable from it, within the configured
+// limits. If ctx is cancelled the pages analyzed so far are returned together with ctx's error.
+func (c *Crawler) Crawl(ctx context.Context, seedURL string) (*SiteReport, error) {
+	seed, err := analyzer.ValidateURL(seedURL)
+	if err != nil {
+		return nil, err
+	}
+	seedStr := analyzer.NormalizeLinkURL(seed)
+
+	// Sitemaps belong to the site, not to a page, so they are checked once below rather than per page
+	a := analyzer.New(slices.Concat(c.analyzerOpts, []analyzer.Option{analyzer.WithSitemapCheck(false)})...)
+	limiter := newHostLimiter(c.concurrency, c.perHostLimit)
+	policy := a.LinkPolicy() // follow the links the reports count as internal
+
+	seen := map[string]bool{seedStr: true}
+	frontier := []pageTask{{URL: seedStr}}
+	var pages []PageReport
+	truncated := false
+
+	for depth := 0; len(frontier) > 0; depth++ {
+		slog.Info("Crawling level", "depth", depth, "pages", len(frontier))
+		levelPages := c.crawlLevel(ctx, a, limiter, frontier)
+		pages = append(pages, levelPages...)
+		if ctx.Err() != nil || depth >= c.maxDepth {
+			break
+		}
+
+		var next []pageTask
+		for _, page := range levelPages {
+			if page.Result == nil {
+				continue
+			}
+			for _, link := range page.Result.Links {
+				if link.Tag != "a" {
+					continue // only follow hyperlinks, not stylesheets etc.
+				}
+				// Compare with the seed rather than trusting link.Internal, which is relative
+				// to the page's final URL and so follows it if the page redirected off-site
+				linkURL, parseErr := url.Parse(link.URL)
+				if parseErr != nil || !policy.Internal(seed, linkURL) {
+					continue
+				}
+				linkStr := analyzer.NormalizeLinkURL(linkURL)
+				if seen[linkStr] || !c.allowed(linkURL) {
+					continue
+				}
+				seen[linkStr] = true
+				if len(pages)+len(next) >= c.maxPages {
+					truncated = true
+					continue
+				}
+				next = append(next, pageTask{URL: linkStr, Depth: depth + 1, FoundOn: page.URL})
+			}
+		}
+		frontier = next
+	}
+
+	report := buildReport(seedStr, pages)
+	report.Truncated = truncated
+	if c.checkSitemaps && ctx.Err() == nil {
+		report.Sitemap, _ = a.CheckSitemaps(ctx, seedStr) // seedStr was validated above
+		report.SitemapURLsNotCrawled = sitemapURLsNotCrawled(report.Sitemap, pages)
+	}
+	return report, ctx.Err()
+}
+
+// crawlLevel analyzes one breadth-first level concurrently and returns the reports in task order
+func (c *Crawler) crawlLevel(ctx context.Context, a *analyzer.Analyzer, limiter *hostLimiter, tasks []pageTask) []PageReport {
+	reports := make([]PageReport, len(tasks))
+	var wg sync.WaitGroup
+	for i, task := range tasks {
+		u, _ := url.Parse(task.URL) // tasks only hold URLs that already parsed
+		if !limiter.acquire(ctx, u.Host) {
+			reports = reports[:i]
+			break
+		}
+		wg.Add(1)
+		go func(i int, task pageTask, host string) {
+			defer wg.Done()
+			defer limiter.release(host)
+			reports[i] = c.crawlPage(ctx, a, task)
+		}(i, task, u.Host)
+	}
+	wg.Wait()
+	return reports
+}
+
+// crawlPage analyzes a single page
+func (c *Crawler) crawlPage(ctx context.Context, a *analyzer.Analyzer, task pageTask) PageReport {
+	report := PageReport{URL: task.URL, Depth: task.Depth, FoundOn: task.FoundOn}
+	result, err := a.Analyze(ctx, task.URL)
+	if err != nil {
+		slog.Warn("Could not analyze crawled page", "url", task.URL, "error", err)
+		report.Err = err
+		report.Error = err.Error()
+		var ae *analyzer.AnalysisError
+		if errors.As(err, &ae) {
+			report.ErrorCategory = ae.Category
+			report.StatusCode = ae.StatusCode
+		}
+		return report
+	}
+	report.Result = result
+	return report
+}
+
+// allowed applies the include and exclude path patterns
+func (c *Crawler) allowed(u *url.URL) bool {
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	for _, re := range c.exclude {
+		if re.MatchString(path) {
+			return false
+		}
+	}
+	if len(c.include) == 0 {
+		return true
+	}
+	for _, re := range c.include {
+		if re.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// sitemapURLsNotCrawled lists the URLs of the sitemap that no crawled page corresponds to
+func sitemapURLsNotCrawled(sitemap *analyzer.SitemapReport, pages []PageReport) []string {
+	crawled := make(map[string]bool, len(pages))
+	for _, page := range pages {
+		crawled[page.URL] = true
+	}
+	missing := []string{}
+	for _, raw := range sitemap.URLs {
+		u, err := url.Parse(raw)
+		if err != nil || !crawled[analyzer.NormalizeLinkURL(u)] {
+			missing = append(missing, raw)
+		}
+	}
+	return missing
+}
+
+// hostLimiter bounds both overall and per-host concurrency
+type hostLimiter struct {
+	global  chan struct{}
+	perHost int
+
+	mu    sync.Mutex
+	hosts map[string]chan struct{}
+}
+
+func newHostLimiter(global, perHost int) *hostLimiter {
+	return &hostLimiter{
+		global:  make(chan struct{}, global),
+		perHost: perHost,
+		hosts:   make(map[string]chan struct{}),
+	}
+}
+
+// acquire blocks until a global and a per-host slot are free; it returns false if ctx is cancelled first
+func (l *hostLimiter) acquire(ctx context.Context, host string) bool {
+	select {
+	case l.global <- struct{}{}:
+	case <-ctx.Done():
+		return false
+	}
+	select {
+	case l.hostSlots(host) <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		<-l.global
+		return false
+	}
+}
+
+func (l *hostLimiter) release(host string) {
+	<-l.hostSlots(host)
+	<-l.global
+}
+
+func (l *hostLimiter) hostSlots(host string) chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	slots, ok := l.hosts[host]
+	if !ok {
+		slots = make(chan struct{}, l.perHost)
+		l.hosts[host] = slots
+	}
+	return slots
+}
+
+// sortedKeys returns the keys of a string set in order
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,249 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tharaka70/web_analyzer/internal/analyzer"
+)
+
+// site maps paths to the HTML body served for them
+var site = map[string]string{
+	"/":          `<html><head><title>Home</title></head><body><h1>Home</h1><a href="/a">A</a><a href="/b#top">B</a><a href="/private/x">X</a><a href="https://external.invalid/">ext</a></body></html>`,
+	"/a":         `<html><head><title>A</title></head><body><h1>A</h1><a href="/c">C</a><a href="/a#self">self</a><a href="/">home</a></body></html>`,
+	"/b":         `<html><body><h2>B</h2><a href="/missing">broken</a><a href="/doc.pdf">pdf</a><form><input type="text" name="user"><input type="password" name="pw"><button>Go</button></form></body></html>`,
+	"/c":         `<html><head><title>C</title></head><body><a href="/d">D</a><a href="/missing">broken</a></body></html>`,
+	"/d":         `<html><head><title>D</title></head><body></body></html>`,
+	"/private/x": `<html><head><title>X</title></head><body></body></html>`,
+}
+
+func newSiteServer(delay time.Duration, inFlight, maxInFlight *int, mu *sync.Mutex) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && inFlight != nil {
+			mu.Lock()
+			*inFlight++
+			if *inFlight > *maxInFlight {
+				*maxInFlight = *inFlight
+			}
+			mu.Unlock()
+			defer func() {
+				mu.Lock()
+				*inFlight--
+				mu.Unlock()
+			}()
+		}
+		time.Sleep(delay)
+		if r.URL.Path == "/doc.pdf" {
+			w.Header().Set("Content-Type", "application/pdf")
+			return
+		}
+		if r.URL.Path == "/sitemap.xml" {
+			w.Header().Set("Content-Type", "application/xml")
+			fmt.Fprintf(w, `<urlset><url><loc>http://%[1]s/</loc></url><url><loc>http://%[1]s/a</loc></url><url><loc>http://%[1]s/d</loc></url><url><loc>http://%[1]s/unlinked</loc></url></urlset>`, r.Host)
+			return
+		}
+		body, ok := site[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, body)
+	}))
+}
+
+func crawledPaths(t *testing.T, server *httptest.Server, report *SiteReport) []string {
+	t.Helper()
+	var paths []string
+	for _, page := range report.Pages {
+		paths = append(paths, page.URL[len(server.URL):])
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func TestCrawl(t *testing.T) {
+	server := newSiteServer(0, nil, nil, nil)
+	defer server.Close()
+
+	c := New(
+		WithMaxDepth(2),
+		WithExclude(regexp.MustCompile(`^/private/`)),
+		WithAnalyzerOptions(analyzer.WithLinkTimeout(2*time.Second)),
+	)
+	report, err := c.Crawl(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("Crawl failed unexpectedly: %v", err)
+	}
+
+	// /d is at depth 3 and /private/x is excluded; /doc.pdf is skipped and /missing fails
+	expected := []string{"/", "/a", "/b", "/c", "/doc.pdf", "/missing"}
+	if got := crawledPaths(t, server, report); fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf("Expected crawled pages %v, got %v", expected, got)
+	}
+	if report.PagesAnalyzed != 4 || report.PagesSkipped != 1 || report.PagesFailed != 1 {
+		t.Errorf("Expected 4 analyzed, 1 skipped, 1 failed pages, got %d/%d/%d", report.PagesAnalyzed, report.PagesSkipped, report.PagesFailed)
+	}
+	if report.Truncated {
+		t.Errorf("Expected crawl not to be truncated")
+	}
+	if report.HeadingsCount["h1"] != 2 || report.HeadingsCount["h2"] != 1 {
+		t.Errorf("Expected aggregated headings h1=2 h2=1, got %v", report.HeadingsCount)
+	}
+	if len(report.PagesMissingTitle) != 1 || report.PagesMissingTitle[0] != server.URL+"/b" {
+		t.Errorf("Expected /b to be reported as missing a title, got %v", report.PagesMissingTitle)
+	}
+	if len(report.PagesWithLoginForm) != 1 || report.PagesWithLoginForm[0] != server.URL+"/b" {
+		t.Errorf("Expected /b to be reported as having a login form, got %v", report.PagesWithLoginForm)
+	}
+
+	// /missing is linked from /b and /c, the external link is unresolvable
+	var missing *SiteLinkIssue
+	for i := range report.InaccessibleLinks {
+		if report.InaccessibleLinks[i].URL == server.URL+"/missing" {
+			missing = &report.InaccessibleLinks[i]
+		}
+	}
+	if missing == nil {
+		t.Fatalf("Expected /missing among inaccessible links, got %+v", report.InaccessibleLinks)
+	}
+	if missing.StatusCode != http.StatusNotFound || len(missing.FoundOn) != 2 {
+		t.Errorf("Expected /missing to be a 404 found on 2 pages, got %+v", missing)
+	}
+
+	for _, page := range report.Pages {
+		if page.URL == server.URL+"/c" && (page.Depth != 2 || page.FoundOn != server.URL+"/a") {
+			t.Errorf("Expected /c at depth 2 found on /a, got depth %d found on %s", page.Depth, page.FoundOn)
+		}
+	}
+}
+
+func TestCrawl_Sitemap(t *testing.T) {
+	server := newSiteServer(0, nil, nil, nil)
+	defer server.Close()
+
+	report, err := New(WithMaxDepth(1), WithSitemapCheck(true), WithAnalyzerOptions(analyzer.WithLinkCheck(false))).Crawl(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("Crawl failed unexpectedly: %v", err)
+	}
+	if report.Sitemap == nil || report.Sitemap.URLsTotal != 4 {
+		t.Fatalf("Expected a sitemap report with 4 URLs, got %+v", report.Sitemap)
+	}
+	for _, page := range report.Pages {
+		if page.Result != nil && page.Result.Sitemap != nil {
+			t.Errorf("Expected sitemaps to be checked once per crawl, not on page %s", page.URL)
+		}
+	}
+
+	// /d is beyond the depth limit and nothing links to /unlinked, which is also broken
+	expected := []string{server.URL + "/d", server.URL + "/unlinked"}
+	if fmt.Sprint(report.SitemapURLsNotCrawled) != fmt.Sprint(expected) {
+		t.Errorf("Expected sitemap URLs not crawled %v, got %v", expected, report.SitemapURLsNotCrawled)
+	}
+	if len(report.Sitemap.Broken) != 1 || report.Sitemap.Broken[0].URL != server.URL+"/unlinked" {
+		t.Errorf("Expected /unlinked to be the only broken sitemap URL, got %+v", report.Sitemap.Broken)
+	}
+}
+
+func TestCrawl_LinkPolicy(t *testing.T) {
+	other := newSiteServer(0, nil, nil, nil)
+	defer other.Close()
+	seed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<html><head><title>Seed</title></head><body><a href="%s/d">D</a></body></html>`, other.URL)
+	}))
+	defer seed.Close()
+
+	crawl := func(opts ...analyzer.Option) int {
+		report, err := New(WithMaxDepth(1), WithAnalyzerOptions(append(opts, analyzer.WithLinkCheck(false))...)).Crawl(context.Background(), seed.URL)
+		if err != nil {
+			t.Fatalf("Crawl failed unexpectedly: %v", err)
+		}
+		return len(report.Pages)
+	}
+
+	if pages := crawl(); pages != 1 {
+		t.Errorf("Expected the link to another host not to be followed by default, got %d pages", pages)
+	}
+	// With the other host as an alias its links are internal, in the report and for the crawl
+	policy := analyzer.LinkPolicy{Scope: analyzer.LinkScopeHost, MatchScheme: true, Aliases: []string{other.Listener.Addr().String()}}
+	if pages := crawl(analyzer.WithLinkPolicy(policy)); pages != 2 {
+		t.Errorf("Expected the link to the alias host to be followed, got %d pages", pages)
+	}
+}
+
+func TestCrawl_HostSpelling(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<html><head><title>T</title></head><body><a href="/a">A</a><a href="http://LOCALHOST:%s/a">A again</a></body></html>`, server.URL[strings.LastIndex(server.URL, ":")+1:])
+	}))
+	defer server.Close()
+	seed := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+
+	report, err := New(WithMaxDepth(1), WithAnalyzerOptions(analyzer.WithLinkCheck(false))).Crawl(context.Background(), seed)
+	if err != nil {
+		t.Fatalf("Crawl failed unexpectedly: %v", err)
+	}
+	if len(report.Pages) != 2 {
+		t.Errorf("Expected / and /a to be crawled once each, got %d pages", len(report.Pages))
+	}
+}
+
+func TestCrawl_Limits(t *testing.T) {
+	t.Run("MaxPages", func(t *testing.T) {
+		server := newSiteServer(0, nil, nil, nil)
+		defer server.Close()
+
+		report, err := New(WithMaxPages(3), WithAnalyzerOptions(analyzer.WithLinkCheck(false))).Crawl(context.Background(), server.URL)
+		if err != nil {
+			t.Fatalf("Crawl failed unexpectedly: %v", err)
+		}
+		if len(report.Pages) != 3 || !report.Truncated {
+			t.Errorf("Expected 3 pages and a truncated crawl, got %d pages (truncated=%v)", len(report.Pages), report.Truncated)
+		}
+	})
+
+	t.Run("Include", func(t *testing.T) {
+		server := newSiteServer(0, nil, nil, nil)
+		defer server.Close()
+
+		report, err := New(WithInclude(regexp.MustCompile(`^/(a|c)$`)), WithAnalyzerOptions(analyzer.WithLinkCheck(false))).Crawl(context.Background(), server.URL)
+		if err != nil {
+			t.Fatalf("Crawl failed unexpectedly: %v", err)
+		}
+		expected := []string{"/", "/a", "/c"}
+		if got := crawledPaths(t, server, report); fmt.Sprint(got) != fmt.Sprint(expected) {
+			t.Errorf("Expected crawled pages %v, got %v", expected, got)
+		}
+	})
+
+	t.Run("PerHostLimit", func(t *testing.T) {
+		var mu sync.Mutex
+		var inFlight, maxInFlight int
+		server := newSiteServer(50*time.Millisecond, &inFlight, &maxInFlight, &mu)
+		defer server.Close()
+
+		_, err := New(WithConcurrency(8), WithPerHostLimit(1), WithAnalyzerOptions(analyzer.WithLinkCheck(false))).Crawl(context.Background(), server.URL)
+		if err != nil {
+			t.Fatalf("Crawl failed unexpectedly: %v", err)
+		}
+		if maxInFlight != 1 {
+			t.Errorf("Expected at most 1 concurrent page fetch per host, got %d", maxInFlight)
+		}
+	})
+
+	t.Run("InvalidSeed", func(t *testing.T) {
+		if _, err := New().Crawl(context.Background(), "ftp://example.com"); err == nil {
+			t.Errorf("Expected an error for an invalid seed URL")
+		}
+	})
+}
@@ -0,0 +1,94 @@
+package crawler
+
+import (
+	"sort"
+
+	"github.com/tharaka70/web_analyzer/internal/analyzer"
+)
+
+// SiteReport aggregates the per-page results of a crawl
+type SiteReport struct {
+	SeedURL            string          `json:"seed_url"`
+	Pages              []PageReport    `json:"pages"` // in crawl order: by depth, then discovery
+	PagesAnalyzed      int             `json:"pages_analyzed"`
+	PagesFailed        int             `json:"pages_failed"`
+	PagesSkipped       int             `json:"pages_skipped"` // not HTML, or disallowed by robots.txt
+	Truncated          bool            `json:"truncated"`     // the page limit stopped the crawl before every page was visited
+	InternalLinksCount int             `json:"internal_links_count"`
+	ExternalLinksCount int             `json:"external_links_count"`
+	HeadingsCount      map[string]int  `json:"headings_count"`
+	HTMLVersions       map[string]int  `json:"html_versions"` // number of pages per detected HTML version
+	PagesMissingTitle  []string        `json:"pages_missing_title"`
+	PagesWithLoginForm []string        `json:"pages_with_login_form"`
+	InaccessibleLinks  []SiteLinkIssue `json:"inaccessible_links"` // unique across the site
+
+	// Set when the sitemap check is enabled
+	Sitemap               *analyzer.SitemapReport `json:"sitemap,omitempty"`
+	SitemapURLsNotCrawled []string                `json:"sitemap_urls_not_crawled,omitempty"`
+}
+
+// SiteLinkIssue is an inaccessible link together with every page that references it
+type SiteLinkIssue struct {
+	URL        string                  `json:"url"`
+	StatusCode int                     `json:"status_code"`
+	ErrorClass analyzer.LinkErrorClass `json:"error_class"`
+	FoundOn    []string                `json:"found_on"`
+}
+
+// buildReport aggregates page reports into a site-level report
+func buildReport(seedURL string, pages []PageReport) *SiteReport {
+	report := &SiteReport{
+		SeedURL:            seedURL,
+		Pages:              pages,
+		HeadingsCount:      make(map[string]int),
+		HTMLVersions:       make(map[string]int),
+		PagesMissingTitle:  []string{},
+		PagesWithLoginForm: []string{},
+		InaccessibleLinks:  []SiteLinkIssue{},
+	}
+
+	issues := make(map[string]*SiteLinkIssue)
+	issuePages := make(map[string]map[string]bool)
+	for _, page := range pages {
+		if page.Result == nil {
+			if page.ErrorCategory == analyzer.ErrorCategoryNotHTML || page.ErrorCategory == analyzer.ErrorCategoryRobots {
+				report.PagesSkipped++
+			} else {
+				report.PagesFailed++
+			}
+			continue
+		}
+
+		r := page.Result
+		report.PagesAnalyzed++
+		report.InternalLinksCount += r.InternalLinksCount
+		report.ExternalLinksCount += r.ExternalLinksCount
+		report.HTMLVersions[r.HTMLVersion]++
+		for level, count := range r.HeadingsCount {
+			report.HeadingsCount[level] += count
+		}
+		if r.PageTitle == "" {
+			report.PagesMissingTitle = append(report.PagesMissingTitle, page.URL)
+		}
+		if r.ContainsLoginForm {
+			report.PagesWithLoginForm = append(report.PagesWithLoginForm, page.URL)
+		}
+
+		for _, link := range r.InaccessibleLinks {
+			if _, ok := issues[link.URL]; !ok {
+				issues[link.URL] = &SiteLinkIssue{URL: link.URL, StatusCode: link.StatusCode, ErrorClass: link.ErrorClass}
+				issuePages[link.URL] = make(map[string]bool)
+			}
+			issuePages[link.URL][page.URL] = true
+		}
+	}
+
+	for linkURL, issue := range issues {
+		issue.FoundOn = sortedKeys(issuePages[linkURL])
+		report.InaccessibleLinks = append(report.InaccessibleLinks, *issue)
+	}
+	sort.Slice(report.InaccessibleLinks, func(i, j int) bool {
+		return report.InaccessibleLinks[i].URL < report.InaccessibleLinks[j].URL
+	})
+	return report
+}
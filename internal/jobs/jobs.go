@@ -0,0 +1,312 @@
+// Package jobs runs page analyses asynchronously on a bounded worker pool and
+// lets callers poll or subscribe to their progress.
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/tharaka70/web_analyzer/internal/analyzer"
+)
+
+// Status is the lifecycle state of a job
+type Status string
+
+const (
+	StatusQueued    Status = "queued"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+)
+
+// Finished reports whether the job has reached a terminal state
+func (s Status) Finished() bool {
+	return s == StatusSucceeded || s == StatusFailed
+}
+
+// Defaults used by NewManager when no option overrides them
+const (
+	DefaultWorkers   = 4
+	DefaultQueueSize = 100
+	DefaultRetention = 30 * time.Minute
+)
+
+// ErrQueueFull is returned by Submit when no more jobs can be accepted
+var ErrQueueFull = errors.New("job queue is full")
+
+// ErrShuttingDown is returned by Submit after Shutdown has been called
+var ErrShuttingDown = errors.New("job manager is shutting down")
+
+// Job is a point-in-time snapshot of an analysis job
+type Job struct {
+	ID         string
+	URL        string
+	Status     Status
+	Progress   analyzer.Progress
+	Result     *analyzer.AnalysisResult // set when Status is StatusSucceeded
+	Err        error                    // set when Status is StatusFailed
+	CreatedAt  time.Time
+	FinishedAt time.Time
+}
+
+// job is the mutable state behind a Job snapshot, guarded by Manager.mu
+type job struct {
+	Job
+	opts        []analyzer.Option
+	subscribers map[chan Job]struct{}
+}
+
+// Manager owns the job table and the worker pool. Create one with NewManager.
+type Manager struct {
+	workers   int
+	queueSize int
+	retention time.Duration
+
+	mu     sync.Mutex
+	jobs   map[string]*job
+	closed bool
+
+	queue  chan *job
+	ctx    context.Context // cancelled on Shutdown to abort running analyses
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// Option configures a Manager
+type Option func(*Manager)
+
+// WithWorkers sets how many analyses run at the same time
+func WithWorkers(n int) Option {
+	return func(m *Manager) {
+		if n > 0 {
+			m.workers = n
+		}
+	}
+}
+
+// WithQueueSize sets how many jobs may wait for a free worker before Submit fails
+func WithQueueSize(n int) Option {
+	return func(m *Manager) {
+		if n >= 0 {
+			m.queueSize = n
+		}
+	}
+}
+
+// WithRetention sets how long finished jobs are kept around for polling
+func WithRetention(d time.Duration) Option {
+	return func(m *Manager) {
+		if d > 0 {
+			m.retention = d
+		}
+	}
+}
+
+// NewManager creates a Manager and starts its workers
+func NewManager(opts ...Option) *Manager {
+	m := &Manager{
+		workers:   DefaultWorkers,
+		queueSize: DefaultQueueSize,
+		retention: DefaultRetention,
+		jobs:      make(map[string]*job),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	m.queue = make(chan *job, m.queueSize)
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+
+	for i := 0; i < m.workers; i++ {
+		m.wg.Add(1)
+		go m.worker()
+	}
+	return m
+}
+
+// Submit queues an analysis of pageURL with the given analyzer options and returns
+// the new job immediately. The URL is expected to be validated already.
+func (m *Manager) Submit(pageURL string, opts ...analyzer.Option) (Job, error) {
+	id, err := newJobID()
+	if err != nil {
+		return Job{}, err
+	}
+	j := &job{
+		Job: Job{
+			ID:        id,
+			URL:       pageURL,
+			Status:    StatusQueued,
+			CreatedAt: time.Now(),
+		},
+		opts:        opts,
+		subscribers: make(map[chan Job]struct{}),
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return Job{}, ErrShuttingDown
+	}
+	m.pruneLocked()
+
+	select {
+	case m.queue <- j:
+	default:
+		return Job{}, ErrQueueFull
+	}
+	m.jobs[id] = j
+	slog.Info("Job queued", "job_id", id, "url", pageURL)
+	return j.Job, nil
+}
+
+// Get returns a snapshot of the job with the given ID
+func (m *Manager) Get(id string) (Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return j.Job, true
+}
+
+// Subscribe returns the current snapshot of a job and a channel that receives later
+// snapshots. Only the most recent snapshot is buffered, so a slow reader skips
+// intermediate progress but always sees the final state. The channel is closed once
+// the job has finished; call unsubscribe to stop listening earlier.
+func (m *Manager) Subscribe(id string) (current Job, updates <-chan Job, unsubscribe func(), ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return Job{}, nil, nil, false
+	}
+
+	ch := make(chan Job, 1)
+	if j.Status.Finished() {
+		close(ch)
+		return j.Job, ch, func() {}, true
+	}
+	j.subscribers[ch] = struct{}{}
+	unsubscribe = func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if _, subscribed := j.subscribers[ch]; subscribed {
+			delete(j.subscribers, ch)
+			close(ch)
+		}
+	}
+	return j.Job, ch, unsubscribe, true
+}
+
+// Shutdown stops accepting jobs, aborts running analyses and waits for the workers
+// to exit or for ctx to expire. Jobs still in the queue are marked as failed.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.mu.Lock()
+	if !m.closed {
+		m.closed = true
+		close(m.queue)
+	}
+	m.mu.Unlock()
+	m.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// worker runs queued jobs until the queue is closed
+func (m *Manager) worker() {
+	defer m.wg.Done()
+	for j := range m.queue {
+		m.run(j)
+	}
+}
+
+// run performs a single analysis and records its outcome
+func (m *Manager) run(j *job) {
+	if err := m.ctx.Err(); err != nil {
+		m.finish(j, nil, err)
+		return
+	}
+	m.update(j, func(j *job) { j.Status = StatusRunning })
+	slog.Info("Job started", "job_id", j.ID, "url", j.URL)
+
+	opts := append(append([]analyzer.Option{}, j.opts...), analyzer.WithProgress(func(p analyzer.Progress) {
+		m.update(j, func(j *job) { j.Progress = p })
+	}))
+	result, err := analyzer.New(opts...).Analyze(m.ctx, j.URL)
+	m.finish(j, result, err)
+}
+
+// finish moves a job into its terminal state and closes its subscriptions
+func (m *Manager) finish(j *job, result *analyzer.AnalysisResult, err error) {
+	m.update(j, func(j *job) {
+		j.FinishedAt = time.Now()
+		if err != nil {
+			j.Status = StatusFailed
+			j.Err = err
+			return
+		}
+		j.Status = StatusSucceeded
+		j.Result = result
+	})
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for ch := range j.subscribers {
+		delete(j.subscribers, ch)
+		close(ch)
+	}
+	if err != nil {
+		slog.Warn("Job failed", "job_id", j.ID, "url", j.URL, "error", err)
+	} else {
+		slog.Info("Job succeeded", "job_id", j.ID, "url", j.URL)
+	}
+}
+
+// update applies fn to the job and pushes the new snapshot to every subscriber
+func (m *Manager) update(j *job, fn func(*job)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fn(j)
+	for ch := range j.subscribers {
+		// Replace any snapshot the subscriber hasn't read yet with the newer one
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- j.Job
+	}
+}
+
+// pruneLocked forgets finished jobs older than the retention period. m.mu must be held.
+func (m *Manager) pruneLocked() {
+	cutoff := time.Now().Add(-m.retention)
+	for id, j := range m.jobs {
+		if j.Status.Finished() && j.FinishedAt.Before(cutoff) {
+			delete(m.jobs, id)
+		}
+	}
+}
+
+// newJobID returns a random, URL-safe job identifier
+func newJobID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
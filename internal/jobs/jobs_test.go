@@ -0,0 +1,170 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tharaka70/web_analyzer/internal/analyzer"
+)
+
+// newPageServer serves a small HTML page with two links; link requests wait for release
+func newPageServer(release <-chan struct{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><head><title>Job Page</title></head><body><a href="/one">1</a><a href="/two">2</a></body></html>`)
+	}))
+}
+
+// waitFinished drains a subscription until it is closed and returns the last snapshot seen
+func waitFinished(t *testing.T, m *Manager, id string) (Job, []Job) {
+	t.Helper()
+	current, updates, unsubscribe, ok := m.Subscribe(id)
+	if !ok {
+		t.Fatalf("Subscribe(%s): job not found", id)
+	}
+	defer unsubscribe()
+
+	seen := []Job{current}
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case job, open := <-updates:
+			if !open {
+				final, _ := m.Get(id)
+				return final, seen
+			}
+			seen = append(seen, job)
+		case <-timeout:
+			t.Fatalf("Timed out waiting for job %s to finish", id)
+		}
+	}
+}
+
+func TestManager_RunsJobAndReportsProgress(t *testing.T) {
+	release := make(chan struct{})
+	close(release)
+	server := newPageServer(release)
+	defer server.Close()
+
+	m := NewManager(WithWorkers(1))
+	defer m.Shutdown(context.Background())
+
+	job, err := m.Submit(server.URL)
+	if err != nil {
+		t.Fatalf("Submit failed unexpectedly: %v", err)
+	}
+	if job.Status != StatusQueued || job.ID == "" {
+		t.Errorf("Expected a queued job with an ID, got %+v", job)
+	}
+
+	final, seen := waitFinished(t, m, job.ID)
+	if final.Status != StatusSucceeded {
+		t.Fatalf("Expected job to succeed, got status %s (error: %v)", final.Status, final.Err)
+	}
+	if final.Result == nil || final.Result.PageTitle != "Job Page" {
+		t.Errorf("Expected result with title 'Job Page', got %+v", final.Result)
+	}
+	if final.Progress.Phase != analyzer.PhaseDone || final.Progress.LinksChecked != 2 {
+		t.Errorf("Expected final progress done with 2 links checked, got %+v", final.Progress)
+	}
+	if final.FinishedAt.IsZero() {
+		t.Errorf("Expected FinishedAt to be set")
+	}
+	if len(seen) < 2 {
+		t.Errorf("Expected to observe progress updates, got %d snapshots", len(seen))
+	}
+}
+
+func TestManager_FailedJob(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	m := NewManager()
+	defer m.Shutdown(context.Background())
+
+	job, err := m.Submit(server.URL)
+	if err != nil {
+		t.Fatalf("Submit failed unexpectedly: %v", err)
+	}
+	final, _ := waitFinished(t, m, job.ID)
+	if final.Status != StatusFailed {
+		t.Fatalf("Expected job to fail, got status %s", final.Status)
+	}
+	var ae *analyzer.AnalysisError
+	if !errors.As(final.Err, &ae) || ae.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected AnalysisError with status 404, got %v", final.Err)
+	}
+}
+
+func TestManager_QueueFullAndShutdown(t *testing.T) {
+	release := make(chan struct{})
+	server := newPageServer(release)
+	defer server.Close()
+	defer close(release)
+
+	m := NewManager(WithWorkers(1), WithQueueSize(1))
+
+	running, err := m.Submit(server.URL)
+	if err != nil {
+		t.Fatalf("Submit failed unexpectedly: %v", err)
+	}
+	// Wait until the worker has picked up the first job so the queue slot is free
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job, _ := m.Get(running.ID)
+		if job.Status == StatusRunning {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for job to start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	queued, err := m.Submit(server.URL)
+	if err != nil {
+		t.Fatalf("Expected second job to be queued, got error: %v", err)
+	}
+	if _, err := m.Submit(server.URL); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Expected ErrQueueFull, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := m.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	for _, id := range []string{running.ID, queued.ID} {
+		job, _ := m.Get(id)
+		if job.Status != StatusFailed {
+			t.Errorf("Expected job %s to be failed after shutdown, got %s", id, job.Status)
+		}
+	}
+	if _, err := m.Submit(server.URL); !errors.Is(err, ErrShuttingDown) {
+		t.Errorf("Expected ErrShuttingDown after Shutdown, got %v", err)
+	}
+}
+
+func TestManager_UnknownJob(t *testing.T) {
+	m := NewManager()
+	defer m.Shutdown(context.Background())
+
+	if _, ok := m.Get("missing"); ok {
+		t.Errorf("Expected Get to report a missing job")
+	}
+	if _, _, _, ok := m.Subscribe("missing"); ok {
+		t.Errorf("Expected Subscribe to report a missing job")
+	}
+}
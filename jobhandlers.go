@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/tharaka70/web_analyzer/internal/analyzer"
+	"github.com/tharaka70/web_analyzer/internal/jobs"
+)
+
+// apiJob is the JSON representation of an analysis job
+type apiJob struct {
+	ID         string                   `json:"id"`
+	URL        string                   `json:"url"`
+	Status     jobs.Status              `json:"status"`
+	Progress   analyzer.Progress        `json:"progress"`
+	Result     *analyzer.AnalysisResult `json:"result,omitempty"`
+	Error      *apiError                `json:"error,omitempty"`
+	CreatedAt  time.Time                `json:"created_at"`
+	FinishedAt *time.Time               `json:"finished_at,omitempty"`
+	StatusURL  string                   `json:"status_url"`
+	EventsURL  string                   `json:"events_url"`
+	ReportURL  string                   `json:"report_url"`
+}
+
+func newAPIJob(j jobs.Job) apiJob {
+	aj := apiJob{
+		ID:        j.ID,
+		URL:       j.URL,
+		Status:    j.Status,
+		Progress:  j.Progress,
+		Result:    j.Result,
+		CreatedAt: j.CreatedAt,
+		StatusURL: "/jobs/" + j.ID,
+		EventsURL: "/jobs/" + j.ID + "/events",
+		ReportURL: "/jobs/" + j.ID + "/report",
+	}
+	if j.Err != nil {
+		_, apiErr := analysisErrorResponse(j.Err)
+		aj.Error = &apiErr
+	}
+	if !j.FinishedAt.IsZero() {
+		finishedAt := j.FinishedAt
+		aj.FinishedAt = &finishedAt
+	}
+	return aj
+}
+
+// submitJobHandler queues an analysis from a JSON request and returns the job immediately
+func submitJobHandler(w http.ResponseWriter, r *http.Request) {
+	req, parsedURL, ok := decodeAnalysisRequest(w, r)
+	if !ok {
+		return
+	}
+
+	job, err := jobManager.Submit(parsedURL.String(), req.Options.analyzerOptions()...)
+	if err != nil {
+		logger.Error("Error submitting job", "URL", parsedURL.String(), "error", err)
+		writeAPIError(w, http.StatusServiceUnavailable, apiError{Message: err.Error(), Category: apiErrorCategoryUnavailable})
+		return
+	}
+
+	aj := newAPIJob(job)
+	w.Header().Set("Location", aj.StatusURL)
+	writeJSON(w, http.StatusAccepted, aj)
+}
+
+// getJobHandler returns the current state of a job as JSON
+func getJobHandler(w http.ResponseWriter, r *http.Request) {
+	job, ok := jobManager.Get(r.PathValue("id"))
+	if !ok {
+		writeAPIError(w, http.StatusNotFound, apiError{Message: "Job not found", Category: apiErrorCategoryNotFound})
+		return
+	}
+	writeJSON(w, http.StatusOK, newAPIJob(job))
+}
+
+// jobEventsHandler streams job updates as Server-Sent Events. Every update is sent as a
+// "progress" event carrying the job JSON; the final state is sent as a "done" event.
+func jobEventsHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	current, updates, unsubscribe, ok := jobManager.Subscribe(r.PathValue("id"))
+	if !ok {
+		writeAPIError(w, http.StatusNotFound, apiError{Message: "Job not found", Category: apiErrorCategoryNotFound})
+		return
+	}
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	for {
+		event := "progress"
+		if current.Status.Finished() {
+			event = "done"
+		}
+		if err := writeSSE(w, event, newAPIJob(current)); err != nil {
+			logger.Error("Error writing job event", "job_id", current.ID, "error", err)
+			return
+		}
+		flusher.Flush()
+		if event == "done" {
+			return
+		}
+
+		select {
+		case job, open := <-updates:
+			if !open {
+				// The job finished between snapshots; send its final state
+				job, _ = jobManager.Get(current.ID)
+			}
+			current = job
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// writeSSE writes a single Server-Sent Event with a JSON payload
+func writeSSE(w http.ResponseWriter, event string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	return err
+}
+
+// jobReportHandler renders a job as HTML: the live progress page while it runs,
+// then the results page (or the form with an error) once it has finished
+func jobReportHandler(w http.ResponseWriter, r *http.Request) {
+	job, ok := jobManager.Get(r.PathValue("id"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	renderJobPage(w, job)
+}
+
+// renderJobPage picks the template matching the job's state
+func renderJobPage(w http.ResponseWriter, job jobs.Job) {
+	pageData := PageData{URL: job.URL, JobID: job.ID, Progress: job.Progress}
+	templateName := "progress.html"
+	switch job.Status {
+	case jobs.StatusSucceeded:
+		pageData.Analysis = job.Result
+		templateName = "results.html"
+	case jobs.StatusFailed:
+		pageData.Error = job.Err.Error()
+		// If the error is of the custom type, extract the status code
+		var ae *analyzer.AnalysisError
+		if errors.As(job.Err, &ae) {
+			pageData.StatusCode = ae.StatusCode
+		}
+		templateName = "index.html" // Show error on the index page
+	}
+
+	templateErr := tmpl.ExecuteTemplate(w, templateName, pageData)
+	if templateErr != nil {
+		logger.Error("Error rendering job template", "template", templateName, "error", templateErr)
+		http.Error(w, "Error rendering page", http.StatusInternalServerError)
+	}
+}
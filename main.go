@@ -1,37 +1,79 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"html/template"
+	"io"
 	"log/slog"
 	"net/http"
-	"net/url"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
 
 	"github.com/tharaka70/web_analyzer/internal/analyzer"
+	"github.com/tharaka70/web_analyzer/internal/jobs"
 )
 
 var logger *slog.Logger // Global logger instance
 
-// Global template variable
+// Global template variable, loaded by the serve command
 var tmpl *template.Template
 
-// init function to parse templates on program startup
-func init() {
-	// Initialize templates
-	tmpl = template.Must(template.ParseGlob("templates/*.html"))
+// Shared HTTP client so connections are pooled across analyses
+var httpClient = &http.Client{}
+
+// Shared per-host limits and robots.txt cache, so concurrent analyses of the same host are polite together.
+// The serve command sizes hostThrottle from its flags.
+var (
+	hostThrottle = analyzer.NewHostThrottle(analyzer.DefaultHostConcurrency, analyzer.DefaultHostRate, analyzer.DefaultHostBurst)
+	robotsCache  = analyzer.NewRobotsCache()
+)
+
+// politenessOptions returns the options sharing the server's per-host limits and robots.txt cache.
+// Each analysis also gets the server's limits as its own, so they are what it runs with by default.
+func politenessOptions() []analyzer.Option {
+	concurrency, rate, burst := hostThrottle.Limits()
+	return []analyzer.Option{
+		analyzer.WithHostThrottle(hostThrottle),
+		analyzer.WithRobotsCache(robotsCache),
+		analyzer.WithHostConcurrency(concurrency),
+		analyzer.WithHostRateLimit(rate, burst),
+	}
+}
+
+// Runs form-submitted and /jobs analyses in the background on a bounded worker pool; started by the serve command
+var jobManager *jobs.Manager
 
+// init function to set up logging on program startup
+func init() {
 	// Initialize structured logger
 	logger = slog.New(slog.NewJSONHandler(os.Stdout, nil))
 	slog.SetDefault(logger)
 }
 
+// loadTemplates parses all HTML templates in dir
+func loadTemplates(dir string) error {
+	parsed, err := template.ParseGlob(filepath.Join(dir, "*.html"))
+	if err != nil {
+		return err
+	}
+	tmpl = parsed
+	return nil
+}
+
 // This struct holds all data passed to HTML templates
 type PageData struct {
 	URL        string
 	Error      string
 	StatusCode int
 	Analysis   *analyzer.AnalysisResult
+	JobID      string            // set when the page belongs to a background job
+	Progress   analyzer.Progress // last known progress of that job
 }
 
 // analyzeHandler processes the form submission and displays analysis results or errors
@@ -42,20 +84,11 @@ func analyzeHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	submittedURL := r.FormValue("url")
-	if submittedURL == "" {
-		data := PageData{Error: "URL field cannot be empty."}
-		templateErr := tmpl.ExecuteTemplate(w, "index.html", data)
-		if templateErr != nil {
-			logger.Error("Error rendering template for empty URL:", "error", templateErr)
-			http.Error(w, "Error rendering page", http.StatusInternalServerError)
-		}
-		return
-	}
 
 	// Validate the submitted URL
-	parsedURL, parseErr := url.ParseRequestURI(submittedURL)
-	if parseErr != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") {
-		data := PageData{Error: fmt.Sprintf("Invalid URL: %q. Must be a valid HTTP/HTTPS URL.", submittedURL)}
+	parsedURL, validationErr := analyzer.ValidateURL(submittedURL)
+	if validationErr != nil {
+		data := PageData{Error: validationErr.Error()}
 		templateErr := tmpl.ExecuteTemplate(w, "index.html", data)
 		if templateErr != nil {
 			logger.Error("Error rendering template for invalid URL:", "error", templateErr)
@@ -63,51 +96,32 @@ func analyzeHandler(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
-	if parsedURL.Host == "" {
-		data := PageData{Error: fmt.Sprintf("Invalid URL: %q. URL must include a host (e.g., example.com).", submittedURL)}
-		templateErr := tmpl.ExecuteTemplate(w, "index.html", data)
-		if templateErr != nil {
-			logger.Error("Error rendering template for URL without host", "error", templateErr)
-			http.Error(w, "Error rendering page", http.StatusInternalServerError)
-		}
-		return
-	}
 
-	logger.Info("Attempting to analyze URL", "URL", parsedURL.String())
+	logger.Info("Submitting analysis job for URL", "URL", parsedURL.String())
 
-	// Perform the analysis by calling the function from the analyzer package
-	analysisResult, analysisErr := analyzer.FetchAndAnalyze(parsedURL.String())
-
-	if analysisErr != nil {
-		logger.Error("Error analyzing URL %s: %v", parsedURL.String(), analysisErr)
+	// Run the analysis in the background and show live progress instead of blocking the request
+	// Sitemap and resource probing fetch many more URLs, so they only run when ticked
+	opts := append(politenessOptions(),
+		analyzer.WithHTTPClient(httpClient),
+		analyzer.WithSitemapCheck(r.FormValue("check_sitemap") != ""),
+		analyzer.WithPerformanceCheck(r.FormValue("check_performance") != ""))
+	job, submitErr := jobManager.Submit(parsedURL.String(), opts...)
+	if submitErr != nil {
+		logger.Error("Error submitting analysis job", "URL", parsedURL.String(), "error", submitErr)
 		pageData := PageData{
 			URL:   submittedURL, // Show the originally submitted URL
-			Error: analysisErr.Error(),
-		}
-		// If the error is of the custom type, extract the status code
-		if ae, ok := analysisErr.(*analyzer.AnalysisError); ok {
-			pageData.StatusCode = ae.StatusCode
+			Error: "The analyzer is busy, please try again shortly.",
 		}
-
-		templateErr := tmpl.ExecuteTemplate(w, "index.html", pageData) // Show error on the index page
+		templateErr := tmpl.ExecuteTemplate(w, "index.html", pageData)
 		if templateErr != nil {
-			logger.Error("Error rendering template for analysis error:", "error", templateErr)
+			logger.Error("Error rendering template for busy analyzer:", "error", templateErr)
 			http.Error(w, "Error rendering page", http.StatusInternalServerError)
 		}
 		return
 	}
 
-	// If analysis is successful, prepare data for the results page
-	logger.Info("Successfully analyzed URL", "URL", parsedURL.String())
-	pageData := PageData{
-		URL:      submittedURL, // Show the originally submitted URL
-		Analysis: analysisResult,
-	}
-	templateErr := tmpl.ExecuteTemplate(w, "results.html", pageData)
-	if templateErr != nil {
-		logger.Error("Error rendering results template:", "error", templateErr)
-		http.Error(w, "Error rendering page", http.StatusInternalServerError)
-	}
+	// Redirect so that reloading the progress page doesn't resubmit the form
+	http.Redirect(w, r, "/jobs/"+job.ID+"/report", http.StatusSeeOther)
 }
 
 // indexHandler serves the initial form page
@@ -125,21 +139,120 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// newRouter registers all application routes on a fresh ServeMux
+func newRouter(staticDir string) *http.ServeMux {
+	mux := http.NewServeMux()
+
+	// Serve static files (CSS, JS) from the static directory
+	fs := http.FileServer(http.Dir(staticDir))
+	mux.Handle("/static/", http.StripPrefix("/static/", fs))
+
+	// Define application routes
+	mux.HandleFunc("/", indexHandler)
+	mux.HandleFunc("/analyze", analyzeHandler)
+	mux.HandleFunc("/api/v1/analyses", apiAnalysesHandler)
+	mux.HandleFunc("POST /jobs", submitJobHandler)
+	mux.HandleFunc("GET /jobs/{id}", getJobHandler)
+	mux.HandleFunc("GET /jobs/{id}/events", jobEventsHandler)
+	mux.HandleFunc("GET /jobs/{id}/report", jobReportHandler)
+	return mux
+}
+
+// Exit codes shared by all subcommands
+const (
+	exitOK           = 0
+	exitChecksFailed = 1 // analyze/crawl: at least one page failed a --fail-on condition
+	exitUsage        = 2 // invalid command line
+	exitFailure      = 3 // analyze: a page could not be analyzed; crawl: the seed could not be analyzed; serve: the server could not run
+)
+
+const usageText = `Usage:
+  web_analyzer [serve] [flags]          run the web server (default)
+  web_analyzer analyze [flags] <url>... analyze pages and print the results
+  web_analyzer crawl [flags] <seed-url> crawl a site and print a site-level report
+
+Run "web_analyzer <command> -h" for the flags of a command.
+`
+
 // main is the entry point of the application
 func main() {
-	// Serve static files (CSS) from the "static" directory
-	fs := http.FileServer(http.Dir("static"))
-	http.Handle("/static/", http.StripPrefix("/static/", fs))
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
 
-	// Define application routes
-	http.HandleFunc("/", indexHandler)
-	http.HandleFunc("/analyze", analyzeHandler)
+// run dispatches to the requested subcommand and returns the process exit code
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		return runServe(nil, stderr) // keep the historical behaviour of starting the server
+	}
+	switch args[0] {
+	case "serve":
+		return runServe(args[1:], stderr)
+	case "analyze":
+		return runAnalyze(args[1:], stdout, stderr)
+	case "crawl":
+		return runCrawl(args[1:], stdout, stderr)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usageText)
+		return exitOK
+	default:
+		if len(args[0]) > 0 && args[0][0] == '-' {
+			return runServe(args, stderr) // flags only, e.g. "web_analyzer -addr :9090"
+		}
+		fmt.Fprintf(stderr, "Unknown command %q\n\n%s", args[0], usageText)
+		return exitUsage
+	}
+}
 
-	port := "8080"
-	logger.Info("Server starting and listening on http://localhost:", "port", port)
+// runServe starts the web server and blocks until it fails or receives SIGINT/SIGTERM
+func runServe(args []string, stderr io.Writer) int {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	addr := flags.String("addr", ":8080", "address to listen on")
+	templateDir := flags.String("templates", "templates", "directory containing the HTML templates")
+	staticDir := flags.String("static", "static", "directory containing static assets (CSS, JS)")
+	workers := flags.Int("workers", jobs.DefaultWorkers, "number of analyses run in parallel")
+	hostConcurrency := flags.Int("host-concurrency", analyzer.DefaultHostConcurrency, "number of requests in flight to the same host, across all analyses")
+	hostRate := flags.Float64("host-rate", analyzer.DefaultHostRate, "link check requests per second to each host across all analyses (0 for no limit)")
+	hostBurst := flags.Int("host-burst", analyzer.DefaultHostBurst, "number of link check requests to a host that may exceed -host-rate in a burst")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
 
-	// Start the HTTP server
-	if err := http.ListenAndServe(":"+port, nil); err != nil {
+	if err := loadTemplates(*templateDir); err != nil {
+		logger.Error("Could not load templates:", "dir", *templateDir, "error", err.Error())
+		return exitFailure
+	}
+	hostThrottle = analyzer.NewHostThrottle(*hostConcurrency, *hostRate, *hostBurst)
+	jobManager = jobs.NewManager(jobs.WithWorkers(*workers))
+
+	server := &http.Server{Addr: *addr, Handler: newRouter(*staticDir)}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		logger.Info("Server starting and listening on", "addr", *addr)
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
 		logger.Error("Could not start server:", "error", err.Error())
+		return exitFailure
+	case <-ctx.Done():
+	}
+
+	logger.Info("Shutting down server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logger.Error("Error shutting down server", "error", err.Error())
+	}
+	if err := jobManager.Shutdown(shutdownCtx); err != nil {
+		logger.Error("Error shutting down job manager", "error", err.Error())
 	}
+	return exitOK
 }
@@ -0,0 +1,34 @@
+// Follows a background analysis job over Server-Sent Events and opens the
+// report page once the job has finished.
+(function () {
+    var container = document.getElementById("job-progress");
+    if (!container || !window.EventSource) {
+        return;
+    }
+    var phase = document.getElementById("job-phase");
+    var bar = document.getElementById("job-links-bar");
+    var links = document.getElementById("job-links");
+    var source = new EventSource(container.dataset.eventsUrl);
+
+    function render(job) {
+        var progress = job.progress || {};
+        phase.textContent = progress.phase || job.status;
+        bar.max = progress.links_total || 1;
+        bar.value = progress.links_checked || 0;
+        links.textContent = (progress.links_checked || 0) + " / " + (progress.links_total || 0);
+    }
+
+    source.addEventListener("progress", function (e) {
+        render(JSON.parse(e.data));
+    });
+    source.addEventListener("done", function (e) {
+        render(JSON.parse(e.data));
+        source.close();
+        window.location.replace(container.dataset.reportUrl);
+    });
+    source.onerror = function () {
+        // The connection dropped (e.g. server restart); fall back to reloading the page
+        source.close();
+        setTimeout(function () { window.location.reload(); }, 2000);
+    };
+})();
@@ -0,0 +1,25 @@
+// Makes every <table class="sortable"> sortable by clicking its column headers.
+// Columns whose header has data-sort="number" are compared numerically.
+document.querySelectorAll("table.sortable").forEach(function (table) {
+    var headers = table.querySelectorAll("thead th");
+    headers.forEach(function (th, index) {
+        th.addEventListener("click", function () {
+            var numeric = th.dataset.sort === "number";
+            var ascending = th.dataset.order !== "asc";
+            headers.forEach(function (other) { delete other.dataset.order; });
+            th.dataset.order = ascending ? "asc" : "desc";
+
+            var tbody = table.tBodies[0];
+            var rows = Array.from(tbody.rows);
+            rows.sort(function (a, b) {
+                var x = a.cells[index].textContent.trim();
+                var y = b.cells[index].textContent.trim();
+                var cmp = numeric
+                    ? (parseFloat(x) || 0) - (parseFloat(y) || 0)
+                    : x.localeCompare(y);
+                return ascending ? cmp : -cmp;
+            });
+            rows.forEach(function (row) { tbody.appendChild(row); });
+        });
+    });
+});